@@ -0,0 +1,132 @@
+package discord
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/algao1/ichor/glucose/metrics"
+	"github.com/algao1/ichor/store"
+	"github.com/diamondburned/arikawa/v3/api"
+	"github.com/diamondburned/arikawa/v3/discord"
+	"github.com/diamondburned/arikawa/v3/utils/json/option"
+	"github.com/diamondburned/arikawa/v3/utils/sendpart"
+)
+
+const defaultAGPDays = 14
+
+var agpCommand = api.CreateCommandData{
+	Name:        "agp",
+	Description: "Get the ambulatory glucose profile.",
+	Options: discord.CommandOptions{
+		&discord.IntegerOption{
+			OptionName:  "days",
+			Description: "Number of days to include, from 14 to 90. Defaults to 14.",
+			Min:         option.NewInt(14),
+			Max:         option.NewInt(90),
+		},
+		patientOption,
+	},
+}
+
+type AGPReport struct {
+	Description string
+	Units       string
+
+	// Fractions of time in each of the consensus ranges. They are fixed so
+	// that reports are comparable, unlike the configured thresholds.
+	metrics.Ranges
+
+	Mean       float64
+	GMI        float64 // Glucose management indicator, in %.
+	CV         float64 // Coefficient of variation.
+	SensorWear float64 // Fraction of the period with readings.
+
+	Chart sendpart.File
+}
+
+func agpReport(days int, sto *store.Store) (*AGPReport, error) {
+	var conf store.Config
+	if err := sto.GetObject(store.IndexConfig, &conf); err != nil {
+		return nil, fmt.Errorf("unable to load config: %w", err)
+	}
+	loc := conf.Location()
+
+	end := time.Now()
+	start := end.AddDate(0, 0, -days)
+
+	var pts []store.TimePoint
+	if err := sto.GetPoints(start, end, store.FieldGlucose, &pts); err != nil {
+		return nil, fmt.Errorf("unable to get points: %w", err)
+	}
+	if len(pts) == 0 {
+		return nil, fmt.Errorf("no readings in the last %d days", days)
+	}
+
+	r, err := PlotAGP(metrics.Low, metrics.High, conf.Units, loc, pts)
+	if err != nil {
+		return nil, fmt.Errorf("unable to generate AGP: %w", err)
+	}
+
+	return &AGPReport{
+		Description: fmt.Sprintf("%s - %s (%d days)",
+			start.In(loc).Format("Mon, 02 Jan 2006"),
+			end.In(loc).Format("Mon, 02 Jan 2006"),
+			days,
+		),
+		Units:      conf.Units,
+		Ranges:     metrics.ConsensusRanges(pts),
+		Mean:       metrics.Mean(pts),
+		GMI:        metrics.GMI(pts),
+		CV:         metrics.CV(pts),
+		SensorWear: metrics.Coverage(pts, start, end),
+		Chart:      sendpart.File{Name: "agp.png", Reader: r},
+	}, nil
+}
+
+func agpResponse(opts []discord.CommandInteractionOption, sto *store.Store) (*api.InteractionResponseData, error) {
+	days := defaultAGPDays
+	if v, ok := getAllOptions(opts)["days"]; ok {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			return nil, fmt.Errorf("invalid days: %w", err)
+		}
+		days = n
+	}
+
+	ar, err := agpReport(days, sto)
+	if err != nil {
+		return nil, err
+	}
+
+	u := ar.Units
+	return &api.InteractionResponseData{
+		Embeds: &[]discord.Embed{{
+			Title:       "Ambulatory Glucose Profile",
+			Description: ar.Description,
+			Image:       &discord.EmbedImage{URL: "attachment://" + ar.Chart.Name},
+			Fields: []discord.EmbedField{
+				// Line 1.
+				{Name: "Very Low (<" + glucoseToString(metrics.VeryLow, u) + ")", Value: floatToString(ar.VeryLow), Inline: true},
+				{Name: "Low (" + glucoseToString(metrics.VeryLow, u) + "-" + glucoseToString(metrics.Low, u) + ")",
+					Value: floatToString(ar.Low), Inline: true},
+				{Name: "In Range (" + glucoseToString(metrics.Low, u) + "-" + glucoseToString(metrics.High, u) + ")",
+					Value: floatToString(ar.InRange), Inline: true},
+				// Line 2.
+				{Name: "High (" + glucoseToString(metrics.High, u) + "-" + glucoseToString(metrics.VeryHigh, u) + ")",
+					Value: floatToString(ar.High), Inline: true},
+				{Name: "Very High (>" + glucoseToString(metrics.VeryHigh, u) + ")", Value: floatToString(ar.VeryHigh), Inline: true},
+				inlineBlankField,
+				// Line 3.
+				{Name: "Mean", Value: glucoseToString(ar.Mean, u), Inline: true},
+				{Name: "GMI", Value: fmt.Sprintf("%.1f%%", ar.GMI), Inline: true},
+				{Name: "CV", Value: floatToString(ar.CV), Inline: true},
+				// Line 4.
+				{Name: "Sensor Wear", Value: floatToString(ar.SensorWear), Inline: true},
+			},
+			Footer: &discord.EmbedFooter{Text: "Ranges are the consensus targets, not the alert thresholds."},
+			Color:  discord.Color(WarnLevel1),
+		}},
+		Files: []sendpart.File{ar.Chart},
+	}, nil
+}
@@ -0,0 +1,285 @@
+package discord
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/algao1/ichor/glucose/onboard"
+	"github.com/algao1/ichor/store"
+	"github.com/diamondburned/arikawa/v3/api"
+	"github.com/diamondburned/arikawa/v3/discord"
+	"github.com/diamondburned/arikawa/v3/utils/json/option"
+)
+
+const (
+	// bolusIncrement is the step suggested doses are rounded down to.
+	bolusIncrement = 0.05
+	// maxReadingAge is the oldest reading the bolus calculator will use.
+	maxReadingAge = 15 * time.Minute
+	// bolusButtonPrefix starts the custom ID of the confirm button, followed
+	// by the user ID, the time of the suggestion, the units and the product.
+	// The product is last, since it is the only part that may hold ":".
+	bolusButtonPrefix = "bolus"
+	// bolusExpiry is how long a suggestion can be confirmed for, before the
+	// insulin on board it was computed with is out of date.
+	bolusExpiry = 10 * time.Minute
+	// maxCustomIDLength is the longest custom ID Discord accepts.
+	maxCustomIDLength = 100
+)
+
+const bolusDisclaimer = "⚠️ **Not for therapy.** This is an estimate from logged data and " +
+	"your profile. Check it yourself before dosing."
+
+var bolusCommand = api.CreateCommandData{
+	Name:        "bolus",
+	Description: "Suggest an insulin dose. Not for therapy.",
+	Options: discord.CommandOptions{
+		&discord.IntegerOption{
+			OptionName:  "carbs",
+			Description: "Carbohydrates about to be eaten (grams).",
+			Min:         option.ZeroInt,
+			Required:    true,
+		},
+		&discord.NumberOption{
+			OptionName:  "glucose",
+			Description: "Current glucose, in the configured units. Defaults to the latest reading.",
+			Min:         option.ZeroFloat,
+		},
+		&discord.StringOption{
+			OptionName:   "product",
+			Description:  "Insulin product. Defaults to the first rapid-acting one.",
+			Autocomplete: true,
+		},
+	},
+}
+
+// BolusSuggestion is a suggested dose and the values it was worked out from.
+// Glucose values are in mmol/L.
+type BolusSuggestion struct {
+	Product string
+	Carbs   int
+	Glucose float64
+
+	CarbRatio   float64
+	Sensitivity float64
+	TargetLow   float64
+	TargetHigh  float64
+
+	CarbDose   float64
+	Correction float64
+	IOB        float64
+	Total      float64
+}
+
+// suggestBolus works out a dose covering carbs and correcting glucose to the
+// target range, less the insulin on board. A glucose of zero or less means
+// the latest reading is used.
+func suggestBolus(sto *store.Store, carbs int, glucose float64, product string) (*BolusSuggestion, error) {
+	var conf store.Config
+	if err := sto.GetObject(store.IndexConfig, &conf); err != nil {
+		return nil, fmt.Errorf("unable to load config: %w", err)
+	}
+
+	profile, err := sto.GetProfile()
+	if err != nil {
+		return nil, err
+	}
+
+	products, err := getProducts(sto)
+	if err != nil {
+		return nil, err
+	}
+	p, err := store.FindInsulinProduct(products, product)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	if glucose <= 0 {
+		var pts []store.TimePoint
+		if err := sto.GetLastPoints(store.FieldGlucose, 1, &pts); err != nil {
+			return nil, fmt.Errorf("unable to get points: %w", err)
+		}
+		if len(pts) == 0 || now.Sub(pts[0].Time) > maxReadingAge {
+			return nil, fmt.Errorf("no reading in the last %s, enter the glucose instead", maxReadingAge)
+		}
+		glucose = pts[0].Value
+	}
+
+	ob, err := onboard.New(sto, now, now)
+	if err != nil {
+		return nil, err
+	}
+
+	bs := BolusSuggestion{
+		Product:     p.Name,
+		Carbs:       carbs,
+		Glucose:     glucose,
+		CarbRatio:   profile.CarbRatios.At(now, conf.Location()),
+		Sensitivity: profile.Sensitivities.At(now, conf.Location()),
+		IOB:         ob.IOB(now),
+	}
+	bs.TargetLow, bs.TargetHigh = profile.Targets.At(now, conf.Location())
+
+	bs.CarbDose = float64(carbs) / bs.CarbRatio
+	if glucose > bs.TargetHigh {
+		bs.Correction = (glucose - bs.TargetHigh) / bs.Sensitivity
+	} else if glucose < bs.TargetLow {
+		bs.Correction = (glucose - bs.TargetLow) / bs.Sensitivity
+	}
+
+	total := bs.CarbDose + bs.Correction - bs.IOB
+	bs.Total = math.Max(0, math.Floor(total/bolusIncrement+1e-9)*bolusIncrement)
+
+	return &bs, nil
+}
+
+func bolusEmbed(bs *BolusSuggestion, units string) discord.Embed {
+	correction := "In range, no correction"
+	if bs.Glucose > bs.TargetHigh {
+		correction = fmt.Sprintf("(%s - %s) ÷ %s = %.2f units",
+			glucoseToString(bs.Glucose, units), glucoseToString(bs.TargetHigh, units),
+			glucoseToString(bs.Sensitivity, units), bs.Correction)
+	} else if bs.Glucose < bs.TargetLow {
+		correction = fmt.Sprintf("(%s - %s) ÷ %s = %.2f units",
+			glucoseToString(bs.Glucose, units), glucoseToString(bs.TargetLow, units),
+			glucoseToString(bs.Sensitivity, units), bs.Correction)
+	}
+
+	return discord.Embed{
+		Title:       "Bolus Suggestion",
+		Description: bolusDisclaimer,
+		Fields: []discord.EmbedField{
+			{Name: "Carbohydrates", Value: fmt.Sprintf("%d grams ÷ %s = %.2f units",
+				bs.Carbs, strconv.FormatFloat(bs.CarbRatio, 'f', -1, 64), bs.CarbDose)},
+			{Name: "Correction", Value: correction},
+			{Name: "Insulin On Board", Value: fmt.Sprintf("-%.2f units", bs.IOB)},
+			{Name: "Suggested", Value: fmt.Sprintf("**%s %s**, rounded down to %s units",
+				insulinToString(bs.Total), bs.Product, strconv.FormatFloat(bolusIncrement, 'f', -1, 64))},
+		},
+		Footer: &discord.EmbedFooter{Text: "Not for therapy. Confirming only logs the dose."},
+		Color:  discord.Color(WarnLevel3),
+	}
+}
+
+// bolusButton confirms the suggested dose, logging it for uid.
+func bolusButton(uid discord.UserID, bs *BolusSuggestion, now time.Time) (discord.ContainerComponents, error) {
+	id := strings.Join([]string{
+		bolusButtonPrefix,
+		uid.String(),
+		strconv.FormatInt(now.Unix(), 10),
+		strconv.FormatFloat(bs.Total, 'f', -1, 64),
+		bs.Product,
+	}, ":")
+	if len(id) > maxCustomIDLength {
+		return nil, fmt.Errorf("insulin product name is too long to confirm: %s", bs.Product)
+	}
+
+	return discord.ContainerComponents{
+		&discord.ActionRowComponent{
+			&discord.ButtonComponent{
+				Style:    discord.SuccessButtonStyle(),
+				CustomID: discord.ComponentID(id),
+				Label:    "Log " + insulinToString(bs.Total) + " " + bs.Product,
+			},
+		},
+	}, nil
+}
+
+func bolusResponse(opts []discord.CommandInteractionOption, uid discord.UserID, sto *store.Store) (*api.InteractionResponseData, error) {
+	optsMap := getAllOptions(opts)
+
+	var conf store.Config
+	if err := sto.GetObject(store.IndexConfig, &conf); err != nil {
+		return nil, fmt.Errorf("unable to load config: %w", err)
+	}
+
+	carbs, err := strconv.Atoi(optsMap["carbs"])
+	if err != nil {
+		return nil, fmt.Errorf("invalid carbs: %w", err)
+	}
+
+	var glucose float64
+	if v, ok := optsMap["glucose"]; ok {
+		g, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid glucose: %w", err)
+		}
+		glucose = fromUnits(g, conf.Units)
+	}
+
+	bs, err := suggestBolus(sto, carbs, glucose, optsMap["product"])
+	if err != nil {
+		return nil, err
+	}
+
+	data := &api.InteractionResponseData{
+		Embeds: &[]discord.Embed{bolusEmbed(bs, conf.Units)},
+	}
+	if bs.Total > 0 {
+		components, err := bolusButton(uid, bs, time.Now())
+		if err != nil {
+			return nil, err
+		}
+		data.Components = &components
+	}
+	return data, nil
+}
+
+// confirmBolus logs the dose of a bolus confirm button clicked by uid.
+func confirmBolus(root *store.Store, uid discord.UserID, id discord.ComponentID) (*discord.Embed, error) {
+	parts := strings.SplitN(string(id), ":", 5)
+	if len(parts) != 5 || parts[0] != bolusButtonPrefix {
+		return nil, fmt.Errorf("unknown button: %s", id)
+	}
+	if parts[1] != uid.String() {
+		return nil, fmt.Errorf("only the user who asked for this suggestion can confirm it")
+	}
+
+	unix, err := strconv.ParseInt(parts[2], 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid suggestion time: %w", err)
+	}
+	if time.Since(time.Unix(unix, 0)) > bolusExpiry {
+		return nil, fmt.Errorf("this suggestion is more than %d minutes old, ask for a new one with /bolus",
+			int(bolusExpiry.Minutes()))
+	}
+
+	sto, err := registeredStore(root, uid)
+	if err != nil {
+		return nil, err
+	}
+
+	units, err := strconv.ParseFloat(parts[3], 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid units: %w", err)
+	}
+
+	products, err := getProducts(sto)
+	if err != nil {
+		return nil, err
+	}
+	p, err := store.FindInsulinProduct(products, parts[4])
+	if err != nil {
+		return nil, err
+	}
+
+	ir, err := addInsulin(*p, units, 0, sto)
+	if err != nil {
+		return nil, err
+	}
+
+	return &discord.Embed{
+		Title: "Bolus Logged",
+		Fields: []discord.EmbedField{
+			{Name: "Units", Value: insulinToString(ir.Units), Inline: true},
+			{Name: "Product", Value: ir.Product, Inline: true},
+			{Name: "Time", Value: ir.Time.Format("Jan 02 15:04:05"), Inline: true},
+		},
+		Footer: &discord.EmbedFooter{Text: "Not for therapy."},
+		Color:  discord.Color(WarnLevel1),
+	}, nil
+}
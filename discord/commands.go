@@ -3,8 +3,11 @@ package discord
 import (
 	"fmt"
 	"strconv"
+	"strings"
 	"time"
 
+	"github.com/algao1/ichor/glucose/metrics"
+	"github.com/algao1/ichor/glucose/onboard"
 	"github.com/algao1/ichor/store"
 	"github.com/diamondburned/arikawa/v3/api"
 	"github.com/diamondburned/arikawa/v3/discord"
@@ -13,7 +16,6 @@ import (
 	"github.com/diamondburned/arikawa/v3/utils/json/option"
 	"github.com/diamondburned/arikawa/v3/utils/sendpart"
 	"go.uber.org/zap"
-	"gonum.org/v1/gonum/stat"
 )
 
 const (
@@ -24,6 +26,8 @@ const (
 	WarnLevel5 = 13382400 // #cc3300
 )
 
+const glucoseChartName = "glucoseChart.png"
+
 var defaultFooter = discord.EmbedFooter{
 	Text: "This bot is still under construction.",
 }
@@ -38,6 +42,9 @@ var registeredCommands = []api.CreateCommandData{
 	{
 		Name:        "glucose",
 		Description: "Get the current glucose value.",
+		Options: discord.CommandOptions{
+			patientOption,
+		},
 	},
 	{
 		Name:        "weekly",
@@ -49,6 +56,7 @@ var registeredCommands = []api.CreateCommandData{
 				Min:         option.ZeroInt,
 				Required:    true,
 			},
+			patientOption,
 		},
 	},
 	{
@@ -67,25 +75,44 @@ var registeredCommands = []api.CreateCommandData{
 				Min:         option.ZeroInt,
 				Required:    false,
 			},
+			&discord.StringOption{
+				OptionName:  "gi",
+				Description: "Glycemic index of the carbohydrates.",
+				Choices: []discord.StringChoice{
+					{Name: store.GIFast, Value: store.GIFast},
+					{Name: store.GIMedium, Value: store.GIMedium},
+					{Name: store.GISlow, Value: store.GISlow},
+				},
+			},
+			&discord.IntegerOption{
+				OptionName:  "fat",
+				Description: "Amount of fat (grams).",
+				Min:         option.ZeroInt,
+			},
+			&discord.IntegerOption{
+				OptionName:  "protein",
+				Description: "Amount of protein (grams).",
+				Min:         option.ZeroInt,
+			},
+			&discord.StringOption{
+				OptionName:  "tags",
+				Description: "Comma-separated tags, e.g. breakfast,snack.",
+			},
+			&discord.StringOption{
+				OptionName:  "notes",
+				Description: "Free-text notes.",
+			},
 		},
 	},
 	{
 		Name:        "insulin",
 		Description: "Insert the amount of insulin taken.",
 		Options: discord.CommandOptions{
-			&discord.StringOption{
-				OptionName:  "type",
-				Description: "Type of insulin",
-				Choices: []discord.StringChoice{
-					{Name: store.RapidActing, Value: store.RapidActing},
-					{Name: store.LongActing, Value: store.LongActing},
-				},
-				Required: true,
-			},
-			&discord.IntegerOption{
+			productOption,
+			&discord.NumberOption{
 				OptionName:  "units",
-				Description: "Units of insulin.",
-				Min:         option.ZeroInt,
+				Description: "Units of insulin, e.g. 2.5.",
+				Min:         option.ZeroFloat,
 				Required:    true,
 			},
 			&discord.IntegerOption{
@@ -96,16 +123,35 @@ var registeredCommands = []api.CreateCommandData{
 			},
 		},
 	},
+	agpCommand,
+	bolusCommand,
+	devicesCommand,
+	episodesCommand,
+	eventCommand,
+	logCommand,
+	mealCommand,
+	mealsCommand,
+	patternsCommand,
+	productsCommand,
+	profileCommand,
+	reportCommand,
+	settingsCommand,
+	shareCommand,
 }
 
 type GlucoseReport struct {
 	Description string
+	Units       string
 
 	// Current and future value.
 	Value     float64
 	Trend     store.Trend
 	Predicted float64
 
+	// Insulin (units) and carbohydrates (grams) on board.
+	IOB float64
+	COB float64
+
 	// 12h overview.
 	Mean           float64
 	Std            float64
@@ -118,6 +164,7 @@ type GlucoseReport struct {
 
 type WeeklyReport struct {
 	Description string
+	Units       string
 
 	// Weekly overview.
 	TimeInRange    float64
@@ -129,14 +176,14 @@ type WeeklyReport struct {
 }
 
 type CarbohydrateResponse struct {
-	Value int
-	Time  time.Time
+	store.Carbohydrate
 }
 
 type InsulinResponse struct {
-	Type  string
-	Units int
-	Time  time.Time
+	Type    string
+	Product string
+	Units   float64
+	Time    time.Time
 }
 
 func sendWarnMessage(ses *session.Session, cid discord.ChannelID, desc string) {
@@ -170,7 +217,7 @@ func getAllOptions(opts []discord.CommandInteractionOption) map[string]string {
 	return optsMap
 }
 
-func interactionCreate(ses *session.Session, sto *store.Store, logger *zap.Logger) func(e *gateway.InteractionCreateEvent) {
+func interactionCreate(ses *session.Session, root *store.Store, logger *zap.Logger) func(e *gateway.InteractionCreateEvent) {
 	return func(e *gateway.InteractionCreateEvent) {
 		var resp api.InteractionResponse
 
@@ -182,6 +229,16 @@ func interactionCreate(ses *session.Session, sto *store.Store, logger *zap.Logge
 
 		switch data := e.Data.(type) {
 		case *discord.CommandInteraction:
+			sto, err := commandStore(root, e.SenderID(), data)
+			if err != nil {
+				logger.Info("rejected interaction",
+					zap.Any("user id", e.SenderID()),
+					zap.Error(err),
+				)
+				resp = interactionWarnResponse(err.Error())
+				break
+			}
+
 			switch data.Name {
 			case "glucose":
 				gr, err := glucoseReport(sto)
@@ -203,17 +260,21 @@ func interactionCreate(ses *session.Session, sto *store.Store, logger *zap.Logge
 								Image:       &discord.EmbedImage{URL: "attachment://" + gr.Chart.Name},
 								Fields: []discord.EmbedField{
 									// Line 1.
-									{Name: "Current", Value: floatToString(gr.Value), Inline: true},
+									{Name: "Current", Value: glucoseToString(gr.Value, gr.Units), Inline: true},
 									{Name: "Trend", Value: "\\" + trendToString(gr.Trend), Inline: true},
-									{Name: "Predicted", Value: floatToString(gr.Predicted), Inline: true},
+									{Name: "Predicted", Value: glucoseToString(gr.Predicted, gr.Units), Inline: true},
 									// Line 2.
-									{Name: "Mean", Value: floatToString(gr.Mean), Inline: true},
-									{Name: "Std Dev", Value: floatToString(gr.Std), Inline: true},
+									{Name: "Mean", Value: glucoseToString(gr.Mean, gr.Units), Inline: true},
+									{Name: "Std Dev", Value: glucoseToString(gr.Std, gr.Units), Inline: true},
 									inlineBlankField,
 									// Line 3.
 									{Name: "In Range", Value: floatToString(gr.TimeInRange), Inline: true},
 									{Name: "Below Range", Value: floatToString(gr.TimeBelowRange), Inline: true},
 									{Name: "Above Range", Value: floatToString(gr.TimeAboveRange), Inline: true},
+									// Line 4.
+									{Name: "Insulin On Board", Value: fmt.Sprintf("%.2f units", gr.IOB), Inline: true},
+									{Name: "Carbs On Board", Value: fmt.Sprintf("%.0f grams", gr.COB), Inline: true},
+									inlineBlankField,
 								},
 								Footer: &defaultFooter,
 								Color:  discord.Color(WarnLevel1),
@@ -223,13 +284,13 @@ func interactionCreate(ses *session.Session, sto *store.Store, logger *zap.Logge
 					},
 				}
 			case "weekly":
-				n, err := data.Options[0].IntValue()
+				n, err := strconv.Atoi(getAllOptions(data.Options)["offset"])
 				if err != nil {
 					resp = interactionWarnResponse(err.Error())
 					break
 				}
 
-				wr, err := weeklyReport(int(n), sto)
+				wr, err := weeklyReport(n, sto)
 				if err != nil {
 					logger.Info("failed to get weekly report",
 						zap.Error(err),
@@ -259,25 +320,86 @@ func interactionCreate(ses *session.Session, sto *store.Store, logger *zap.Logge
 						Files: []sendpart.File{wr.Chart},
 					},
 				}
-			case "carbohydrates":
-				var val, offset int
+			case "agp":
+				rd, err := agpResponse(data.Options, sto)
+				if err != nil {
+					logger.Info("failed to get AGP report",
+						zap.Error(err),
+					)
+					resp = interactionWarnResponse(err.Error())
+					break
+				}
 
-				optsMap := getAllOptions(data.Options)
-				val, err := strconv.Atoi(optsMap["amount"])
+				resp = api.InteractionResponse{
+					Type: api.MessageInteractionWithSource,
+					Data: rd,
+				}
+			case "meals":
+				rd, err := mealsResponse(data.Options, sto)
 				if err != nil {
+					logger.Info("failed to get meal responses",
+						zap.Error(err),
+					)
 					resp = interactionWarnResponse(err.Error())
 					break
 				}
 
-				if off, ok := optsMap["offset"]; ok {
-					offset, err = strconv.Atoi(off)
-					if err != nil {
-						resp = interactionWarnResponse(err.Error())
-						break
-					}
+				resp = api.InteractionResponse{
+					Type: api.MessageInteractionWithSource,
+					Data: rd,
+				}
+			case "episodes":
+				rd, err := episodesResponse(data.Options, sto)
+				if err != nil {
+					logger.Info("failed to get low episodes",
+						zap.Error(err),
+					)
+					resp = interactionWarnResponse(err.Error())
+					break
+				}
+
+				resp = api.InteractionResponse{
+					Type: api.MessageInteractionWithSource,
+					Data: rd,
+				}
+			case "patterns":
+				rd, err := patternsResponse(data.Options, sto)
+				if err != nil {
+					logger.Info("failed to get patterns",
+						zap.Error(err),
+					)
+					resp = interactionWarnResponse(err.Error())
+					break
+				}
+
+				resp = api.InteractionResponse{
+					Type: api.MessageInteractionWithSource,
+					Data: rd,
+				}
+			case "report":
+				embed, err := reportResponse(data.Options, sto)
+				if err != nil {
+					logger.Info("failed to get report",
+						zap.Error(err),
+					)
+					resp = interactionWarnResponse(err.Error())
+					break
+				}
+
+				resp = api.InteractionResponse{
+					Type: api.MessageInteractionWithSource,
+					Data: &api.InteractionResponseData{
+						Embeds: &[]discord.Embed{*embed},
+					},
+				}
+			case "carbohydrates":
+				carb, offset, err := parseCarbohydrate(getAllOptions(data.Options))
+				if err != nil {
+					resp = interactionWarnResponse(err.Error())
+					break
 				}
 
-				cr, err := addCarbohydrate(val, offset, sto)
+				cr, err := addCarbohydrate(carb, offset, sto)
 				if err != nil {
 					logger.Info("failed to add carbohydrate intake",
 						zap.Error(err),
@@ -291,10 +413,7 @@ func interactionCreate(ses *session.Session, sto *store.Store, logger *zap.Logge
 					Data: &api.InteractionResponseData{
 						Embeds: &[]discord.Embed{
 							{
-								Fields: []discord.EmbedField{
-									{Name: "Amount", Value: strconv.Itoa(val) + " grams", Inline: true},
-									{Name: "Time", Value: cr.Time.In(loc).Format("Jan 02 15:04:05"), Inline: true},
-								},
+								Fields: carbohydrateFields(cr),
 								Footer: &defaultFooter,
 								Color:  discord.Color(WarnLevel1),
 							},
@@ -302,12 +421,11 @@ func interactionCreate(ses *session.Session, sto *store.Store, logger *zap.Logge
 					},
 				}
 			case "insulin":
-				var insulin string
-				var units, offset int
+				var offset int
 
 				optsMap := getAllOptions(data.Options)
 
-				units, err := strconv.Atoi(optsMap["units"])
+				units, err := strconv.ParseFloat(optsMap["units"], 64)
 				if err != nil {
 					resp = interactionWarnResponse(err.Error())
 					break
@@ -321,9 +439,18 @@ func interactionCreate(ses *session.Session, sto *store.Store, logger *zap.Logge
 					}
 				}
 
-				insulin = optsMap["type"]
+				products, err := getProducts(sto)
+				if err != nil {
+					resp = interactionWarnResponse(err.Error())
+					break
+				}
+				i := findProduct(products, optsMap["product"])
+				if i < 0 {
+					resp = interactionWarnResponse("unknown insulin product: " + optsMap["product"])
+					break
+				}
 
-				ir, err := addInsulin(insulin, units, offset, sto)
+				ir, err := addInsulin(products[i], units, offset, sto)
 				if err != nil {
 					logger.Info("failed to add insulin intake",
 						zap.Error(err),
@@ -338,8 +465,9 @@ func interactionCreate(ses *session.Session, sto *store.Store, logger *zap.Logge
 						Embeds: &[]discord.Embed{
 							{
 								Fields: []discord.EmbedField{
-									{Name: "Units", Value: strconv.Itoa(ir.Units) + " units", Inline: true},
-									{Name: "Time", Value: ir.Time.In(loc).Format("Jan 02 15:04:05"), Inline: true},
+									{Name: "Units", Value: insulinToString(ir.Units), Inline: true},
+									{Name: "Product", Value: ir.Product, Inline: true},
+									{Name: "Time", Value: ir.Time.Format("Jan 02 15:04:05"), Inline: true},
 								},
 								Footer: &defaultFooter,
 								Color:  discord.Color(WarnLevel1),
@@ -347,6 +475,189 @@ func interactionCreate(ses *session.Session, sto *store.Store, logger *zap.Logge
 						},
 					},
 				}
+			case "settings":
+				conf, err := settingsResponse(data.Options, sto)
+				if err != nil {
+					logger.Info("failed to handle settings",
+						zap.Error(err),
+					)
+					resp = interactionWarnResponse(err.Error())
+					break
+				}
+
+				resp = api.InteractionResponse{
+					Type: api.MessageInteractionWithSource,
+					Data: &api.InteractionResponseData{
+						Embeds: &[]discord.Embed{settingsEmbed(conf)},
+					},
+				}
+			case "share":
+				u, err := shareResponse(data.Options, root, e.SenderID())
+				if err != nil {
+					logger.Info("failed to handle share",
+						zap.Error(err),
+					)
+					resp = interactionWarnResponse(err.Error())
+					break
+				}
+
+				resp = api.InteractionResponse{
+					Type: api.MessageInteractionWithSource,
+					Data: &api.InteractionResponseData{
+						Embeds: &[]discord.Embed{shareEmbed(u)},
+					},
+				}
+			case "log":
+				embed, err := logResponse(data.Options, sto)
+				if err != nil {
+					logger.Info("failed to handle log",
+						zap.Error(err),
+					)
+					resp = interactionWarnResponse(err.Error())
+					break
+				}
+
+				resp = api.InteractionResponse{
+					Type: api.MessageInteractionWithSource,
+					Data: &api.InteractionResponseData{
+						Embeds: &[]discord.Embed{*embed},
+					},
+				}
+			case "devices":
+				embed, err := devicesResponse(data.Options, sto)
+				if err != nil {
+					logger.Info("failed to handle devices",
+						zap.Error(err),
+					)
+					resp = interactionWarnResponse(err.Error())
+					break
+				}
+
+				resp = api.InteractionResponse{
+					Type: api.MessageInteractionWithSource,
+					Data: &api.InteractionResponseData{
+						Embeds: &[]discord.Embed{*embed},
+					},
+				}
+			case "event":
+				embed, err := eventResponse(data.Options, sto)
+				if err != nil {
+					logger.Info("failed to add event",
+						zap.Error(err),
+					)
+					resp = interactionWarnResponse(err.Error())
+					break
+				}
+
+				resp = api.InteractionResponse{
+					Type: api.MessageInteractionWithSource,
+					Data: &api.InteractionResponseData{
+						Embeds: &[]discord.Embed{*embed},
+					},
+				}
+			case "meal":
+				embed, err := mealResponse(data.Options, sto)
+				if err != nil {
+					logger.Info("failed to handle meal",
+						zap.Error(err),
+					)
+					resp = interactionWarnResponse(err.Error())
+					break
+				}
+
+				resp = api.InteractionResponse{
+					Type: api.MessageInteractionWithSource,
+					Data: &api.InteractionResponseData{
+						Embeds: &[]discord.Embed{*embed},
+					},
+				}
+			case "profile":
+				embed, err := profileResponse(data.Options, sto)
+				if err != nil {
+					logger.Info("failed to handle profile",
+						zap.Error(err),
+					)
+					resp = interactionWarnResponse(err.Error())
+					break
+				}
+
+				resp = api.InteractionResponse{
+					Type: api.MessageInteractionWithSource,
+					Data: &api.InteractionResponseData{
+						Embeds: &[]discord.Embed{*embed},
+					},
+				}
+			case "bolus":
+				rd, err := bolusResponse(data.Options, e.SenderID(), sto)
+				if err != nil {
+					logger.Info("failed to suggest bolus",
+						zap.Error(err),
+					)
+					resp = interactionWarnResponse(err.Error())
+					break
+				}
+
+				resp = api.InteractionResponse{
+					Type: api.MessageInteractionWithSource,
+					Data: rd,
+				}
+			case "products":
+				embed, err := productsResponse(data.Options, sto)
+				if err != nil {
+					logger.Info("failed to handle insulin products",
+						zap.Error(err),
+					)
+					resp = interactionWarnResponse(err.Error())
+					break
+				}
+
+				resp = api.InteractionResponse{
+					Type: api.MessageInteractionWithSource,
+					Data: &api.InteractionResponseData{
+						Embeds: &[]discord.Embed{*embed},
+					},
+				}
+			}
+		case *discord.AutocompleteInteraction:
+			choices, err := autocomplete(root, e.SenderID(), data)
+			if err != nil {
+				logger.Info("failed to autocomplete",
+					zap.String("command", data.Name),
+					zap.Error(err),
+				)
+				choices = []api.AutocompleteChoice{}
+			}
+
+			resp = api.InteractionResponse{
+				Type: api.AutocompleteResult,
+				Data: &api.InteractionResponseData{Choices: &choices},
+			}
+		case *discord.ButtonInteraction:
+			// Buttons are told apart by the prefix of their custom ID.
+			switch strings.SplitN(string(data.CustomID), ":", 2)[0] {
+			case bolusButtonPrefix:
+				embed, err := confirmBolus(root, e.SenderID(), data.CustomID)
+				if err != nil {
+					logger.Info("failed to confirm bolus",
+						zap.Error(err),
+					)
+					resp = interactionWarnResponse(err.Error())
+					break
+				}
+
+				// Replace the suggestion, so that the dose cannot be logged twice.
+				resp = api.InteractionResponse{
+					Type: api.UpdateMessage,
+					Data: &api.InteractionResponseData{
+						Embeds:     &[]discord.Embed{*embed},
+						Components: &discord.ContainerComponents{},
+					},
+				}
+			default:
+				logger.Info("unknown button",
+					zap.String("id", string(data.CustomID)),
+				)
+				resp = interactionWarnResponse("unknown button")
 			}
 		}
 
@@ -358,6 +669,33 @@ func interactionCreate(ses *session.Session, sto *store.Store, logger *zap.Logge
 	}
 }
 
+// autocomplete returns the choices for the focused option of a command.
+func autocomplete(root *store.Store, uid discord.UserID, data *discord.AutocompleteInteraction) ([]api.AutocompleteChoice, error) {
+	sto, err := registeredStore(root, uid)
+	if err != nil {
+		return nil, err
+	}
+
+	focused := focusedOption(data.Options)
+	if focused == nil {
+		return nil, fmt.Errorf("no focused option")
+	}
+
+	switch data.Name {
+	case "log":
+		return logAutocomplete(sto, focused.Value)
+	case "bolus", "insulin", "products":
+		return productAutocomplete(sto, focused.Value)
+	case "meal":
+		if focused.Name == "insulin" {
+			return productAutocomplete(sto, focused.Value)
+		}
+		return mealAutocomplete(sto, focused.Value)
+	default:
+		return nil, fmt.Errorf("unknown command: %s", data.Name)
+	}
+}
+
 func glucoseReport(sto *store.Store) (*GlucoseReport, error) {
 	start := time.Now().Add(-12 * time.Hour)
 	end := time.Now()
@@ -390,12 +728,21 @@ func glucoseReport(sto *store.Store) (*GlucoseReport, error) {
 		return nil, fmt.Errorf("unable to get insulin doses: %w", err)
 	}
 
+	// Get events.
+	var events []store.Event
+	err = sto.GetPoints(start, end, store.FieldEvent, &events)
+	if err != nil {
+		return nil, fmt.Errorf("unable to get events: %w", err)
+	}
+
 	var conf store.Config
 	if err = sto.GetObject(store.IndexConfig, &conf); err != nil {
 		return nil, fmt.Errorf("unable to load config: %w", err)
 	}
 
-	r, err := PlotRecentAndPreds(conf.LowThreshold, conf.HighThreshold, pts, preds, carbs, insulin)
+	loc := conf.Location()
+
+	r, err := PlotRecentAndPreds(conf.LowThreshold, conf.HighThreshold, conf.Units, loc, pts, preds, carbs, insulin, events)
 	if err != nil {
 		return nil, fmt.Errorf("unable to generate daily graph: %w", err)
 	}
@@ -406,45 +753,40 @@ func glucoseReport(sto *store.Store) (*GlucoseReport, error) {
 		predPt = preds[len(preds)-1]
 	}
 
-	total := float64(len(pts))
-	var within, below, above float64
-
-	x := make([]float64, len(pts))
-	for i, pt := range pts {
-		x[i] = pt.Value
-
-		if pt.Value < conf.LowThreshold {
-			below++
-		} else if pt.Value > conf.HighThreshold {
-			above++
-		} else {
-			within++
-		}
+	ob, err := onboard.New(sto, end, end)
+	if err != nil {
+		return nil, err
 	}
 
-	mean := stat.Mean(x, nil)
-	std := stat.StdDev(x, nil)
-
 	return &GlucoseReport{
 		Description: fmt.Sprintf("%s - %s",
 			start.In(loc).Format("Jan 02 15:04:05"),
 			end.In(loc).Format("Jan 02 15:04:05"),
 		),
+		Units:          conf.Units,
 		Value:          curPt.Value,
 		Trend:          curPt.Trend,
 		Predicted:      predPt.Value,
-		Mean:           mean,
-		Std:            std,
-		TimeInRange:    within / total,
-		TimeBelowRange: below / total,
-		TimeAboveRange: above / total,
-		Chart:          sendpart.File{Name: "glucoseChart.png", Reader: r},
+		IOB:            ob.IOB(end),
+		COB:            ob.COB(end),
+		Mean:           metrics.Mean(pts),
+		Std:            metrics.StdDev(pts),
+		TimeInRange:    metrics.TimeInRange(pts, conf.LowThreshold, conf.HighThreshold),
+		TimeBelowRange: metrics.TimeBelow(pts, conf.LowThreshold),
+		TimeAboveRange: metrics.TimeAbove(pts, conf.HighThreshold),
+		Chart:          sendpart.File{Name: glucoseChartName, Reader: r},
 	}, nil
 }
 
 func weeklyReport(offset int, sto *store.Store) (*WeeklyReport, error) {
+	var conf store.Config
+	if err := sto.GetObject(store.IndexConfig, &conf); err != nil {
+		return nil, fmt.Errorf("unable to load config: %w", err)
+	}
+	loc := conf.Location()
+
 	t := time.Now().In(loc).AddDate(0, 0, -7*offset)
-	ws := weekStart(t)
+	ws := weekStart(t, loc)
 	we := ws.AddDate(0, 0, 7)
 
 	var pts []store.TimePoint
@@ -460,83 +802,128 @@ func weeklyReport(offset int, sto *store.Store) (*WeeklyReport, error) {
 		return nil, fmt.Errorf("unable to get last week's points: %w", err)
 	}
 
-	var conf store.Config
-	if err = sto.GetObject(store.IndexConfig, &conf); err != nil {
-		return nil, fmt.Errorf("unable to load config: %w", err)
-	}
-
-	r, err := PlotOverlayWeekly(conf.LowThreshold, conf.HighThreshold, pts)
+	r, err := PlotOverlayWeekly(conf.LowThreshold, conf.HighThreshold, conf.Units, loc, pts)
 	if err != nil {
 		return nil, fmt.Errorf("unable to generate weekly plot: %w", err)
 	}
 
-	total := float64(len(pts))
-	var within, below, above float64
-
-	x := make([]float64, len(pts))
-	for i, pt := range pts {
-		x[i] = pt.Value
-
-		if pt.Value < conf.LowThreshold {
-			below++
-		} else if pt.Value > conf.HighThreshold {
-			above++
-		} else {
-			within++
-		}
-	}
-
-	lwTotal := float64(len(lwPts))
-	var lwWithin float64
-	for _, pt := range lwPts {
-		if pt.Value >= conf.LowThreshold && pt.Value <= conf.HighThreshold {
-			lwWithin++
-		}
-	}
+	within := metrics.TimeInRange(pts, conf.LowThreshold, conf.HighThreshold)
+	lwWithin := metrics.TimeInRange(lwPts, conf.LowThreshold, conf.HighThreshold)
 
 	return &WeeklyReport{
 		Description: fmt.Sprintf("%s - %s",
 			ws.In(loc).Format("Mon, 02 Jan 2006"),
 			ws.AddDate(0, 0, 6).In(loc).Format("Mon, 02 Jan 2006"),
 		),
-		TimeInRange:    within / total,
-		TimeBelowRange: below / total,
-		TimeAboveRange: above / total,
-		WeeklyChange:   within/total - lwWithin/lwTotal,
+		Units:          conf.Units,
+		TimeInRange:    within,
+		TimeBelowRange: metrics.TimeBelow(pts, conf.LowThreshold),
+		TimeAboveRange: metrics.TimeAbove(pts, conf.HighThreshold),
+		WeeklyChange:   within - lwWithin,
 		Chart:          sendpart.File{Name: "weeklyOverlay.png", Reader: r},
 	}, nil
 }
 
-func addCarbohydrate(val, offset int, sto *store.Store) (*CarbohydrateResponse, error) {
-	when := time.Now().In(loc).Add(-time.Duration(offset) * time.Minute)
-	err := sto.AddPoint(store.FieldCarbohydrate, when, store.Carbohydrate{
-		Time:  when,
-		Value: val,
-	})
+// parseCarbohydrate reads a carbohydrate intake and its offset in minutes
+// from the /carbohydrates options.
+func parseCarbohydrate(optsMap map[string]string) (store.Carbohydrate, int, error) {
+	var carb store.Carbohydrate
+	var offset int
+	var err error
+
+	carb.Value, err = strconv.Atoi(optsMap["amount"])
+	if err != nil {
+		return carb, 0, fmt.Errorf("invalid amount: %w", err)
+	}
+
+	ints := map[string]*int{"offset": &offset, "fat": &carb.Fat, "protein": &carb.Protein}
+	for name, dst := range ints {
+		if v, ok := optsMap[name]; ok {
+			if *dst, err = strconv.Atoi(v); err != nil {
+				return carb, 0, fmt.Errorf("invalid %s: %w", name, err)
+			}
+		}
+	}
+
+	carb.GI = optsMap["gi"]
+	carb.Notes = strings.TrimSpace(optsMap["notes"])
+	carb.Tags = parseTags(optsMap["tags"])
+
+	return carb, offset, nil
+}
+
+// parseTags splits a comma-separated list of tags, normalizing them to
+// lowercase and dropping empty ones.
+func parseTags(s string) []string {
+	var tags []string
+	for _, tag := range strings.Split(s, ",") {
+		tag = strings.ToLower(strings.TrimSpace(tag))
+		if tag != "" {
+			tags = append(tags, tag)
+		}
+	}
+	return tags
+}
+
+func carbohydrateFields(cr *CarbohydrateResponse) []discord.EmbedField {
+	fields := []discord.EmbedField{
+		{Name: "Amount", Value: strconv.Itoa(cr.Value) + " grams", Inline: true},
+		{Name: "Time", Value: cr.Time.Format("Jan 02 15:04:05"), Inline: true},
+	}
+	if cr.GI != "" {
+		fields = append(fields, discord.EmbedField{Name: "Glycemic Index", Value: cr.GI, Inline: true})
+	}
+	if cr.Fat > 0 {
+		fields = append(fields, discord.EmbedField{Name: "Fat", Value: strconv.Itoa(cr.Fat) + " grams", Inline: true})
+	}
+	if cr.Protein > 0 {
+		fields = append(fields, discord.EmbedField{Name: "Protein", Value: strconv.Itoa(cr.Protein) + " grams", Inline: true})
+	}
+	if len(cr.Tags) > 0 {
+		fields = append(fields, discord.EmbedField{Name: "Tags", Value: strings.Join(cr.Tags, ", "), Inline: true})
+	}
+	if cr.Notes != "" {
+		fields = append(fields, discord.EmbedField{Name: "Notes", Value: cr.Notes})
+	}
+	return fields
+}
+
+func addCarbohydrate(carb store.Carbohydrate, offset int, sto *store.Store) (*CarbohydrateResponse, error) {
+	var conf store.Config
+	if err := sto.GetObject(store.IndexConfig, &conf); err != nil {
+		return nil, fmt.Errorf("unable to load config: %w", err)
+	}
+
+	carb.Time = time.Now().In(conf.Location()).Add(-time.Duration(offset) * time.Minute)
+	err := sto.AddPoint(store.FieldCarbohydrate, carb.Time, carb)
 	if err != nil {
 		return nil, err
 	}
 
-	return &CarbohydrateResponse{
-		Value: val,
-		Time:  when,
-	}, nil
+	return &CarbohydrateResponse{carb}, nil
 }
 
-func addInsulin(insulin string, units, offset int, sto *store.Store) (*InsulinResponse, error) {
-	when := time.Now().In(loc).Add(-time.Duration(offset) * time.Minute)
+func addInsulin(product store.InsulinProduct, units float64, offset int, sto *store.Store) (*InsulinResponse, error) {
+	var conf store.Config
+	if err := sto.GetObject(store.IndexConfig, &conf); err != nil {
+		return nil, fmt.Errorf("unable to load config: %w", err)
+	}
+
+	when := time.Now().In(conf.Location()).Add(-time.Duration(offset) * time.Minute)
 	err := sto.AddPoint(store.FieldInsulin, when, store.Insulin{
-		Time:  when,
-		Type:  insulin,
-		Value: units,
+		Time:    when,
+		Type:    product.Category,
+		Product: product.Name,
+		Value:   units,
 	})
 	if err != nil {
 		return nil, err
 	}
 
 	return &InsulinResponse{
-		Type:  insulin,
-		Time:  when,
-		Units: units,
+		Type:    product.Category,
+		Product: product.Name,
+		Time:    when,
+		Units:   units,
 	}, nil
 }
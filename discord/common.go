@@ -1,27 +1,76 @@
 package discord
 
 import (
+	"fmt"
 	"strconv"
 	"time"
 
+	"github.com/algao1/ichor/glucose/patterns"
+	"github.com/algao1/ichor/glucose/report"
 	"github.com/algao1/ichor/store"
+	"github.com/diamondburned/arikawa/v3/discord"
 )
 
-type Alert int
+type AlertType int
 
 const (
-	Low Alert = iota
+	Low AlertType = iota
 	High
+	UrgentLow
 )
 
-var loc, _ = time.LoadLocation("Canada/Eastern")
+// Alert is a glucose alert for a registered user, raised by the predicted
+// point that crossed a threshold. IOB and COB are the insulin and carbs on
+// board when it was raised.
+type Alert struct {
+	UserID string
+	Type   AlertType
+	Point  store.TimePoint
+	IOB    float64
+	COB    float64
+}
+
+// Reminder is a message for a registered user that is not about their
+// glucose, such as a sensor about to expire. It is not forwarded to
+// followers.
+type Reminder struct {
+	UserID  string
+	Message string
+}
+
+// Digest is a scheduled report for a registered user, with the report of
+// the period before it for comparison, and any patterns found.
+type Digest struct {
+	UserID   string
+	Title    string
+	Report   *report.Report
+	Previous *report.Report
+	Findings []patterns.Finding
+}
+
+// subscribed reports whether a follower with the given subscription should
+// receive an alert of type t.
+func subscribed(alerts string, t AlertType) bool {
+	switch alerts {
+	case store.AlertsAll:
+		return true
+	case store.AlertsLows:
+		return t == Low || t == UrgentLow
+	case store.AlertsUrgent:
+		return t == UrgentLow
+	default:
+		return false
+	}
+}
 
 const (
 	TimeFormat = "2006-01-02 03:04 PM"
 	HourFormat = "3 PM"
 )
 
-func weekStart(t time.Time) time.Time {
+// weekStart returns midnight of the Monday starting the week of t, in loc.
+func weekStart(t time.Time, loc *time.Location) time.Time {
+	t = t.In(loc)
 	rounded := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, loc)
 	if wd := rounded.Weekday(); wd == time.Sunday {
 		rounded = rounded.AddDate(0, 0, -6)
@@ -31,16 +80,99 @@ func weekStart(t time.Time) time.Time {
 	return rounded
 }
 
-func daySeconds(t time.Time) int {
-	year, month, day := t.Date()
-	t2 := time.Date(year, month, day, 0, 0, 0, 0, loc)
-	return int(t.Sub(t2).Seconds())
+// daySeconds returns the number of seconds since midnight on the wall clock
+// in loc. Unlike the elapsed time, this stays aligned with the hour labels
+// on days with a DST transition.
+func daySeconds(t time.Time, loc *time.Location) int {
+	hour, min, sec := t.In(loc).Clock()
+	return hour*3600 + min*60 + sec
 }
 
-func localFormat(t time.Time) string {
+func localFormat(t time.Time, loc *time.Location) string {
 	return t.In(loc).Format(TimeFormat)
 }
 
+// toUnits converts a glucose value from mmol/L to the given units.
+func toUnits(v float64, units string) float64 {
+	if units == store.UnitMgdl {
+		return v * store.MgdlPerMmol
+	}
+	return v
+}
+
+// fromUnits converts a glucose value in the given units to mmol/L.
+func fromUnits(v float64, units string) float64 {
+	if units == store.UnitMgdl {
+		return v / store.MgdlPerMmol
+	}
+	return v
+}
+
+// glucoseToString formats a glucose value stored in mmol/L using the
+// given units, mg/dL values are shown without decimals. Negative values
+// are used as placeholders for missing values.
+func glucoseToString(v float64, units string) string {
+	if v < 0 {
+		return "-"
+	}
+	if units == store.UnitMgdl {
+		return strconv.FormatFloat(toUnits(v, units), 'f', 0, 64)
+	}
+	return floatToString(v)
+}
+
+// signedGlucoseString formats a change in glucose, given in mmol/L, using
+// the given units.
+func signedGlucoseString(v float64, units string) string {
+	prec := 2
+	if units == store.UnitMgdl {
+		prec = 0
+	}
+
+	s := strconv.FormatFloat(toUnits(v, units), 'f', prec, 64)
+	if v > 0 {
+		s = "+" + s
+	}
+	return s
+}
+
+// focusedOption returns the option being autocompleted, searching through
+// subcommands.
+func focusedOption(opts []discord.AutocompleteOption) *discord.AutocompleteOption {
+	for i := range opts {
+		if opts[i].Focused {
+			return &opts[i]
+		}
+		if opt := focusedOption(opts[i].Options); opt != nil {
+			return opt
+		}
+	}
+	return nil
+}
+
+// maxChoiceLength is the longest name or value Discord accepts for an
+// autocomplete choice. A single longer name rejects the whole response.
+const maxChoiceLength = 100
+
+// choiceName shortens an autocomplete choice name to maxChoiceLength
+// characters.
+func choiceName(name string) string {
+	if r := []rune(name); len(r) > maxChoiceLength {
+		return string(r[:maxChoiceLength-3]) + "..."
+	}
+	return name
+}
+
+// onBoardString describes the insulin and carbs on board.
+func onBoardString(iob, cob float64) string {
+	return fmt.Sprintf("IOB %.2f units, COB %.0f grams", iob, cob)
+}
+
+// insulinToString formats units of insulin without trailing zeros.
+func insulinToString(v float64) string {
+	return strconv.FormatFloat(v, 'f', -1, 64) + " units"
+}
+
 func floatToString(v float64) string {
 	return strconv.FormatFloat(v, 'f', 2, 64)
 }
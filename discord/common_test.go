@@ -0,0 +1,117 @@
+package discord
+
+import (
+	"testing"
+	"time"
+)
+
+func toronto(t *testing.T) *time.Location {
+	t.Helper()
+	loc, err := time.LoadLocation("America/Toronto")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return loc
+}
+
+func TestWeekStartDST(t *testing.T) {
+	loc := toronto(t)
+
+	tests := []struct {
+		name    string
+		t       time.Time
+		want    time.Time
+		weekLen time.Duration
+		dayLen  time.Duration
+	}{
+		{
+			name:    "spring forward day",
+			t:       time.Date(2022, 3, 13, 12, 0, 0, 0, loc),
+			want:    time.Date(2022, 3, 7, 0, 0, 0, 0, loc),
+			weekLen: 167 * time.Hour,
+			dayLen:  23 * time.Hour,
+		},
+		{
+			// 03:30 UTC is still Sunday in Toronto.
+			name:    "spring forward day in UTC",
+			t:       time.Date(2022, 3, 14, 3, 30, 0, 0, time.UTC),
+			want:    time.Date(2022, 3, 7, 0, 0, 0, 0, loc),
+			weekLen: 167 * time.Hour,
+			dayLen:  23 * time.Hour,
+		},
+		{
+			name:    "after spring forward",
+			t:       time.Date(2022, 3, 14, 0, 30, 0, 0, loc),
+			want:    time.Date(2022, 3, 14, 0, 0, 0, 0, loc),
+			weekLen: 168 * time.Hour,
+			dayLen:  24 * time.Hour,
+		},
+		{
+			name:    "fall back day",
+			t:       time.Date(2022, 11, 6, 23, 0, 0, 0, loc),
+			want:    time.Date(2022, 10, 31, 0, 0, 0, 0, loc),
+			weekLen: 169 * time.Hour,
+			dayLen:  25 * time.Hour,
+		},
+		{
+			name:    "after fall back",
+			t:       time.Date(2022, 11, 7, 0, 0, 0, 0, loc),
+			want:    time.Date(2022, 11, 7, 0, 0, 0, 0, loc),
+			weekLen: 168 * time.Hour,
+			dayLen:  24 * time.Hour,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			got := weekStart(tc.t, loc)
+			if !got.Equal(tc.want) {
+				t.Fatalf("got %s, want %s", got, tc.want)
+			}
+
+			// The next week starts at the following Monday midnight, however
+			// long the week is.
+			next := weekStart(got.AddDate(0, 0, 7), loc)
+			if l := next.Sub(got); l != tc.weekLen {
+				t.Errorf("got a week of %s, want %s", l, tc.weekLen)
+			}
+			if wd := next.Weekday(); wd != time.Monday || next.Hour() != 0 {
+				t.Errorf("got next week starting %s, want Monday midnight", next)
+			}
+
+			local := tc.t.In(loc)
+			midnight := time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, loc)
+			if l := midnight.AddDate(0, 0, 1).Sub(midnight); l != tc.dayLen {
+				t.Errorf("got a day of %s, want %s", l, tc.dayLen)
+			}
+		})
+	}
+}
+
+func TestDaySecondsDST(t *testing.T) {
+	loc := toronto(t)
+
+	tests := []struct {
+		name string
+		t    time.Time
+		want int
+	}{
+		{"before spring forward", time.Date(2022, 3, 13, 6, 59, 59, 0, time.UTC), 2*3600 - 1},
+		// Two hours after midnight, the clock reads 3:00.
+		{"spring forward", time.Date(2022, 3, 13, 7, 0, 0, 0, time.UTC), 3 * 3600},
+		{"end of 23h day", time.Date(2022, 3, 14, 3, 59, 59, 0, time.UTC), 24*3600 - 1},
+		{"first 1:30", time.Date(2022, 11, 6, 5, 30, 0, 0, time.UTC), 5400},
+		{"second 1:30", time.Date(2022, 11, 6, 6, 30, 0, 0, time.UTC), 5400},
+		// Three hours after midnight, the clock reads 2:00.
+		{"after fall back", time.Date(2022, 11, 6, 7, 0, 0, 0, time.UTC), 2 * 3600},
+		{"end of 25h day", time.Date(2022, 11, 7, 4, 59, 59, 0, time.UTC), 24*3600 - 1},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := daySeconds(tc.t, loc); got != tc.want {
+				t.Errorf("got %d, want %d", got, tc.want)
+			}
+		})
+	}
+}
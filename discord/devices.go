@@ -0,0 +1,268 @@
+package discord
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/algao1/ichor/glucose/metrics"
+	"github.com/algao1/ichor/store"
+	"github.com/diamondburned/arikawa/v3/api"
+	"github.com/diamondburned/arikawa/v3/discord"
+	"github.com/diamondburned/arikawa/v3/utils/json/option"
+)
+
+const (
+	// fingerstickTolerance is how far around a fingerstick sensor readings
+	// are looked up, the closest being compared to it.
+	fingerstickTolerance = 5 * time.Minute
+	// accuracySessions is the number of past sensors shown with their accuracy.
+	accuracySessions = 5
+)
+
+var devicesCommand = api.CreateCommandData{
+	Name:        "devices",
+	Description: "Track sensor and infusion site changes.",
+	Options: discord.CommandOptions{
+		&discord.SubcommandOption{
+			OptionName:  "view",
+			Description: "View the sensor and site age, and the accuracy of past sensors.",
+		},
+		&discord.SubcommandOption{
+			OptionName:  "change",
+			Description: "Log a sensor or site change.",
+			Options: []discord.CommandOptionValue{
+				&discord.StringOption{
+					OptionName:  "device",
+					Description: "Device that was changed.",
+					Choices: []discord.StringChoice{
+						{Name: "sensor", Value: store.EventSensorChange},
+						{Name: "site", Value: store.EventSiteChange},
+					},
+					Required: true,
+				},
+				&discord.IntegerOption{
+					OptionName:  "offset",
+					Description: "Offset in minutes.",
+					Min:         option.ZeroInt,
+				},
+			},
+		},
+		&discord.SubcommandOption{
+			OptionName:  "fingerstick",
+			Description: "Log a meter reading, used to measure the accuracy of the sensor.",
+			Options: []discord.CommandOptionValue{
+				&discord.NumberOption{
+					OptionName:  "value",
+					Description: "Meter reading, in the configured units.",
+					Min:         option.ZeroFloat,
+					Required:    true,
+				},
+				&discord.IntegerOption{
+					OptionName:  "offset",
+					Description: "Offset in minutes.",
+					Min:         option.ZeroInt,
+				},
+			},
+		},
+	},
+}
+
+// ageString formats the age of a device in days and hours.
+func ageString(d time.Duration) string {
+	days := int(d.Hours()) / 24
+	hours := int(d.Hours()) % 24
+	if days == 0 {
+		return fmt.Sprintf("%dh", hours)
+	}
+	return fmt.Sprintf("%dd %dh", days, hours)
+}
+
+// deviceAge describes how long ago the last change of the given type was,
+// and when the device expires.
+func deviceAge(sto *store.Store, conf *store.Config, eventType string) (string, error) {
+	last, err := sto.LastDeviceChange(eventType)
+	if err != nil {
+		return "", err
+	}
+	if last == nil {
+		return "No change logged yet.", nil
+	}
+
+	expiry := last.Time.Add(conf.Lifetime(eventType))
+	status := "expires " + localFormat(expiry, conf.Location())
+	if time.Now().After(expiry) {
+		status = "**expired** " + localFormat(expiry, conf.Location())
+	}
+
+	return fmt.Sprintf("%s old, %s", ageString(time.Since(last.Time)), status), nil
+}
+
+// sensorAccuracy returns the mean absolute relative difference between the
+// sensor readings and fingersticks between start and end, and the number of
+// fingersticks compared. The difference is NaN without any.
+func sensorAccuracy(sto *store.Store, start, end time.Time) (float64, int, error) {
+	var sticks []store.TimePoint
+	if err := sto.GetPoints(start, end, store.FieldFingerstick, &sticks); err != nil {
+		return 0, 0, fmt.Errorf("unable to get fingersticks: %w", err)
+	}
+
+	var sum float64
+	var n int
+	for _, stick := range sticks {
+		var pts []store.TimePoint
+		err := sto.GetPoints(stick.Time.Add(-fingerstickTolerance), stick.Time.Add(fingerstickTolerance),
+			store.FieldGlucose, &pts)
+		if err != nil {
+			return 0, 0, fmt.Errorf("unable to get points: %w", err)
+		}
+
+		v, ok := metrics.At(pts, stick.Time)
+		if !ok || stick.Value <= 0 {
+			continue
+		}
+		sum += math.Abs(v-stick.Value) / stick.Value
+		n++
+	}
+
+	if n == 0 {
+		return math.NaN(), 0, nil
+	}
+	return sum / float64(n), n, nil
+}
+
+// accuracyString lists the accuracy of the last few sensors, latest first.
+func accuracyString(sto *store.Store, loc *time.Location) (string, error) {
+	changes, err := sto.GetDeviceChanges(store.EventSensorChange)
+	if err != nil {
+		return "", err
+	}
+	if len(changes) == 0 {
+		return "No sensor changes logged yet.", nil
+	}
+
+	lines := make([]string, 0, accuracySessions)
+	for i := len(changes) - 1; i >= 0 && len(lines) < accuracySessions; i-- {
+		start := changes[i].Time
+		end := time.Now()
+		if i+1 < len(changes) {
+			end = changes[i+1].Time
+		}
+
+		mard, n, err := sensorAccuracy(sto, start, end)
+		if err != nil {
+			return "", err
+		}
+
+		accuracy := "no fingersticks"
+		if n > 0 {
+			accuracy = fmt.Sprintf("%.1f%% MARD (%d fingersticks)", mard*100, n)
+		}
+		lines = append(lines, fmt.Sprintf("%s - %s: %s",
+			start.In(loc).Format("Jan 02"), end.In(loc).Format("Jan 02"), accuracy))
+	}
+
+	return strings.Join(lines, "\n"), nil
+}
+
+func devicesResponse(opts []discord.CommandInteractionOption, sto *store.Store) (*discord.Embed, error) {
+	if len(opts) == 0 {
+		return nil, fmt.Errorf("missing subcommand")
+	}
+	optsMap := getAllOptions(opts[0].Options)
+
+	var conf store.Config
+	if err := sto.GetObject(store.IndexConfig, &conf); err != nil {
+		return nil, fmt.Errorf("unable to load config: %w", err)
+	}
+
+	var offset int
+	if v, ok := optsMap["offset"]; ok {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			return nil, fmt.Errorf("invalid offset: %w", err)
+		}
+		offset = n
+	}
+	when := time.Now().In(conf.Location()).Add(-time.Duration(offset) * time.Minute)
+
+	switch opts[0].Name {
+	case "view":
+		sensor, err := deviceAge(sto, &conf, store.EventSensorChange)
+		if err != nil {
+			return nil, err
+		}
+		site, err := deviceAge(sto, &conf, store.EventSiteChange)
+		if err != nil {
+			return nil, err
+		}
+		accuracy, err := accuracyString(sto, conf.Location())
+		if err != nil {
+			return nil, err
+		}
+
+		return &discord.Embed{
+			Title: "Devices",
+			Fields: []discord.EmbedField{
+				{Name: "Sensor", Value: sensor},
+				{Name: "Infusion Site", Value: site},
+				{Name: "Sensor Accuracy", Value: accuracy},
+			},
+			Footer: &discord.EmbedFooter{Text: "MARD is the mean absolute relative difference from fingersticks."},
+			Color:  discord.Color(WarnLevel1),
+		}, nil
+	case "change":
+		change := store.Event{Time: when, Type: optsMap["device"]}
+		if err := change.Validate(); err != nil {
+			return nil, err
+		}
+		if err := sto.AddPoint(store.FieldEvent, change.Time, change); err != nil {
+			return nil, fmt.Errorf("unable to save event: %w", err)
+		}
+
+		return &discord.Embed{
+			Fields: []discord.EmbedField{
+				{Name: "Event", Value: change.Type, Inline: true},
+				{Name: "Time", Value: change.Time.Format("Jan 02 15:04:05"), Inline: true},
+				{Name: "Expires", Value: localFormat(change.Time.Add(conf.Lifetime(change.Type)), conf.Location()), Inline: true},
+			},
+			Footer: &defaultFooter,
+			Color:  discord.Color(WarnLevel1),
+		}, nil
+	case "fingerstick":
+		v, err := strconv.ParseFloat(optsMap["value"], 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid value: %w", err)
+		}
+
+		stick := store.TimePoint{Time: when, Value: fromUnits(v, conf.Units), Trend: store.Missing}
+		if err := sto.AddPoint(store.FieldFingerstick, stick.Time, stick); err != nil {
+			return nil, fmt.Errorf("unable to save fingerstick: %w", err)
+		}
+
+		sensor := "-"
+		var pts []store.TimePoint
+		err = sto.GetPoints(when.Add(-fingerstickTolerance), when.Add(fingerstickTolerance), store.FieldGlucose, &pts)
+		if err != nil {
+			return nil, fmt.Errorf("unable to get points: %w", err)
+		}
+		if v, ok := metrics.At(pts, when); ok {
+			sensor = fmt.Sprintf("%s (%s)", glucoseToString(v, conf.Units),
+				signedGlucoseString(v-stick.Value, conf.Units))
+		}
+
+		return &discord.Embed{
+			Fields: []discord.EmbedField{
+				{Name: "Fingerstick", Value: glucoseToString(stick.Value, conf.Units), Inline: true},
+				{Name: "Sensor", Value: sensor, Inline: true},
+				{Name: "Time", Value: stick.Time.Format("Jan 02 15:04:05"), Inline: true},
+			},
+			Footer: &defaultFooter,
+			Color:  discord.Color(WarnLevel1),
+		}, nil
+	default:
+		return nil, fmt.Errorf("unknown subcommand: %s", opts[0].Name)
+	}
+}
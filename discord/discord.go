@@ -3,8 +3,10 @@ package discord
 import (
 	"context"
 	"fmt"
+	"sync"
 
 	"github.com/algao1/ichor/store"
+	"github.com/diamondburned/arikawa/v3/api"
 	"github.com/diamondburned/arikawa/v3/discord"
 	"github.com/diamondburned/arikawa/v3/gateway"
 	"github.com/diamondburned/arikawa/v3/session"
@@ -12,38 +14,34 @@ import (
 )
 
 type Bot struct {
-	ses    *session.Session
-	sto    *store.Store
-	logger *zap.Logger
-	alerts <-chan Alert
+	ses       *session.Session
+	sto       *store.Store
+	logger    *zap.Logger
+	alerts    <-chan Alert
+	reminders <-chan Reminder
+	digests   <-chan Digest
 
-	uid  discord.UserID
-	chid discord.ChannelID
+	// Private channels of registered users, created on demand.
+	mu       sync.Mutex
+	channels map[discord.UserID]discord.ChannelID
 }
 
-func Create(token string, uid float64, sto *store.Store, logger *zap.Logger, alertCh <-chan Alert) (*Bot, error) {
+func Create(token string, sto *store.Store, logger *zap.Logger, alertCh <-chan Alert,
+	reminderCh <-chan Reminder, digestCh <-chan Digest) (*Bot, error) {
 	ses := session.New("Bot " + token)
 
-	// Verify that we can create a private channel.
-	duid := discord.UserID(uid)
-	uch, err := ses.Client.CreatePrivateChannel(duid)
-	if err != nil {
-		return nil, err
-	}
-
 	b := &Bot{
-		ses:    ses,
-		sto:    sto,
-		alerts: alertCh,
-		logger: logger,
-		uid:    duid,
-		chid:   uch.ID,
+		ses:       ses,
+		sto:       sto,
+		alerts:    alertCh,
+		reminders: reminderCh,
+		digests:   digestCh,
+		logger:    logger,
+		channels:  make(map[discord.UserID]discord.ChannelID),
 	}
 
 	logger.Info("created Discord bot",
 		zap.String("token", token),
-		zap.Any("user id", duid),
-		zap.Any("private channel id", uch.ID),
 	)
 
 	// Add handlers.
@@ -53,10 +51,46 @@ func Create(token string, uid float64, sto *store.Store, logger *zap.Logger, ale
 	if alertCh != nil {
 		go b.handleAlerts()
 	}
+	if reminderCh != nil {
+		go b.handleReminders()
+	}
+	if digestCh != nil {
+		go b.handleDigests()
+	}
 
 	return b, nil
 }
 
+// channel returns the private channel with the given user, creating it if
+// it does not exist yet.
+func (b *Bot) channel(uid string) (discord.ChannelID, error) {
+	sf, err := discord.ParseSnowflake(uid)
+	if err != nil {
+		return 0, fmt.Errorf("invalid user id %s: %w", uid, err)
+	}
+	duid := discord.UserID(sf)
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if chid, ok := b.channels[duid]; ok {
+		return chid, nil
+	}
+
+	uch, err := b.ses.Client.CreatePrivateChannel(duid)
+	if err != nil {
+		return 0, err
+	}
+	b.channels[duid] = uch.ID
+
+	b.logger.Info("created private channel",
+		zap.Any("user id", duid),
+		zap.Any("private channel id", uch.ID),
+	)
+
+	return uch.ID, nil
+}
+
 func (b *Bot) Run(ctx context.Context) error {
 	err := b.ses.Open(ctx)
 	if err != nil {
@@ -94,6 +128,8 @@ func (b *Bot) Run(ctx context.Context) error {
 		)
 	}
 
+	go b.runStatus()
+
 	return nil
 }
 
@@ -106,38 +142,141 @@ func (b *Bot) handleAlerts() {
 		var msg string
 		alert := <-b.alerts
 
+		chid, err := b.channel(alert.UserID)
+		if err != nil {
+			b.logger.Info("failed to get private channel",
+				zap.String("user", alert.UserID),
+				zap.Error(err),
+			)
+			continue
+		}
+		sto := b.sto.ForUser(alert.UserID)
+
 		var obs []store.TimePoint
-		if err := b.sto.GetLastPoints(store.FieldGlucose, 1, &obs); err != nil {
-			msg = fmt.Sprintf("unable to get points: %s", err)
-			sendWarnMessage(b.ses, b.chid, msg)
+		if err := sto.GetLastPoints(store.FieldGlucose, 1, &obs); err != nil || len(obs) == 0 {
+			msg = fmt.Sprintf("unable to get points: %v", err)
+			sendWarnMessage(b.ses, chid, msg)
 			continue
 		}
 		ob := obs[0]
+		pr := alert.Point
+
+		var conf store.Config
+		if err := sto.GetObject(store.IndexConfig, &conf); err != nil {
+			msg = fmt.Sprintf("unable to load config: %s", err)
+			sendWarnMessage(b.ses, chid, msg)
+			continue
+		}
+
+		var header string
+		switch alert.Type {
+		case UrgentLow:
+			header = "🚨 incoming urgent low blood sugar"
+		case Low:
+			header = "🔻 incoming low blood sugar"
+		default:
+			header = "🔺 incoming high blood sugar"
+		}
+
+		msg = fmt.Sprintf(
+			"%s\n%s %s %s\n%s %s %s\n%s",
+			header,
+			localFormat(ob.Time, conf.Location()), glucoseToString(ob.Value, conf.Units), conf.Units,
+			localFormat(pr.Time, conf.Location()), glucoseToString(pr.Value, conf.Units), conf.Units,
+			onBoardString(alert.IOB, alert.COB),
+		)
+
+		b.ses.SendEmbeds(chid, discord.Embed{
+			Description: msg,
+			Color:       discord.Color(WarnLevel5),
+		})
 
-		var preds []store.TimePoint
-		if err := b.sto.GetLastPoints(store.FieldGlucosePred, 1, &preds); err != nil {
-			msg = fmt.Sprintf("unable to get points: %s", err)
-			sendWarnMessage(b.ses, b.chid, msg)
+		b.alertFollowers(alert, msg)
+	}
+}
+
+func (b *Bot) handleReminders() {
+	for reminder := range b.reminders {
+		chid, err := b.channel(reminder.UserID)
+		if err != nil {
+			b.logger.Info("failed to get private channel",
+				zap.String("user", reminder.UserID),
+				zap.Error(err),
+			)
 			continue
 		}
-		pr := preds[0]
 
-		if alert == Low {
-			msg = fmt.Sprintf(
-				"🔻 incoming low blood sugar\n%s %5.2f\n%s %5.2f",
-				localFormat(ob.Time), ob.Value,
-				localFormat(pr.Time), pr.Value,
+		b.ses.SendEmbeds(chid, discord.Embed{
+			Description: reminder.Message,
+			Color:       discord.Color(WarnLevel3),
+		})
+	}
+}
+
+func (b *Bot) handleDigests() {
+	for digest := range b.digests {
+		var conf store.Config
+		if err := b.sto.ForUser(digest.UserID).GetObject(store.IndexConfig, &conf); err != nil {
+			b.logger.Info("failed to load config",
+				zap.String("user", digest.UserID),
+				zap.Error(err),
 			)
-		} else {
-			msg = fmt.Sprintf(
-				"🔺 incoming high blood sugar\n%s %5.2f\n%s %5.2f",
-				localFormat(ob.Time), ob.Value,
-				localFormat(pr.Time), pr.Value,
+			continue
+		}
+
+		chid, err := b.channel(digest.UserID)
+		if err != nil {
+			b.logger.Info("failed to get private channel",
+				zap.String("user", digest.UserID),
+				zap.Error(err),
 			)
+			continue
 		}
 
-		b.ses.SendEmbeds(b.chid, discord.Embed{
-			Description: msg,
+		b.ses.SendEmbeds(chid, reportEmbed(digest.Title, digest.Report, digest.Previous, &conf))
+
+		if len(digest.Findings) == 0 {
+			continue
+		}
+		embeds, files, err := findingsMessage(digest.Findings, b.sto.ForUser(digest.UserID), &conf)
+		if err != nil {
+			b.logger.Info("failed to show patterns",
+				zap.String("user", digest.UserID),
+				zap.Error(err),
+			)
+			continue
+		}
+		b.ses.SendMessageComplex(chid, api.SendMessageData{Embeds: embeds, Files: files})
+	}
+}
+
+// alertFollowers forwards an alert to the followers subscribed to it.
+func (b *Bot) alertFollowers(alert Alert, msg string) {
+	u, err := b.sto.GetUser(alert.UserID)
+	if err != nil {
+		b.logger.Info("failed to get user",
+			zap.String("user", alert.UserID),
+			zap.Error(err),
+		)
+		return
+	}
+
+	for _, f := range u.Followers {
+		if !subscribed(f.Alerts, alert.Type) {
+			continue
+		}
+
+		chid, err := b.channel(f.ID)
+		if err != nil {
+			b.logger.Info("failed to get private channel",
+				zap.String("follower", f.ID),
+				zap.Error(err),
+			)
+			continue
+		}
+
+		b.ses.SendEmbeds(chid, discord.Embed{
+			Description: fmt.Sprintf("<@%s>\n%s", alert.UserID, msg),
 			Color:       discord.Color(WarnLevel5),
 		})
 	}
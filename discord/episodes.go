@@ -0,0 +1,177 @@
+package discord
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/algao1/ichor/store"
+	"github.com/diamondburned/arikawa/v3/api"
+	"github.com/diamondburned/arikawa/v3/discord"
+	"github.com/diamondburned/arikawa/v3/utils/json/option"
+	"github.com/diamondburned/arikawa/v3/utils/sendpart"
+)
+
+const (
+	defaultEpisodeDays = 14
+	// maxEpisodes is the number of episodes shown with their charts, leaving
+	// one embed of the message for the summary.
+	maxEpisodes = 9
+
+	// episodeChartBefore and episodeChartAfter are the time shown around an
+	// episode on its chart.
+	episodeChartBefore = 2 * time.Hour
+	episodeChartAfter  = time.Hour
+)
+
+var episodesCommand = api.CreateCommandData{
+	Name:        "episodes",
+	Description: "Review recent low episodes.",
+	Options: discord.CommandOptions{
+		&discord.IntegerOption{
+			OptionName:  "days",
+			Description: "Number of days to include, up to 90. Defaults to 14.",
+			Min:         option.NewInt(1),
+			Max:         option.NewInt(90),
+		},
+		patientOption,
+	},
+}
+
+// descentString formats a rate of descent, given in mmol/L per minute.
+func descentString(v float64, units string) string {
+	if v <= 0 {
+		return "-"
+	}
+	prec := 2
+	if units == store.UnitMgdl {
+		prec = 1
+	}
+	return strconv.FormatFloat(toUnits(v, units), 'f', prec, 64) + " " + units + "/min"
+}
+
+// alertString describes how long before an episode a low alert was sent.
+func alertString(ep store.Episode) string {
+	if !ep.Alerted() {
+		return "None"
+	}
+	return durationString(ep.Start.Sub(ep.Alert).Minutes()) + " before"
+}
+
+// episodeEmbed describes an episode, with its chart.
+func episodeEmbed(ep store.Episode, file sendpart.File, conf *store.Config) discord.Embed {
+	u := conf.Units
+	return discord.Embed{
+		Title:       localFormat(ep.Start, conf.Location()),
+		Description: fmt.Sprintf("Below %s for %s.", glucoseToString(ep.Threshold, u), durationString(ep.Duration().Minutes())),
+		Image:       &discord.EmbedImage{URL: "attachment://" + file.Name},
+		Fields: []discord.EmbedField{
+			// Line 1.
+			{Name: "Nadir", Value: fmt.Sprintf("%s at %s", glucoseToString(ep.Nadir, u),
+				ep.NadirTime.In(conf.Location()).Format("3:04 PM")), Inline: true},
+			{Name: "Descent", Value: descentString(ep.Descent, u), Inline: true},
+			{Name: "Alert", Value: alertString(ep), Inline: true},
+			// Line 2.
+			{Name: "Insulin (4h before)", Value: insulinToString(ep.Insulin), Inline: true},
+			{Name: "Carbs (4h before)", Value: fmt.Sprintf("%dg", ep.Carbs), Inline: true},
+		},
+		Color: discord.Color(WarnLevel3),
+	}
+}
+
+// episodeChart plots an episode with the readings and treatments around it.
+func episodeChart(ep store.Episode, i int, sto *store.Store, conf *store.Config) (sendpart.File, error) {
+	start, end := ep.Start.Add(-episodeChartBefore), ep.End.Add(episodeChartAfter)
+
+	var pts []store.TimePoint
+	if err := sto.GetPoints(start, end, store.FieldGlucose, &pts); err != nil {
+		return sendpart.File{}, fmt.Errorf("unable to get points: %w", err)
+	}
+
+	var carbs []store.Carbohydrate
+	if err := sto.GetPoints(start, end, store.FieldCarbohydrate, &carbs); err != nil {
+		return sendpart.File{}, fmt.Errorf("unable to get carbohydrates: %w", err)
+	}
+
+	var insulin []store.Insulin
+	if err := sto.GetPoints(start, end, store.FieldInsulin, &insulin); err != nil {
+		return sendpart.File{}, fmt.Errorf("unable to get insulin doses: %w", err)
+	}
+
+	r, err := PlotEpisode(conf.Units, conf.Location(), ep, pts, carbs, insulin)
+	if err != nil {
+		return sendpart.File{}, fmt.Errorf("unable to plot episode: %w", err)
+	}
+	return sendpart.File{Name: "episode-" + strconv.Itoa(i) + ".png", Reader: r}, nil
+}
+
+func episodesResponse(opts []discord.CommandInteractionOption, sto *store.Store) (*api.InteractionResponseData, error) {
+	days := defaultEpisodeDays
+	if v, ok := getAllOptions(opts)["days"]; ok {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			return nil, fmt.Errorf("invalid days: %w", err)
+		}
+		days = n
+	}
+
+	var conf store.Config
+	if err := sto.GetObject(store.IndexConfig, &conf); err != nil {
+		return nil, fmt.Errorf("unable to load config: %w", err)
+	}
+
+	var episodes []store.Episode
+	if err := sto.GetPoints(time.Now().AddDate(0, 0, -days), time.Now(), store.FieldEpisode, &episodes); err != nil {
+		return nil, fmt.Errorf("unable to get episodes: %w", err)
+	}
+
+	summary := discord.Embed{
+		Title:       "Low Episodes",
+		Description: fmt.Sprintf("No low episodes in the last %d days.", days),
+		Footer:      &discord.EmbedFooter{Text: "Episodes are at least 15 minutes low, ending after 15 minutes back in range."},
+		Color:       discord.Color(WarnLevel1),
+	}
+	if len(episodes) == 0 {
+		return &api.InteractionResponseData{Embeds: &[]discord.Embed{summary}}, nil
+	}
+
+	var total time.Duration
+	var alerted int
+	lowest := episodes[0].Nadir
+	for _, ep := range episodes {
+		total += ep.Duration()
+		if ep.Alerted() {
+			alerted++
+		}
+		if ep.Nadir < lowest {
+			lowest = ep.Nadir
+		}
+	}
+	summary.Description = fmt.Sprintf("%d episodes in the last %d days, the latest %d shown.",
+		len(episodes), days, minInt(len(episodes), maxEpisodes))
+	summary.Fields = []discord.EmbedField{
+		{Name: "Time Low", Value: durationString(total.Minutes()), Inline: true},
+		{Name: "Lowest", Value: glucoseToString(lowest, conf.Units), Inline: true},
+		{Name: "Alerted", Value: fmt.Sprintf("%d of %d", alerted, len(episodes)), Inline: true},
+	}
+
+	embeds := []discord.Embed{summary}
+	files := make([]sendpart.File, 0, maxEpisodes)
+	for i := len(episodes) - 1; i >= 0 && len(files) < maxEpisodes; i-- {
+		file, err := episodeChart(episodes[i], len(files), sto, &conf)
+		if err != nil {
+			return nil, err
+		}
+		embeds = append(embeds, episodeEmbed(episodes[i], file, &conf))
+		files = append(files, file)
+	}
+
+	return &api.InteractionResponseData{Embeds: &embeds, Files: files}, nil
+}
+
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
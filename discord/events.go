@@ -0,0 +1,130 @@
+package discord
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/algao1/ichor/store"
+	"github.com/diamondburned/arikawa/v3/api"
+	"github.com/diamondburned/arikawa/v3/discord"
+	"github.com/diamondburned/arikawa/v3/utils/json/option"
+)
+
+var eventCommand = api.CreateCommandData{
+	Name:        "event",
+	Description: "Log exercise, illness or another event that affects glucose.",
+	Options: discord.CommandOptions{
+		&discord.StringOption{
+			OptionName:  "type",
+			Description: "Type of event.",
+			Choices:     eventTypeChoices(),
+			Required:    true,
+		},
+		&discord.IntegerOption{
+			OptionName:  "duration",
+			Description: "Duration in minutes.",
+			Min:         option.ZeroInt,
+		},
+		&discord.StringOption{
+			OptionName:  "intensity",
+			Description: "Intensity of the exercise.",
+			Choices: []discord.StringChoice{
+				{Name: store.IntensityLight, Value: store.IntensityLight},
+				{Name: store.IntensityModerate, Value: store.IntensityModerate},
+				{Name: store.IntensityVigorous, Value: store.IntensityVigorous},
+			},
+		},
+		&discord.IntegerOption{
+			OptionName:  "offset",
+			Description: "Offset in minutes.",
+			Min:         option.ZeroInt,
+		},
+		&discord.StringOption{
+			OptionName:  "notes",
+			Description: "Free-text notes.",
+		},
+	},
+}
+
+func eventTypeChoices() []discord.StringChoice {
+	choices := make([]discord.StringChoice, len(store.EventTypes))
+	for i, t := range store.EventTypes {
+		choices[i] = discord.StringChoice{Name: t, Value: t}
+	}
+	return choices
+}
+
+// eventLabel briefly describes an event, e.g. "exercise 45m vigorous".
+func eventLabel(e store.Event) string {
+	label := e.Type
+	if e.Duration > 0 {
+		label += fmt.Sprintf(" %.0fm", e.Duration.Minutes())
+	}
+	if e.Intensity != "" {
+		label += " " + e.Intensity
+	}
+	return label
+}
+
+func eventResponse(opts []discord.CommandInteractionOption, sto *store.Store) (*discord.Embed, error) {
+	optsMap := getAllOptions(opts)
+
+	var conf store.Config
+	if err := sto.GetObject(store.IndexConfig, &conf); err != nil {
+		return nil, fmt.Errorf("unable to load config: %w", err)
+	}
+
+	event := store.Event{
+		Type:      optsMap["type"],
+		Intensity: optsMap["intensity"],
+		Notes:     strings.TrimSpace(optsMap["notes"]),
+	}
+
+	var offset, minutes int
+	ints := map[string]*int{"offset": &offset, "duration": &minutes}
+	for name, dst := range ints {
+		if v, ok := optsMap[name]; ok {
+			n, err := strconv.Atoi(v)
+			if err != nil {
+				return nil, fmt.Errorf("invalid %s: %w", name, err)
+			}
+			*dst = n
+		}
+	}
+	event.Duration = time.Duration(minutes) * time.Minute
+
+	if err := event.Validate(); err != nil {
+		return nil, err
+	}
+
+	event.Time = time.Now().In(conf.Location()).Add(-time.Duration(offset) * time.Minute)
+	if err := sto.AddPoint(store.FieldEvent, event.Time, event); err != nil {
+		return nil, fmt.Errorf("unable to save event: %w", err)
+	}
+
+	fields := []discord.EmbedField{
+		{Name: "Event", Value: event.Type, Inline: true},
+		{Name: "Time", Value: event.Time.Format("Jan 02 15:04:05"), Inline: true},
+	}
+	if event.Duration > 0 {
+		fields = append(fields, discord.EmbedField{
+			Name:   "Duration",
+			Value:  strconv.Itoa(minutes) + " minutes",
+			Inline: true,
+		})
+	}
+	if event.Intensity != "" {
+		fields = append(fields, discord.EmbedField{Name: "Intensity", Value: event.Intensity, Inline: true})
+	}
+	if event.Notes != "" {
+		fields = append(fields, discord.EmbedField{Name: "Notes", Value: event.Notes})
+	}
+
+	return &discord.Embed{
+		Fields: fields,
+		Footer: &defaultFooter,
+		Color:  discord.Color(WarnLevel1),
+	}, nil
+}
@@ -0,0 +1,370 @@
+package discord
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/algao1/ichor/store"
+	"github.com/diamondburned/arikawa/v3/api"
+	"github.com/diamondburned/arikawa/v3/discord"
+	"github.com/diamondburned/arikawa/v3/utils/json/option"
+)
+
+const defaultLogCount = 10
+
+var logEntryOption = &discord.StringOption{
+	OptionName:   "entry",
+	Description:  "Logged entry.",
+	Required:     true,
+	Autocomplete: true,
+}
+
+var logCommand = api.CreateCommandData{
+	Name:        "log",
+	Description: "List, edit or delete logged carbohydrates and insulin.",
+	Options: discord.CommandOptions{
+		&discord.SubcommandOption{
+			OptionName:  "list",
+			Description: "List recent entries.",
+			Options: []discord.CommandOptionValue{
+				&discord.IntegerOption{
+					OptionName:  "count",
+					Description: "Number of entries to list.",
+					Min:         option.NewInt(1),
+					Max:         option.NewInt(25),
+				},
+			},
+		},
+		&discord.SubcommandOption{
+			OptionName:  "edit",
+			Description: "Edit the value or time of an entry.",
+			Options: []discord.CommandOptionValue{
+				logEntryOption,
+				&discord.NumberOption{
+					OptionName:  "value",
+					Description: "New amount, in grams or units.",
+					Min:         option.ZeroFloat,
+				},
+				&discord.IntegerOption{
+					OptionName:  "offset",
+					Description: "New time, as an offset in minutes from now.",
+					Min:         option.ZeroInt,
+				},
+			},
+		},
+		&discord.SubcommandOption{
+			OptionName:  "delete",
+			Description: "Delete an entry.",
+			Options: []discord.CommandOptionValue{
+				logEntryOption,
+			},
+		},
+	},
+}
+
+// logEntry is a logged carbohydrate intake or insulin dose. Exactly one of
+// Carbs or Insulin is set, depending on Field.
+type logEntry struct {
+	Field   string
+	Carbs   *store.Carbohydrate
+	Insulin *store.Insulin
+}
+
+func (le logEntry) Time() time.Time {
+	if le.Carbs != nil {
+		return le.Carbs.Time
+	}
+	return le.Insulin.Time
+}
+
+// ID identifies the entry by its bucket and key.
+func (le logEntry) ID() string {
+	return le.Field + ":" + strconv.FormatInt(le.Time().Unix(), 10)
+}
+
+func (le logEntry) Describe(loc *time.Location) string {
+	when := le.Time().In(loc).Format("Jan 02 15:04")
+	if le.Carbs != nil {
+		desc := fmt.Sprintf("%s: %d grams carbohydrates", when, le.Carbs.Value)
+		if le.Carbs.GI != "" {
+			desc += fmt.Sprintf(" (%s)", le.Carbs.GI)
+		}
+		if len(le.Carbs.Tags) > 0 {
+			desc += " [" + strings.Join(le.Carbs.Tags, ", ") + "]"
+		}
+		return desc
+	}
+	product := le.Insulin.Product
+	if product == "" {
+		product = le.Insulin.Type
+	}
+	return fmt.Sprintf("%s: %s %s insulin", when, insulinToString(le.Insulin.Value), product)
+}
+
+func (le logEntry) value() interface{} {
+	if le.Carbs != nil {
+		return le.Carbs
+	}
+	return le.Insulin
+}
+
+// recentEntries returns the last n carbohydrate and insulin entries, from
+// latest to earliest.
+func recentEntries(sto *store.Store, n int) ([]logEntry, error) {
+	var carbs []store.Carbohydrate
+	if err := sto.GetLastPoints(store.FieldCarbohydrate, n, &carbs); err != nil {
+		return nil, fmt.Errorf("unable to get carbohydrates: %w", err)
+	}
+
+	var insulin []store.Insulin
+	if err := sto.GetLastPoints(store.FieldInsulin, n, &insulin); err != nil {
+		return nil, fmt.Errorf("unable to get insulin doses: %w", err)
+	}
+
+	entries := make([]logEntry, 0, len(carbs)+len(insulin))
+	for i := range carbs {
+		entries = append(entries, logEntry{Field: store.FieldCarbohydrate, Carbs: &carbs[i]})
+	}
+	for i := range insulin {
+		entries = append(entries, logEntry{Field: store.FieldInsulin, Insulin: &insulin[i]})
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].Time().After(entries[j].Time())
+	})
+
+	if len(entries) > n {
+		entries = entries[:n]
+	}
+
+	return entries, nil
+}
+
+// findEntry returns the entry with the given ID.
+func findEntry(sto *store.Store, id string) (*logEntry, error) {
+	parts := strings.SplitN(id, ":", 2)
+	if len(parts) != 2 {
+		return nil, fmt.Errorf("invalid entry: %s", id)
+	}
+
+	unix, err := strconv.ParseInt(parts[1], 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid entry: %s", id)
+	}
+	t := time.Unix(unix, 0)
+
+	switch parts[0] {
+	case store.FieldCarbohydrate:
+		var carbs []store.Carbohydrate
+		if err := sto.GetPoints(t, t, store.FieldCarbohydrate, &carbs); err != nil {
+			return nil, err
+		}
+		if len(carbs) == 0 {
+			return nil, fmt.Errorf("unable to find entry: %s", id)
+		}
+		return &logEntry{Field: store.FieldCarbohydrate, Carbs: &carbs[0]}, nil
+	case store.FieldInsulin:
+		var insulin []store.Insulin
+		if err := sto.GetPoints(t, t, store.FieldInsulin, &insulin); err != nil {
+			return nil, err
+		}
+		if len(insulin) == 0 {
+			return nil, fmt.Errorf("unable to find entry: %s", id)
+		}
+		return &logEntry{Field: store.FieldInsulin, Insulin: &insulin[0]}, nil
+	default:
+		return nil, fmt.Errorf("invalid entry: %s", id)
+	}
+}
+
+// logAutocomplete suggests recent entries matching the query.
+func logAutocomplete(sto *store.Store, query string) ([]api.AutocompleteChoice, error) {
+	var conf store.Config
+	if err := sto.GetObject(store.IndexConfig, &conf); err != nil {
+		return nil, fmt.Errorf("unable to load config: %w", err)
+	}
+
+	entries, err := recentEntries(sto, 25)
+	if err != nil {
+		return nil, err
+	}
+
+	query = strings.ToLower(query)
+	choices := make([]api.AutocompleteChoice, 0, len(entries))
+	for _, le := range entries {
+		desc := choiceName(le.Describe(conf.Location()))
+		if strings.Contains(strings.ToLower(desc), query) {
+			choices = append(choices, api.AutocompleteChoice{Name: desc, Value: le.ID()})
+		}
+	}
+
+	return choices, nil
+}
+
+func logResponse(opts []discord.CommandInteractionOption, sto *store.Store) (*discord.Embed, error) {
+	if len(opts) == 0 {
+		return nil, fmt.Errorf("missing subcommand")
+	}
+	optsMap := getAllOptions(opts[0].Options)
+
+	var conf store.Config
+	if err := sto.GetObject(store.IndexConfig, &conf); err != nil {
+		return nil, fmt.Errorf("unable to load config: %w", err)
+	}
+	loc := conf.Location()
+
+	switch opts[0].Name {
+	case "list":
+		n := defaultLogCount
+		if v, ok := optsMap["count"]; ok {
+			count, err := strconv.Atoi(v)
+			if err != nil {
+				return nil, err
+			}
+			n = count
+		}
+
+		entries, err := recentEntries(sto, n)
+		if err != nil {
+			return nil, err
+		}
+
+		desc := "Nothing has been logged yet."
+		if len(entries) > 0 {
+			lines := make([]string, len(entries))
+			for i, le := range entries {
+				lines[i] = le.Describe(loc)
+			}
+			desc = strings.Join(lines, "\n")
+		}
+
+		return &discord.Embed{
+			Title:       "Recent Entries",
+			Description: desc,
+			Footer:      &discord.EmbedFooter{Text: "Use /log edit or /log delete to correct an entry."},
+			Color:       discord.Color(WarnLevel1),
+		}, nil
+	case "edit":
+		le, err := findEntry(sto, optsMap["entry"])
+		if err != nil {
+			return nil, err
+		}
+		before := le.Describe(loc)
+
+		after, err := editEntry(sto, le, optsMap)
+		if err != nil {
+			return nil, err
+		}
+
+		return &discord.Embed{
+			Title: "Entry Updated",
+			Fields: []discord.EmbedField{
+				{Name: "Before", Value: before},
+				{Name: "After", Value: after.Describe(loc)},
+			},
+			Footer: &defaultFooter,
+			Color:  discord.Color(WarnLevel1),
+		}, nil
+	case "delete":
+		le, err := findEntry(sto, optsMap["entry"])
+		if err != nil {
+			return nil, err
+		}
+
+		if err := sto.DeletePoint(le.Field, le.Time()); err != nil {
+			return nil, fmt.Errorf("unable to delete entry: %w", err)
+		}
+
+		err = sto.AddAudit(store.AuditEntry{
+			Time:   time.Now(),
+			Action: store.AuditDelete,
+			Field:  le.Field,
+			Before: le.value(),
+		})
+		if err != nil {
+			return nil, fmt.Errorf("unable to record audit entry: %w", err)
+		}
+
+		return &discord.Embed{
+			Title:       "Entry Deleted",
+			Description: le.Describe(loc),
+			Footer:      &defaultFooter,
+			Color:       discord.Color(WarnLevel1),
+		}, nil
+	default:
+		return nil, fmt.Errorf("unknown subcommand: %s", opts[0].Name)
+	}
+}
+
+// editEntry changes the value and time of an entry, and records the change
+// in the audit trail.
+func editEntry(sto *store.Store, le *logEntry, optsMap map[string]string) (*logEntry, error) {
+	after := logEntry{Field: le.Field}
+	if le.Carbs != nil {
+		c := *le.Carbs
+		after.Carbs = &c
+	} else {
+		i := *le.Insulin
+		after.Insulin = &i
+	}
+
+	if v, ok := optsMap["value"]; ok {
+		val, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			return nil, err
+		}
+		if after.Carbs != nil {
+			if val != math.Trunc(val) {
+				return nil, fmt.Errorf("carbohydrates must be whole grams, got %s", v)
+			}
+			after.Carbs.Value = int(val)
+		} else {
+			after.Insulin.Value = val
+		}
+	}
+
+	if v, ok := optsMap["offset"]; ok {
+		offset, err := strconv.Atoi(v)
+		if err != nil {
+			return nil, err
+		}
+		when := time.Now().In(le.Time().Location()).Add(-time.Duration(offset) * time.Minute)
+		if after.Carbs != nil {
+			after.Carbs.Time = when
+		} else {
+			after.Insulin.Time = when
+		}
+	}
+
+	// Entries are keyed by time, so moving an entry must not overwrite
+	// another one.
+	if after.Time().Unix() != le.Time().Unix() {
+		if _, err := findEntry(sto, after.ID()); err == nil {
+			return nil, fmt.Errorf("another entry already exists at that time")
+		}
+		if err := sto.DeletePoint(le.Field, le.Time()); err != nil {
+			return nil, fmt.Errorf("unable to move entry: %w", err)
+		}
+	}
+
+	if err := sto.AddPoint(after.Field, after.Time(), after.value()); err != nil {
+		return nil, fmt.Errorf("unable to save entry: %w", err)
+	}
+
+	err := sto.AddAudit(store.AuditEntry{
+		Time:   time.Now(),
+		Action: store.AuditEdit,
+		Field:  le.Field,
+		Before: le.value(),
+		After:  after.value(),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("unable to record audit entry: %w", err)
+	}
+
+	return &after, nil
+}
@@ -0,0 +1,211 @@
+package discord
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/algao1/ichor/glucose/metrics"
+	"github.com/algao1/ichor/store"
+	"github.com/diamondburned/arikawa/v3/api"
+	"github.com/diamondburned/arikawa/v3/discord"
+	"github.com/diamondburned/arikawa/v3/utils/json/option"
+	"github.com/diamondburned/arikawa/v3/utils/sendpart"
+)
+
+const (
+	defaultMealDays = 14
+
+	groupTimeOfDay = "time-of-day"
+	groupTag       = "tag"
+
+	// untagged is the group of meals logged without tags.
+	untagged = "untagged"
+)
+
+var mealsCommand = api.CreateCommandData{
+	Name:        "meals",
+	Description: "Analyze the glucose response to logged meals.",
+	Options: discord.CommandOptions{
+		&discord.IntegerOption{
+			OptionName:  "days",
+			Description: "Number of days to include, up to 90. Defaults to 14.",
+			Min:         option.NewInt(1),
+			Max:         option.NewInt(90),
+		},
+		&discord.StringOption{
+			OptionName:  "group",
+			Description: "How to group the meals. Defaults to time of day.",
+			Choices: []discord.StringChoice{
+				{Name: "time of day", Value: groupTimeOfDay},
+				{Name: "tag", Value: groupTag},
+			},
+		},
+		patientOption,
+	},
+}
+
+// groupMeals groups meal responses by time of day or by tag, and returns the
+// group names in order. A meal with several tags is in each of their groups.
+func groupMeals(responses []metrics.MealResponse, by string, loc *time.Location) ([]string, map[string][]metrics.MealResponse) {
+	groups := make(map[string][]metrics.MealResponse)
+	for _, r := range responses {
+		keys := []string{metrics.MealTime(r.Meal.Time, loc)}
+		if by == groupTag {
+			keys = r.Meal.Tags
+			if len(keys) == 0 {
+				keys = []string{untagged}
+			}
+		}
+		for _, k := range keys {
+			groups[k] = append(groups[k], r)
+		}
+	}
+
+	names := make([]string, 0, len(groups))
+	if by == groupTag {
+		for name := range groups {
+			names = append(names, name)
+		}
+		sort.Slice(names, func(i, j int) bool {
+			if len(groups[names[i]]) != len(groups[names[j]]) {
+				return len(groups[names[i]]) > len(groups[names[j]])
+			}
+			return names[i] < names[j]
+		})
+	} else {
+		for _, name := range metrics.MealTimes {
+			if _, ok := groups[name]; ok {
+				names = append(names, name)
+			}
+		}
+	}
+
+	return names, groups
+}
+
+// median returns the median of vs, or NaN if there are none.
+func median(vs []float64) float64 {
+	if len(vs) == 0 {
+		return math.NaN()
+	}
+	sorted := append([]float64(nil), vs...)
+	sort.Float64s(sorted)
+	if n := len(sorted); n%2 == 0 {
+		return (sorted[n/2-1] + sorted[n/2]) / 2
+	}
+	return sorted[len(sorted)/2]
+}
+
+// durationString formats a time after a meal in hours and minutes.
+func durationString(minutes float64) string {
+	if math.IsNaN(minutes) {
+		return "-"
+	}
+	m := int(minutes)
+	if m < 60 {
+		return fmt.Sprintf("%dm", m)
+	}
+	return fmt.Sprintf("%dh%02dm", m/60, m%60)
+}
+
+// mealGroupString summarizes the responses to a group of meals by their
+// medians.
+func mealGroupString(responses []metrics.MealResponse, units string) string {
+	var carbs, peaks, toPeak, toBaseline []float64
+	for _, r := range responses {
+		carbs = append(carbs, float64(r.Meal.Value))
+		peaks = append(peaks, r.Peak)
+		toPeak = append(toPeak, r.TimeToPeak.Minutes())
+		if r.Returned {
+			toBaseline = append(toBaseline, r.TimeToBaseline.Minutes())
+		}
+	}
+
+	return fmt.Sprintf("%d meals, %.0fg\nPeak %s after %s\nBack to baseline after %s (%d of %d)",
+		len(responses), median(carbs), signedGlucoseString(median(peaks), units),
+		durationString(median(toPeak)), durationString(median(toBaseline)),
+		len(toBaseline), len(responses))
+}
+
+func mealsResponse(opts []discord.CommandInteractionOption, sto *store.Store) (*api.InteractionResponseData, error) {
+	optsMap := getAllOptions(opts)
+
+	days := defaultMealDays
+	if v, ok := optsMap["days"]; ok {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			return nil, fmt.Errorf("invalid days: %w", err)
+		}
+		days = n
+	}
+
+	by := groupTimeOfDay
+	if v, ok := optsMap["group"]; ok {
+		by = v
+	}
+
+	var conf store.Config
+	if err := sto.GetObject(store.IndexConfig, &conf); err != nil {
+		return nil, fmt.Errorf("unable to load config: %w", err)
+	}
+	loc := conf.Location()
+
+	end := time.Now()
+	start := end.AddDate(0, 0, -days)
+
+	var carbs []store.Carbohydrate
+	if err := sto.GetPoints(start, end, store.FieldCarbohydrate, &carbs); err != nil {
+		return nil, fmt.Errorf("unable to get carbohydrates: %w", err)
+	}
+
+	var pts []store.TimePoint
+	if err := sto.GetPoints(start.Add(-metrics.MealBefore), end, store.FieldGlucose, &pts); err != nil {
+		return nil, fmt.Errorf("unable to get points: %w", err)
+	}
+
+	responses := metrics.MealResponses(pts, carbs)
+	if len(responses) == 0 {
+		return nil, fmt.Errorf("no meals with glucose data in the last %d days", days)
+	}
+	names, groups := groupMeals(responses, by, loc)
+
+	// Only the largest groups are plotted, so that their colours are distinct.
+	plotted := names
+	if len(plotted) > len(groupColours) {
+		plotted = plotted[:len(groupColours)]
+	}
+	chart, err := PlotMealResponses(conf.Units, plotted, groups)
+	if err != nil {
+		return nil, fmt.Errorf("unable to plot meal responses: %w", err)
+	}
+	file := sendpart.File{Name: "meals.png", Reader: chart}
+
+	// Embeds hold at most 25 fields.
+	fields := make([]discord.EmbedField, 0, len(names))
+	for _, name := range names {
+		if len(fields) == 25 {
+			break
+		}
+		fields = append(fields, discord.EmbedField{
+			Name:   name,
+			Value:  mealGroupString(groups[name], conf.Units),
+			Inline: true,
+		})
+	}
+
+	return &api.InteractionResponseData{
+		Embeds: &[]discord.Embed{{
+			Title: "Meal Responses",
+			Description: fmt.Sprintf("%d of %d meals in the last %d days had glucose data.",
+				len(responses), len(carbs), days),
+			Image:  &discord.EmbedImage{URL: "attachment://" + file.Name},
+			Fields: fields,
+			Footer: &discord.EmbedFooter{Text: "Medians of the change from the reading at the meal, up to 4 hours after."},
+			Color:  discord.Color(WarnLevel1),
+		}},
+		Files: []sendpart.File{file},
+	}, nil
+}
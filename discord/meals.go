@@ -0,0 +1,382 @@
+package discord
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/algao1/ichor/glucose/metrics"
+	"github.com/algao1/ichor/store"
+	"github.com/diamondburned/arikawa/v3/api"
+	"github.com/diamondburned/arikawa/v3/discord"
+	"github.com/diamondburned/arikawa/v3/utils/json/option"
+)
+
+// presetHistory is how far back past uses of a preset are looked up.
+const presetHistory = 90 * 24 * time.Hour
+
+// excursionHours are the hours after a meal at which its glucose response
+// is measured.
+var excursionHours = []int{1, 2, 3}
+
+var mealPresetOption = &discord.StringOption{
+	OptionName:   "preset",
+	Description:  "Saved meal.",
+	Required:     true,
+	Autocomplete: true,
+}
+
+var mealCommand = api.CreateCommandData{
+	Name:        "meal",
+	Description: "Save and log meal presets.",
+	Options: discord.CommandOptions{
+		&discord.SubcommandOption{
+			OptionName:  "save",
+			Description: "Save a meal preset, replacing any with the same name.",
+			Options: []discord.CommandOptionValue{
+				&discord.StringOption{
+					OptionName:  "name",
+					Description: "Name of the meal.",
+					Required:    true,
+				},
+				&discord.IntegerOption{
+					OptionName:  "carbs",
+					Description: "Amount of carbohydrates (grams).",
+					Min:         option.ZeroInt,
+					Required:    true,
+				},
+				&discord.StringOption{
+					OptionName:  "gi",
+					Description: "Glycemic index of the carbohydrates.",
+					Choices: []discord.StringChoice{
+						{Name: store.GIFast, Value: store.GIFast},
+						{Name: store.GIMedium, Value: store.GIMedium},
+						{Name: store.GISlow, Value: store.GISlow},
+					},
+				},
+				&discord.NumberOption{
+					OptionName:  "bolus",
+					Description: "Usual units of insulin.",
+					Min:         option.ZeroFloat,
+				},
+				&discord.StringOption{
+					OptionName:   "insulin",
+					Description:  "Insulin product of the bolus. Defaults to the first rapid-acting one.",
+					Autocomplete: true,
+				},
+			},
+		},
+		&discord.SubcommandOption{
+			OptionName:  "log",
+			Description: "Log a saved meal, with its usual bolus.",
+			Options: []discord.CommandOptionValue{
+				mealPresetOption,
+				&discord.IntegerOption{
+					OptionName:  "offset",
+					Description: "Offset in minutes.",
+					Min:         option.ZeroInt,
+				},
+			},
+		},
+		&discord.SubcommandOption{
+			OptionName:  "list",
+			Description: "List saved meals and their usual glucose response.",
+		},
+		&discord.SubcommandOption{
+			OptionName:  "delete",
+			Description: "Delete a saved meal.",
+			Options: []discord.CommandOptionValue{
+				mealPresetOption,
+			},
+		},
+	},
+}
+
+func getPresets(sto *store.Store) ([]store.MealPreset, error) {
+	var presets []store.MealPreset
+	err := sto.GetObject(store.IndexMealPresets, &presets)
+	if err != nil && !errors.Is(err, store.ErrNotFound) {
+		return nil, fmt.Errorf("unable to load meal presets: %w", err)
+	}
+	return presets, nil
+}
+
+// findPreset returns the index of the preset with the given name, or -1.
+func findPreset(presets []store.MealPreset, name string) int {
+	for i, p := range presets {
+		if strings.EqualFold(p.Name, name) {
+			return i
+		}
+	}
+	return -1
+}
+
+// presetExcursions returns the average change in glucose at each of
+// excursionHours after the preset was logged, and the number of uses with
+// glucose data. Averages without data are NaN.
+func presetExcursions(sto *store.Store, name string) ([]float64, int, error) {
+	var carbs []store.Carbohydrate
+	err := sto.GetPoints(time.Now().Add(-presetHistory), time.Now(), store.FieldCarbohydrate, &carbs)
+	if err != nil {
+		return nil, 0, fmt.Errorf("unable to get carbohydrates: %w", err)
+	}
+
+	sums := make([]float64, len(excursionHours))
+	counts := make([]int, len(excursionHours))
+	uses := 0
+
+	for _, carb := range carbs {
+		if !strings.EqualFold(carb.Preset, name) {
+			continue
+		}
+
+		last := time.Duration(excursionHours[len(excursionHours)-1]) * time.Hour
+		var pts []store.TimePoint
+		err := sto.GetPoints(carb.Time.Add(-metrics.ReadingInterval), carb.Time.Add(last+metrics.ReadingInterval),
+			store.FieldGlucose, &pts)
+		if err != nil {
+			return nil, 0, fmt.Errorf("unable to get points: %w", err)
+		}
+
+		base, ok := metrics.At(pts, carb.Time)
+		if !ok {
+			continue
+		}
+
+		used := false
+		for i, h := range excursionHours {
+			v, ok := metrics.At(pts, carb.Time.Add(time.Duration(h)*time.Hour))
+			if !ok {
+				continue
+			}
+			sums[i] += v - base
+			counts[i]++
+			used = true
+		}
+		if used {
+			uses++
+		}
+	}
+
+	avgs := make([]float64, len(excursionHours))
+	for i := range avgs {
+		avgs[i] = math.NaN()
+		if counts[i] > 0 {
+			avgs[i] = sums[i] / float64(counts[i])
+		}
+	}
+
+	return avgs, uses, nil
+}
+
+func excursionString(avgs []float64, uses int, units string) string {
+	if uses == 0 {
+		return "No past uses with glucose data."
+	}
+
+	parts := make([]string, len(avgs))
+	for i, avg := range avgs {
+		v := "-"
+		if !math.IsNaN(avg) {
+			v = signedGlucoseString(avg, units)
+		}
+		parts[i] = fmt.Sprintf("%dh %s", excursionHours[i], v)
+	}
+
+	return fmt.Sprintf("%s (%d uses)", strings.Join(parts, " · "), uses)
+}
+
+func describePreset(p store.MealPreset) string {
+	desc := fmt.Sprintf("%d grams", p.Carbs)
+	if p.GI != "" {
+		desc += fmt.Sprintf(" (%s)", p.GI)
+	}
+	if p.Bolus > 0 {
+		desc += ", " + insulinToString(p.Bolus)
+		if p.Insulin != "" {
+			desc += " " + p.Insulin
+		}
+	}
+	return desc
+}
+
+// mealAutocomplete suggests saved meals matching the query.
+func mealAutocomplete(sto *store.Store, query string) ([]api.AutocompleteChoice, error) {
+	presets, err := getPresets(sto)
+	if err != nil {
+		return nil, err
+	}
+
+	query = strings.ToLower(query)
+	choices := make([]api.AutocompleteChoice, 0, len(presets))
+	for _, p := range presets {
+		if strings.Contains(strings.ToLower(p.Name), query) {
+			choices = append(choices, api.AutocompleteChoice{Name: choiceName(p.Name), Value: p.Name})
+		}
+	}
+
+	return choices, nil
+}
+
+func mealResponse(opts []discord.CommandInteractionOption, sto *store.Store) (*discord.Embed, error) {
+	if len(opts) == 0 {
+		return nil, fmt.Errorf("missing subcommand")
+	}
+	optsMap := getAllOptions(opts[0].Options)
+
+	var conf store.Config
+	if err := sto.GetObject(store.IndexConfig, &conf); err != nil {
+		return nil, fmt.Errorf("unable to load config: %w", err)
+	}
+
+	presets, err := getPresets(sto)
+	if err != nil {
+		return nil, err
+	}
+
+	switch opts[0].Name {
+	case "save":
+		p := store.MealPreset{
+			Name: strings.TrimSpace(optsMap["name"]),
+			GI:   optsMap["gi"],
+		}
+		if p.Name == "" {
+			return nil, fmt.Errorf("missing meal name")
+		}
+		// The name is the value of its autocomplete choice, which cannot be
+		// shortened.
+		if utf8.RuneCountInString(p.Name) > maxChoiceLength {
+			return nil, fmt.Errorf("meal name must be at most %d characters", maxChoiceLength)
+		}
+		if p.Carbs, err = strconv.Atoi(optsMap["carbs"]); err != nil {
+			return nil, fmt.Errorf("invalid carbs: %w", err)
+		}
+		if v, ok := optsMap["bolus"]; ok {
+			if p.Bolus, err = strconv.ParseFloat(v, 64); err != nil {
+				return nil, fmt.Errorf("invalid bolus: %w", err)
+			}
+		}
+		if v, ok := optsMap["insulin"]; ok {
+			products, err := getProducts(sto)
+			if err != nil {
+				return nil, err
+			}
+			product, err := store.FindInsulinProduct(products, v)
+			if err != nil {
+				return nil, err
+			}
+			p.Insulin = product.Name
+		}
+
+		if i := findPreset(presets, p.Name); i >= 0 {
+			presets[i] = p
+		} else {
+			presets = append(presets, p)
+		}
+		if err := sto.AddObject(store.IndexMealPresets, presets); err != nil {
+			return nil, fmt.Errorf("unable to save meal presets: %w", err)
+		}
+
+		return &discord.Embed{
+			Title:       "Meal Saved",
+			Description: fmt.Sprintf("**%s**: %s", p.Name, describePreset(p)),
+			Footer:      &defaultFooter,
+			Color:       discord.Color(WarnLevel1),
+		}, nil
+	case "log":
+		i := findPreset(presets, optsMap["preset"])
+		if i < 0 {
+			return nil, fmt.Errorf("unable to find meal: %s", optsMap["preset"])
+		}
+		p := presets[i]
+
+		var offset int
+		if v, ok := optsMap["offset"]; ok {
+			if offset, err = strconv.Atoi(v); err != nil {
+				return nil, fmt.Errorf("invalid offset: %w", err)
+			}
+		}
+
+		avgs, uses, err := presetExcursions(sto, p.Name)
+		if err != nil {
+			return nil, err
+		}
+
+		cr, err := addCarbohydrate(store.Carbohydrate{Value: p.Carbs, GI: p.GI, Preset: p.Name}, offset, sto)
+		if err != nil {
+			return nil, err
+		}
+		fields := carbohydrateFields(cr)
+
+		if p.Bolus > 0 {
+			products, err := getProducts(sto)
+			if err != nil {
+				return nil, err
+			}
+			product, err := store.FindInsulinProduct(products, p.Insulin)
+			if err != nil {
+				return nil, err
+			}
+			if _, err := addInsulin(*product, p.Bolus, offset, sto); err != nil {
+				return nil, err
+			}
+			fields = append(fields, discord.EmbedField{
+				Name:   "Bolus",
+				Value:  insulinToString(p.Bolus) + " " + product.Name,
+				Inline: true,
+			})
+		}
+		fields = append(fields, discord.EmbedField{Name: "Usual Response", Value: excursionString(avgs, uses, conf.Units)})
+
+		return &discord.Embed{
+			Title:  p.Name,
+			Fields: fields,
+			Footer: &defaultFooter,
+			Color:  discord.Color(WarnLevel1),
+		}, nil
+	case "list":
+		desc := "No meals have been saved yet."
+		if len(presets) > 0 {
+			lines := make([]string, len(presets))
+			for i, p := range presets {
+				avgs, uses, err := presetExcursions(sto, p.Name)
+				if err != nil {
+					return nil, err
+				}
+				lines[i] = fmt.Sprintf("**%s**: %s\n%s", p.Name, describePreset(p), excursionString(avgs, uses, conf.Units))
+			}
+			desc = strings.Join(lines, "\n\n")
+		}
+
+		return &discord.Embed{
+			Title:       "Saved Meals",
+			Description: desc,
+			Footer:      &discord.EmbedFooter{Text: "Responses are the average change in glucose after the meal."},
+			Color:       discord.Color(WarnLevel1),
+		}, nil
+	case "delete":
+		i := findPreset(presets, optsMap["preset"])
+		if i < 0 {
+			return nil, fmt.Errorf("unable to find meal: %s", optsMap["preset"])
+		}
+		p := presets[i]
+
+		presets = append(presets[:i], presets[i+1:]...)
+		if err := sto.AddObject(store.IndexMealPresets, presets); err != nil {
+			return nil, fmt.Errorf("unable to save meal presets: %w", err)
+		}
+
+		return &discord.Embed{
+			Title:       "Meal Deleted",
+			Description: fmt.Sprintf("**%s**: %s", p.Name, describePreset(p)),
+			Footer:      &defaultFooter,
+			Color:       discord.Color(WarnLevel1),
+		}, nil
+	default:
+		return nil, fmt.Errorf("unknown subcommand: %s", opts[0].Name)
+	}
+}
@@ -0,0 +1,154 @@
+package discord
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+
+	"github.com/algao1/ichor/glucose/metrics"
+	"github.com/algao1/ichor/glucose/patterns"
+	"github.com/algao1/ichor/store"
+	"github.com/diamondburned/arikawa/v3/api"
+	"github.com/diamondburned/arikawa/v3/discord"
+	"github.com/diamondburned/arikawa/v3/utils/json/option"
+	"github.com/diamondburned/arikawa/v3/utils/sendpart"
+)
+
+// maxFindings is the number of findings shown in one message, the most
+// embeds a message can hold.
+const maxFindings = 10
+
+var patternsCommand = api.CreateCommandData{
+	Name:        "patterns",
+	Description: "Look for recurring patterns, such as overnight lows.",
+	Options: discord.CommandOptions{
+		&discord.IntegerOption{
+			OptionName:  "days",
+			Description: "Number of days to scan, from 7 to 90. Defaults to 14.",
+			Min:         option.NewInt(7),
+			Max:         option.NewInt(90),
+		},
+		patientOption,
+	},
+}
+
+var findingTitles = map[string]string{
+	patterns.KindOvernightLows:  "Recurring Overnight Lows",
+	patterns.KindDawn:           "Dawn Phenomenon",
+	patterns.KindPostMealSpikes: "Post-Meal Spikes",
+	patterns.KindBasalDrift:     "Basal Insulin Timing",
+}
+
+// clockString formats a time since midnight.
+func clockString(d time.Duration) string {
+	return time.Date(0, 0, 0, 0, int(d.Minutes()), 0, 0, time.UTC).Format("3:04 PM")
+}
+
+// describeFinding explains a finding in a sentence.
+func describeFinding(f patterns.Finding, conf *store.Config) string {
+	switch f.Kind {
+	case patterns.KindOvernightLows:
+		return fmt.Sprintf("Lows started between %s and %s on %d of %d nights.",
+			clockString(f.From), clockString(f.To), f.Occurrences, f.Checked)
+	case patterns.KindDawn:
+		return fmt.Sprintf("Glucose rose by a median of %s between %s and %s on %d of %d mornings without carbohydrates.",
+			signedGlucoseString(f.Value, conf.Units), clockString(f.From), clockString(f.To), f.Occurrences, f.Checked)
+	case patterns.KindPostMealSpikes:
+		return fmt.Sprintf("Glucose went above %s after %d of %d %s meals, peaking at a median of %s.",
+			glucoseToString(conf.HighThreshold, conf.Units), f.Occurrences, f.Checked, f.Meal,
+			glucoseToString(f.Value, conf.Units))
+	case patterns.KindBasalDrift:
+		return fmt.Sprintf("Long-acting insulin was taken between %s and %s, with %d of %d doses over an hour from the usual time.",
+			clockString(f.From), clockString(f.To), f.Occurrences, f.Checked)
+	default:
+		return f.Kind
+	}
+}
+
+// findingChart plots the evidence for a finding.
+func findingChart(f patterns.Finding, sto *store.Store, conf *store.Config) (io.Reader, error) {
+	switch f.Kind {
+	case patterns.KindPostMealSpikes:
+		return PlotMealResponses(conf.Units, []string{f.Meal}, map[string][]metrics.MealResponse{f.Meal: f.Meals})
+	case patterns.KindBasalDrift:
+		return PlotDoseTimes(conf.Location(), f.Doses)
+	default:
+		// At most one window per colour, so that the days can be told apart.
+		windows := f.Windows
+		if len(windows) > len(groupColours) {
+			windows = windows[len(windows)-len(groupColours):]
+		}
+
+		var pts []store.TimePoint
+		if err := sto.GetPoints(windows[0].Start, windows[len(windows)-1].End, store.FieldGlucose, &pts); err != nil {
+			return nil, fmt.Errorf("unable to get points: %w", err)
+		}
+		return PlotWindows(findingTitles[f.Kind], conf.LowThreshold, conf.HighThreshold, conf.Units,
+			conf.Location(), windows, pts)
+	}
+}
+
+// findingsMessage shows each finding in an embed with its evidence chart.
+func findingsMessage(findings []patterns.Finding, sto *store.Store, conf *store.Config) ([]discord.Embed, []sendpart.File, error) {
+	if len(findings) > maxFindings {
+		findings = findings[:maxFindings]
+	}
+
+	embeds := make([]discord.Embed, 0, len(findings))
+	files := make([]sendpart.File, 0, len(findings))
+	for i, f := range findings {
+		chart, err := findingChart(f, sto, conf)
+		if err != nil {
+			return nil, nil, fmt.Errorf("unable to plot %s: %w", f.Kind, err)
+		}
+		file := sendpart.File{Name: "pattern-" + strconv.Itoa(i) + ".png", Reader: chart}
+
+		embeds = append(embeds, discord.Embed{
+			Title:       findingTitles[f.Kind],
+			Description: describeFinding(f, conf),
+			Image:       &discord.EmbedImage{URL: "attachment://" + file.Name},
+			Color:       discord.Color(WarnLevel3),
+		})
+		files = append(files, file)
+	}
+
+	return embeds, files, nil
+}
+
+func patternsResponse(opts []discord.CommandInteractionOption, sto *store.Store) (*api.InteractionResponseData, error) {
+	days := patterns.DefaultDays
+	if v, ok := getAllOptions(opts)["days"]; ok {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			return nil, fmt.Errorf("invalid days: %w", err)
+		}
+		days = n
+	}
+
+	var conf store.Config
+	if err := sto.GetObject(store.IndexConfig, &conf); err != nil {
+		return nil, fmt.Errorf("unable to load config: %w", err)
+	}
+
+	end := time.Now()
+	findings, err := patterns.Detect(sto, end.AddDate(0, 0, -days), end)
+	if err != nil {
+		return nil, fmt.Errorf("unable to detect patterns: %w", err)
+	}
+	if len(findings) == 0 {
+		return &api.InteractionResponseData{
+			Embeds: &[]discord.Embed{{
+				Title:       "Patterns",
+				Description: fmt.Sprintf("No recurring patterns in the last %d days.", days),
+				Color:       discord.Color(WarnLevel1),
+			}},
+		}, nil
+	}
+
+	embeds, files, err := findingsMessage(findings, sto, &conf)
+	if err != nil {
+		return nil, err
+	}
+	return &api.InteractionResponseData{Embeds: &embeds, Files: files}, nil
+}
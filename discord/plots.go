@@ -6,8 +6,11 @@ import (
 	"image/color"
 	"io"
 	"math"
+	"strings"
 	"time"
 
+	"github.com/algao1/ichor/glucose/metrics"
+	"github.com/algao1/ichor/glucose/patterns"
 	"github.com/algao1/ichor/store"
 	"github.com/lucasb-eyer/go-colorful"
 	"gonum.org/v1/plot"
@@ -22,8 +25,32 @@ var warnColour, _ = colorful.Hex("#484a47")
 var (
 	carbColour, _    = colorful.Hex("#21897e")
 	insulinColour, _ = colorful.Hex("#8980f5")
+	eventColour, _   = colorful.Hex("#d1495b")
 )
 
+var (
+	agpOuterColour, _ = colorful.Hex("#c6dbef")
+	agpInnerColour, _ = colorful.Hex("#6baed6")
+)
+
+// agpBinWidth is the width of the time of day bins the AGP percentiles are
+// computed over.
+const agpBinWidth = 15 * time.Minute
+
+// groupColours are the colours of the groups of meals, in order.
+var groupColours = []color.Color{
+	MondayColour,
+	WednesdayColour,
+	carbColour,
+	insulinColour,
+	SundayColour,
+	ThursdayColour,
+}
+
+// mealBinSeconds is the width of the bins the mean response of a group of
+// meals is computed over.
+const mealBinSeconds = 15 * 60
+
 var (
 	MondayColour, _    = colorful.Hex("#517AB8")
 	TuesdayColour, _   = colorful.Hex("#191970")
@@ -55,7 +82,7 @@ func (t HourTicks) Ticks(min, max float64) []plot.Tick {
 	for i := 0; i <= 24; i++ {
 		var label string
 		if i%3 == 0 {
-			label = time.Date(0, 0, 0, i, 0, 0, 0, loc).Format(HourFormat)
+			label = time.Date(0, 0, 0, i, 0, 0, 0, time.UTC).Format(HourFormat)
 		}
 
 		ticks = append(ticks, plot.Tick{
@@ -67,18 +94,58 @@ func (t HourTicks) Ticks(min, max float64) []plot.Tick {
 	return ticks
 }
 
+// MealTicks marks the time since a meal every 30 minutes, labelled every
+// hour.
+type MealTicks struct{}
+
+func (MealTicks) Ticks(min, max float64) []plot.Tick {
+	ticks := []plot.Tick{}
+
+	for m := -int(metrics.MealBefore.Minutes()); m <= int(metrics.MealAfter.Minutes()); m += 30 {
+		var label string
+		if m%60 == 0 {
+			label = fmt.Sprintf("%dh", m/60)
+		}
+
+		ticks = append(ticks, plot.Tick{
+			Value: float64(m * 60),
+			Label: label,
+		})
+	}
+
+	return ticks
+}
+
+// ClockTicks marks every hour of a plot whose x values are the seconds since
+// midnight, which may run past the next midnight.
+type ClockTicks struct{}
+
+func (ClockTicks) Ticks(min, max float64) []plot.Tick {
+	ticks := []plot.Tick{}
+
+	for h := int(math.Ceil(min / 3600)); h <= int(max/3600); h++ {
+		ticks = append(ticks, plot.Tick{
+			Value: float64(h * 3600),
+			Label: time.Date(0, 0, 0, h%24, 0, 0, 0, time.UTC).Format(HourFormat),
+		})
+	}
+
+	return ticks
+}
+
 type RecentTicks struct {
 	Ticker plot.Ticker
 	Time   func(t float64) time.Time
+	Loc    *time.Location
 }
 
 func (t RecentTicks) Ticks(min, max float64) []plot.Tick {
 	ticks := []plot.Tick{}
 
-	st := time.Unix(int64(min), 0).In(loc)
-	st = time.Date(st.Year(), st.Month(), st.Day(), st.Hour(), 0, 0, 0, loc)
+	st := time.Unix(int64(min), 0).In(t.Loc)
+	st = time.Date(st.Year(), st.Month(), st.Day(), st.Hour(), 0, 0, 0, t.Loc)
 
-	et := time.Unix(int64(max), 0).In(loc)
+	et := time.Unix(int64(max), 0).In(t.Loc)
 
 	for ; st.Before(et); st = st.Add(15 * time.Minute) {
 		tick := plot.Tick{Value: float64(st.Unix())}
@@ -135,6 +202,7 @@ func plotLowHighLines(min, max float64, p *plot.Plot) error {
 func plotCarbohydrates(yrange float64, xys plotter.XYs, carbs []store.Carbohydrate, p *plot.Plot) error {
 	offset := yrange / 20
 	carbxys := make(plotter.XYs, 0)
+	labels := make([]string, 0)
 	c := 0
 
 	for _, carb := range carbs {
@@ -149,6 +217,7 @@ func plotCarbohydrates(yrange float64, xys plotter.XYs, carbs []store.Carbohydra
 		}
 
 		carbxys = append(carbxys, plotter.XY{X: carbx, Y: carby - offset})
+		labels = append(labels, carbLabel(carb))
 	}
 
 	cs, err := plotter.NewScatter(carbxys)
@@ -159,12 +228,40 @@ func plotCarbohydrates(yrange float64, xys plotter.XYs, carbs []store.Carbohydra
 	cs.GlyphStyle.Shape = draw.PyramidGlyph{}
 	cs.GlyphStyle.Radius = 0.2 * font.Centimeter
 
-	p.Add(cs)
+	labelxys := make(plotter.XYs, len(carbxys))
+	for i, xy := range carbxys {
+		labelxys[i] = plotter.XY{X: xy.X, Y: xy.Y - offset}
+	}
+	ls, err := plotter.NewLabels(plotter.XYLabels{XYs: labelxys, Labels: labels})
+	if err != nil {
+		return err
+	}
+	for i := range ls.TextStyle {
+		ls.TextStyle[i].Color = carbColour
+		ls.TextStyle[i].XAlign = draw.XCenter
+		ls.TextStyle[i].YAlign = draw.YTop
+		ls.TextStyle[i].Font.Size = vg.Points(8)
+	}
+
+	p.Add(cs, ls)
 	p.Legend.Add("Carbohydrates", cs)
 
 	return nil
 }
 
+// carbLabel annotates a carbohydrate intake with its amount, glycemic index
+// and tags, when known.
+func carbLabel(carb store.Carbohydrate) string {
+	label := fmt.Sprintf("%dg", carb.Value)
+	if carb.GI != "" {
+		label += " " + carb.GI
+	}
+	if len(carb.Tags) > 0 {
+		label += "\n" + strings.Join(carb.Tags, ", ")
+	}
+	return label
+}
+
 func plotInsulin(yrange float64, xys plotter.XYs, insulin []store.Insulin, p *plot.Plot) error {
 	offset := yrange / 20
 	dosexys := make(plotter.XYs, 0)
@@ -198,29 +295,95 @@ func plotInsulin(yrange float64, xys plotter.XYs, insulin []store.Insulin, p *pl
 	return nil
 }
 
-func PlotRecentAndPreds(min, max float64, pts []store.TimePoint, preds []store.TimePoint,
-	carbs []store.Carbohydrate, insulin []store.Insulin) (io.Reader, error) {
+// plotEvents marks events along the top of the plot, above the highest
+// value. Events with a duration are drawn as a bar spanning it.
+func plotEvents(yrange, top float64, events []store.Event, p *plot.Plot) error {
+	if len(events) == 0 {
+		return nil
+	}
+
+	y := top + yrange/10
+	eventxys := make(plotter.XYs, len(events))
+	labels := make([]string, len(events))
+
+	for i, event := range events {
+		eventx := float64(event.Time.Unix())
+		eventxys[i] = plotter.XY{X: eventx, Y: y}
+		labels[i] = eventLabel(event)
+
+		if event.Duration <= 0 {
+			continue
+		}
+		bar, err := plotter.NewLine(plotter.XYs{
+			{X: eventx, Y: y},
+			{X: float64(event.Time.Add(event.Duration).Unix()), Y: y},
+		})
+		if err != nil {
+			return err
+		}
+		bar.LineStyle.Color = eventColour
+		bar.LineStyle.Width = vg.Points(3)
+		p.Add(bar)
+	}
+
+	es, err := plotter.NewScatter(eventxys)
+	if err != nil {
+		return err
+	}
+	es.GlyphStyle.Color = eventColour
+	es.GlyphStyle.Shape = draw.CircleGlyph{}
+	es.GlyphStyle.Radius = 0.15 * font.Centimeter
+
+	labelxys := make(plotter.XYs, len(eventxys))
+	for i, xy := range eventxys {
+		labelxys[i] = plotter.XY{X: xy.X, Y: xy.Y + yrange/40}
+	}
+	ls, err := plotter.NewLabels(plotter.XYLabels{XYs: labelxys, Labels: labels})
+	if err != nil {
+		return err
+	}
+	for i := range ls.TextStyle {
+		ls.TextStyle[i].Color = eventColour
+		ls.TextStyle[i].XAlign = draw.XLeft
+		ls.TextStyle[i].YAlign = draw.YBottom
+		ls.TextStyle[i].Font.Size = vg.Points(8)
+	}
+
+	p.Add(es, ls)
+	p.Legend.Add("Events", es)
+
+	return nil
+}
+
+// PlotRecentAndPreds plots recent glucose values and predictions. The
+// thresholds and points are given in mmol/L, and plotted in units and loc.
+func PlotRecentAndPreds(min, max float64, units string, loc *time.Location, pts []store.TimePoint, preds []store.TimePoint,
+	carbs []store.Carbohydrate, insulin []store.Insulin, events []store.Event) (io.Reader, error) {
 	if len(pts) == 0 {
 		return nil, fmt.Errorf("no points given")
 	}
 
+	min, max = toUnits(min, units), toUnits(max, units)
+	pad := toUnits(1, units)
+
 	p := plot.New()
 	p.Title.Text = "Current Values"
-	p.X.Label.Text = "Hour (EST)"
-	p.Y.Label.Text = "Glucose (mmol/l)"
-	p.X.Tick.Marker = RecentTicks{}
+	p.X.Label.Text = "Hour (" + pts[len(pts)-1].Time.In(loc).Format("MST") + ")"
+	p.Y.Label.Text = "Glucose (" + units + ")"
+	p.X.Tick.Marker = RecentTicks{Loc: loc}
 
-	p.Y.Min = math.Max(0, min-1)
-	p.Y.Max = max + 1
+	p.Y.Min = math.Max(0, min-pad)
+	p.Y.Max = max + pad
 
 	minSoFar := min
 	maxSoFar := max
 
 	xys := make(plotter.XYs, len(pts))
 	for i, pt := range pts {
-		maxSoFar = math.Max(maxSoFar, pt.Value)
-		minSoFar = math.Min(minSoFar, pt.Value)
-		xys[i] = plotter.XY{X: float64(pt.Time.Unix()), Y: pt.Value}
+		v := toUnits(pt.Value, units)
+		maxSoFar = math.Max(maxSoFar, v)
+		minSoFar = math.Min(minSoFar, v)
+		xys[i] = plotter.XY{X: float64(pt.Time.Unix()), Y: v}
 	}
 
 	l, err := plotter.NewLine(xys)
@@ -233,10 +396,11 @@ func PlotRecentAndPreds(min, max float64, pts []store.TimePoint, preds []store.T
 
 	predXYs := make(plotter.XYs, len(preds)+1)
 	for i, pred := range preds {
-		maxSoFar = math.Max(maxSoFar, pred.Value)
-		minSoFar = math.Min(minSoFar, pred.Value)
+		v := toUnits(pred.Value, units)
+		maxSoFar = math.Max(maxSoFar, v)
+		minSoFar = math.Min(minSoFar, v)
 
-		predXYs[i+1] = plotter.XY{X: float64(pred.Time.Unix()), Y: pred.Value}
+		predXYs[i+1] = plotter.XY{X: float64(pred.Time.Unix()), Y: v}
 	}
 	predXYs[0] = xys[len(xys)-1]
 
@@ -257,6 +421,10 @@ func PlotRecentAndPreds(min, max float64, pts []store.TimePoint, preds []store.T
 		return nil, err
 	}
 
+	if err = plotEvents(maxSoFar-minSoFar, maxSoFar, events, p); err != nil {
+		return nil, err
+	}
+
 	if err = plotLowHighLines(min, max, p); err != nil {
 		return nil, err
 	}
@@ -275,14 +443,41 @@ func PlotRecentAndPreds(min, max float64, pts []store.TimePoint, preds []store.T
 	return buf, nil
 }
 
-func PlotOverlayWeekly(min, max float64, pts []store.TimePoint) (io.Reader, error) {
+// overlayXYs groups the points by weekday in loc, placed at the time of day
+// on the wall clock so that each day lines up with the hour ticks.
+func overlayXYs(pts []store.TimePoint, units string, loc *time.Location) map[string]plotter.XYs {
+	dayPts := make(map[string]plotter.XYs)
+
+	for _, pt := range pts {
+		wd := pt.Time.In(loc).Weekday().String()
+		if _, ok := dayPts[wd]; !ok {
+			dayPts[wd] = make(plotter.XYs, 0)
+		}
+
+		dayPts[wd] = append(dayPts[wd],
+			plotter.XY{
+				X: float64(daySeconds(pt.Time, loc)),
+				Y: toUnits(pt.Value, units),
+			},
+		)
+	}
+
+	return dayPts
+}
+
+// PlotOverlayWeekly overlays a week of glucose values by day. The
+// thresholds and points are given in mmol/L, and plotted in units and loc.
+func PlotOverlayWeekly(min, max float64, units string, loc *time.Location, pts []store.TimePoint) (io.Reader, error) {
 	if len(pts) == 0 {
 		return nil, fmt.Errorf("no points given")
 	}
 
-	start := pts[0].Time
-	end := pts[len(pts)-1].Time
-	if end.Sub(start).Hours() > 7*24 {
+	min, max = toUnits(min, units), toUnits(max, units)
+
+	// A calendar week is not always 7*24 hours long across DST transitions.
+	start := pts[0].Time.In(loc)
+	end := pts[len(pts)-1].Time.In(loc)
+	if end.After(start.AddDate(0, 0, 7)) {
 		return nil, fmt.Errorf("points must be within a week")
 	}
 
@@ -294,32 +489,16 @@ func PlotOverlayWeekly(min, max float64, pts []store.TimePoint) (io.Reader, erro
 
 	p := plot.New()
 	p.Title.Text = "Weekly Overlay"
-	p.X.Label.Text = "Hour (EST)"
-	p.Y.Label.Text = "Glucose (mmol/l)"
+	p.X.Label.Text = "Hour (" + end.Format("MST") + ")"
+	p.Y.Label.Text = "Glucose (" + units + ")"
 	p.X.Tick.Marker = HourTicks{}
 
 	p.X.Min = 0
 	p.X.Max = 24 * 3600
 
-	p.Y.Min = math.Max(0, min-1)
+	p.Y.Min = math.Max(0, min-toUnits(1, units))
 
-	dayPts := make(map[string]plotter.XYs)
-
-	for _, pt := range pts {
-		wd := pt.Time.In(loc).Weekday().String()
-		if _, ok := dayPts[wd]; !ok {
-			dayPts[wd] = make(plotter.XYs, 0)
-		}
-
-		dayPts[wd] = append(dayPts[wd],
-			plotter.XY{
-				X: float64(daySeconds(pt.Time)),
-				Y: pt.Value,
-			},
-		)
-	}
-
-	for day, pts := range dayPts {
+	for day, pts := range overlayXYs(pts, units, loc) {
 		l, err := plotter.NewLine(pts)
 		if err != nil {
 			return nil, err
@@ -353,3 +532,392 @@ func PlotOverlayWeekly(min, max float64, pts []store.TimePoint) (io.Reader, erro
 
 	return buf, nil
 }
+
+// PlotAGP plots the ambulatory glucose profile: the median and the 25-75%
+// and 5-95% percentile bands of pts by time of day. The thresholds and
+// points are given in mmol/L, and plotted in units and loc.
+func PlotAGP(min, max float64, units string, loc *time.Location, pts []store.TimePoint) (io.Reader, error) {
+	if len(pts) == 0 {
+		return nil, fmt.Errorf("no points given")
+	}
+
+	min, max = toUnits(min, units), toUnits(max, units)
+
+	agp := metrics.AGP(pts, loc, agpBinWidth)
+	if len(agp) < 2 {
+		return nil, fmt.Errorf("not enough points to plot")
+	}
+
+	curves := make([]plotter.XYs, 5)
+	for i := range curves {
+		curves[i] = make(plotter.XYs, len(agp))
+	}
+	for i, bin := range agp {
+		x := bin.Time.Seconds()
+		for j, v := range []float64{bin.P5, bin.P25, bin.P50, bin.P75, bin.P95} {
+			curves[j][i] = plotter.XY{X: x, Y: toUnits(v, units)}
+		}
+	}
+
+	p := plot.New()
+	p.Title.Text = "Ambulatory Glucose Profile"
+	p.X.Label.Text = "Hour (" + pts[len(pts)-1].Time.In(loc).Format("MST") + ")"
+	p.Y.Label.Text = "Glucose (" + units + ")"
+	p.X.Tick.Marker = HourTicks{}
+
+	p.X.Min = 0
+	p.X.Max = 24 * 3600
+	p.Y.Min = 0
+
+	outer, err := percentileBand(curves[0], curves[4])
+	if err != nil {
+		return nil, err
+	}
+	outer.Color = agpOuterColour
+	outer.LineStyle.Width = 0
+
+	inner, err := percentileBand(curves[1], curves[3])
+	if err != nil {
+		return nil, err
+	}
+	inner.Color = agpInnerColour
+	inner.LineStyle.Width = 0
+
+	median, err := plotter.NewLine(curves[2])
+	if err != nil {
+		return nil, err
+	}
+	median.LineStyle.Width = vg.Points(2)
+
+	p.Add(outer, inner, median)
+	p.Legend.Add("5-95%", outer)
+	p.Legend.Add("25-75%", inner)
+	p.Legend.Add("Median", median)
+
+	if err := plotLowHighLines(min, max, p); err != nil {
+		return nil, err
+	}
+
+	wt, err := p.WriterTo(18*vg.Inch, 6*vg.Inch, "png")
+	if err != nil {
+		return nil, err
+	}
+
+	buf := new(bytes.Buffer)
+	_, err = wt.WriteTo(buf)
+	if err != nil {
+		return nil, err
+	}
+
+	return buf, nil
+}
+
+// percentileBand returns the area between the lower and upper curves.
+func percentileBand(lower, upper plotter.XYs) (*plotter.Polygon, error) {
+	ring := make(plotter.XYs, 0, len(lower)+len(upper))
+	ring = append(ring, upper...)
+	for i := len(lower) - 1; i >= 0; i-- {
+		ring = append(ring, lower[i])
+	}
+	return plotter.NewPolygon(ring)
+}
+
+// PlotMealResponses overlays the change in glucose after each meal, with
+// the mean response of each group in bold. Groups are plotted in the order
+// of names, and values are given in mmol/L and plotted in units.
+func PlotMealResponses(units string, names []string, groups map[string][]metrics.MealResponse) (io.Reader, error) {
+	if len(names) == 0 {
+		return nil, fmt.Errorf("no meals given")
+	}
+
+	p := plot.New()
+	p.Title.Text = "Meal Responses"
+	p.X.Label.Text = "Time Since Meal"
+	p.Y.Label.Text = "Change in Glucose (" + units + ")"
+	p.X.Tick.Marker = MealTicks{}
+	p.Legend.Top = true
+
+	p.X.Min = -metrics.MealBefore.Seconds()
+	p.X.Max = metrics.MealAfter.Seconds()
+
+	for i, name := range names {
+		c := groupColours[i%len(groupColours)]
+		faded := color.NRGBAModel.Convert(c).(color.NRGBA)
+		faded.A = 0x60
+
+		sums := make([]float64, int(p.X.Max-p.X.Min)/mealBinSeconds+1)
+		counts := make([]int, len(sums))
+
+		for _, r := range groups[name] {
+			xys := make(plotter.XYs, 0, len(r.Readings))
+			for _, pt := range r.Readings {
+				x := pt.Time.Sub(r.Meal.Time).Seconds()
+				y := toUnits(pt.Value-r.Baseline, units)
+				xys = append(xys, plotter.XY{X: x, Y: y})
+
+				bin := int(x-p.X.Min) / mealBinSeconds
+				sums[bin] += y
+				counts[bin]++
+			}
+			if len(xys) < 2 {
+				continue
+			}
+
+			l, err := plotter.NewLine(xys)
+			if err != nil {
+				return nil, err
+			}
+			l.LineStyle.Width = vg.Points(1)
+			l.LineStyle.Color = faded
+			p.Add(l)
+		}
+
+		mean := make(plotter.XYs, 0, len(sums))
+		for bin, sum := range sums {
+			if counts[bin] == 0 {
+				continue
+			}
+			x := p.X.Min + float64(bin*mealBinSeconds+mealBinSeconds/2)
+			mean = append(mean, plotter.XY{X: math.Min(x, p.X.Max), Y: sum / float64(counts[bin])})
+		}
+		if len(mean) < 2 {
+			continue
+		}
+
+		l, err := plotter.NewLine(mean)
+		if err != nil {
+			return nil, err
+		}
+		l.LineStyle.Width = vg.Points(3)
+		l.LineStyle.Color = c
+		p.Add(l)
+		p.Legend.Add(fmt.Sprintf("%s (%d)", name, len(groups[name])), l)
+	}
+
+	zero, err := plotter.NewLine(plotter.XYs{{X: p.X.Min, Y: 0}, {X: p.X.Max, Y: 0}})
+	if err != nil {
+		return nil, err
+	}
+	zero.LineStyle.Color = warnColour
+	zero.LineStyle.Width = vg.Points(1)
+	zero.LineStyle.Dashes = []vg.Length{vg.Points(5), vg.Points(10)}
+	p.Add(zero)
+
+	wt, err := p.WriterTo(18*vg.Inch, 6*vg.Inch, "png")
+	if err != nil {
+		return nil, err
+	}
+
+	buf := new(bytes.Buffer)
+	_, err = wt.WriteTo(buf)
+	if err != nil {
+		return nil, err
+	}
+
+	return buf, nil
+}
+
+// PlotWindows overlays the readings in each window by time of day, such as
+// the nights a pattern was seen on. The thresholds and points are given in
+// mmol/L, and plotted in units and loc.
+func PlotWindows(title string, min, max float64, units string, loc *time.Location,
+	windows []patterns.Window, pts []store.TimePoint) (io.Reader, error) {
+	if len(windows) == 0 {
+		return nil, fmt.Errorf("no windows given")
+	}
+
+	min, max = toUnits(min, units), toUnits(max, units)
+
+	p := plot.New()
+	p.Title.Text = title
+	p.X.Label.Text = "Hour (" + windows[0].Start.In(loc).Format("MST") + ")"
+	p.Y.Label.Text = "Glucose (" + units + ")"
+	p.X.Tick.Marker = ClockTicks{}
+
+	p.X.Min = math.Inf(1)
+	p.X.Max = math.Inf(-1)
+	p.Y.Min = 0
+
+	for i, w := range windows {
+		offset := float64(daySeconds(w.Start, loc))
+		p.X.Min = math.Min(p.X.Min, offset)
+		p.X.Max = math.Max(p.X.Max, offset+w.End.Sub(w.Start).Seconds())
+
+		xys := make(plotter.XYs, 0)
+		for _, pt := range pts {
+			if pt.Time.Before(w.Start) || pt.Time.After(w.End) {
+				continue
+			}
+			xys = append(xys, plotter.XY{
+				X: offset + pt.Time.Sub(w.Start).Seconds(),
+				Y: toUnits(pt.Value, units),
+			})
+		}
+		if len(xys) < 2 {
+			continue
+		}
+
+		l, err := plotter.NewLine(xys)
+		if err != nil {
+			return nil, err
+		}
+		l.LineStyle.Width = vg.Points(2)
+		l.LineStyle.Color = groupColours[i%len(groupColours)]
+		p.Add(l)
+		p.Legend.Add(w.Start.In(loc).Format("Mon Jan 02"), l)
+	}
+
+	if err := plotLowHighLines(min, max, p); err != nil {
+		return nil, err
+	}
+
+	wt, err := p.WriterTo(18*vg.Inch, 6*vg.Inch, "png")
+	if err != nil {
+		return nil, err
+	}
+
+	buf := new(bytes.Buffer)
+	_, err = wt.WriteTo(buf)
+	if err != nil {
+		return nil, err
+	}
+
+	return buf, nil
+}
+
+// PlotDoseTimes plots the time of day of each dose against its date, in loc.
+func PlotDoseTimes(loc *time.Location, doses []store.Insulin) (io.Reader, error) {
+	if len(doses) == 0 {
+		return nil, fmt.Errorf("no doses given")
+	}
+
+	xys := make(plotter.XYs, len(doses))
+	for i, dose := range doses {
+		xys[i] = plotter.XY{
+			X: float64(dose.Time.Unix()),
+			Y: float64(daySeconds(dose.Time, loc)),
+		}
+	}
+
+	p := plot.New()
+	p.Title.Text = "Long-Acting Insulin Doses"
+	p.X.Label.Text = "Date"
+	p.Y.Label.Text = "Hour (" + doses[len(doses)-1].Time.In(loc).Format("MST") + ")"
+	p.X.Tick.Marker = plot.TimeTicks{
+		Format: "Jan 02",
+		Time:   func(t float64) time.Time { return time.Unix(int64(t), 0).In(loc) },
+	}
+	p.Y.Tick.Marker = HourTicks{}
+
+	p.Y.Min = 0
+	p.Y.Max = 24 * 3600
+
+	ds, err := plotter.NewScatter(xys)
+	if err != nil {
+		return nil, err
+	}
+	ds.GlyphStyle.Color = insulinColour
+	ds.GlyphStyle.Shape = InvertPyramidGlyph{}
+	ds.GlyphStyle.Radius = 0.2 * font.Centimeter
+	p.Add(ds)
+
+	wt, err := p.WriterTo(18*vg.Inch, 6*vg.Inch, "png")
+	if err != nil {
+		return nil, err
+	}
+
+	buf := new(bytes.Buffer)
+	_, err = wt.WriteTo(buf)
+	if err != nil {
+		return nil, err
+	}
+
+	return buf, nil
+}
+
+// PlotEpisode plots a low episode with the readings, treatments and alert
+// around it. Values are given in mmol/L, and plotted in units and loc.
+func PlotEpisode(units string, loc *time.Location, ep store.Episode, pts []store.TimePoint,
+	carbs []store.Carbohydrate, insulin []store.Insulin) (io.Reader, error) {
+	if len(pts) == 0 {
+		return nil, fmt.Errorf("no points given")
+	}
+
+	threshold := toUnits(ep.Threshold, units)
+
+	p := plot.New()
+	p.Title.Text = "Low on " + ep.Start.In(loc).Format("Mon Jan 02")
+	p.X.Label.Text = "Hour (" + ep.Start.In(loc).Format("MST") + ")"
+	p.Y.Label.Text = "Glucose (" + units + ")"
+	p.X.Tick.Marker = RecentTicks{Loc: loc}
+	p.Y.Min = 0
+
+	minSoFar, maxSoFar := threshold, threshold
+	xys := make(plotter.XYs, len(pts))
+	for i, pt := range pts {
+		v := toUnits(pt.Value, units)
+		minSoFar = math.Min(minSoFar, v)
+		maxSoFar = math.Max(maxSoFar, v)
+		xys[i] = plotter.XY{X: float64(pt.Time.Unix()), Y: v}
+	}
+
+	l, err := plotter.NewLine(xys)
+	if err != nil {
+		return nil, err
+	}
+	l.LineStyle.Width = vg.Points(2)
+	p.Add(l)
+
+	nadir, err := plotter.NewScatter(plotter.XYs{{X: float64(ep.NadirTime.Unix()), Y: toUnits(ep.Nadir, units)}})
+	if err != nil {
+		return nil, err
+	}
+	nadir.GlyphStyle.Color = eventColour
+	nadir.GlyphStyle.Shape = draw.CircleGlyph{}
+	nadir.GlyphStyle.Radius = 0.15 * font.Centimeter
+	p.Add(nadir)
+	p.Legend.Add("Nadir", nadir)
+
+	if ep.Alerted() {
+		al, err := plotter.NewLine(plotter.XYs{
+			{X: float64(ep.Alert.Unix()), Y: 0},
+			{X: float64(ep.Alert.Unix()), Y: maxSoFar},
+		})
+		if err != nil {
+			return nil, err
+		}
+		al.LineStyle.Color = eventColour
+		al.LineStyle.Dashes = []vg.Length{vg.Points(3), vg.Points(3)}
+		p.Add(al)
+		p.Legend.Add("Alert", al)
+	}
+
+	if err := plotCarbohydrates(maxSoFar-minSoFar, xys, carbs, p); err != nil {
+		return nil, err
+	}
+	if err := plotInsulin(maxSoFar-minSoFar, xys, insulin, p); err != nil {
+		return nil, err
+	}
+
+	tl, err := plotter.NewLine(plotter.XYs{{X: xys[0].X, Y: threshold}, {X: xys[len(xys)-1].X, Y: threshold}})
+	if err != nil {
+		return nil, err
+	}
+	tl.LineStyle.Color = warnColour
+	tl.LineStyle.Width = vg.Points(1)
+	tl.LineStyle.Dashes = []vg.Length{vg.Points(5), vg.Points(10)}
+	p.Add(tl)
+
+	wt, err := p.WriterTo(9*vg.Inch, 3*vg.Inch, "png")
+	if err != nil {
+		return nil, err
+	}
+
+	buf := new(bytes.Buffer)
+	_, err = wt.WriteTo(buf)
+	if err != nil {
+		return nil, err
+	}
+
+	return buf, nil
+}
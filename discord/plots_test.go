@@ -0,0 +1,86 @@
+package discord
+
+import (
+	"testing"
+	"time"
+
+	"github.com/algao1/ichor/store"
+)
+
+// hourly returns a reading every hour of elapsed time from start, up to and
+// excluding end.
+func hourly(start, end time.Time) []store.TimePoint {
+	var pts []store.TimePoint
+	for t := start; t.Before(end); t = t.Add(time.Hour) {
+		pts = append(pts, store.TimePoint{Time: t, Value: 6})
+	}
+	return pts
+}
+
+func TestOverlayXYsDST(t *testing.T) {
+	loc := toronto(t)
+
+	tests := []struct {
+		name  string
+		start time.Time
+		// Hours on the wall clock of the readings on Sunday, the day of the
+		// transition.
+		wantSunday []int
+	}{
+		{
+			name:  "spring forward",
+			start: time.Date(2022, 3, 7, 0, 0, 0, 0, loc),
+			wantSunday: []int{0, 1, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16, 17, 18, 19, 20, 21, 22,
+				23},
+		},
+		{
+			name:  "fall back",
+			start: time.Date(2022, 10, 31, 0, 0, 0, 0, loc),
+			wantSunday: []int{0, 1, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16, 17, 18, 19, 20, 21,
+				22, 23},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			end := tc.start.AddDate(0, 0, 7)
+			pts := hourly(tc.start, end)
+
+			days := overlayXYs(pts, store.UnitMmol, loc)
+			if len(days) != 7 {
+				t.Fatalf("got %d days, want 7", len(days))
+			}
+			for day, xys := range days {
+				want := 24
+				if day == time.Sunday.String() {
+					want = len(tc.wantSunday)
+				}
+				if len(xys) != want {
+					t.Errorf("got %d points on %s, want %d", len(xys), day, want)
+				}
+				for _, xy := range xys {
+					if xy.X < 0 || xy.X >= 24*3600 {
+						t.Errorf("got x %g on %s, want within the day", xy.X, day)
+					}
+				}
+			}
+
+			sunday := days[time.Sunday.String()]
+			for i, h := range tc.wantSunday {
+				if i < len(sunday) && sunday[i].X != float64(h*3600) {
+					t.Errorf("got x %g for reading %d on Sunday, want %d", sunday[i].X, i, h*3600)
+				}
+			}
+
+			// The whole calendar week fits in an overlay, even when it is not
+			// 7*24 hours long.
+			if _, err := PlotOverlayWeekly(4, 10, store.UnitMmol, loc, pts); err != nil {
+				t.Errorf("got error for a calendar week: %v", err)
+			}
+			pts = append(pts, store.TimePoint{Time: end.Add(time.Hour), Value: 6})
+			if _, err := PlotOverlayWeekly(4, 10, store.UnitMmol, loc, pts); err == nil {
+				t.Errorf("got no error for points over more than a week")
+			}
+		})
+	}
+}
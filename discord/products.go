@@ -0,0 +1,222 @@
+package discord
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/algao1/ichor/store"
+	"github.com/diamondburned/arikawa/v3/api"
+	"github.com/diamondburned/arikawa/v3/discord"
+	"github.com/diamondburned/arikawa/v3/utils/json/option"
+)
+
+// maxProductName is the longest insulin product name, leaving room for the
+// rest of the bolus confirm button ID.
+const maxProductName = 50
+
+var productOption = &discord.StringOption{
+	OptionName:   "product",
+	Description:  "Insulin product.",
+	Required:     true,
+	Autocomplete: true,
+}
+
+var productsCommand = api.CreateCommandData{
+	Name:        "products",
+	Description: "Manage the insulin products that doses can be logged for.",
+	Options: discord.CommandOptions{
+		&discord.SubcommandOption{
+			OptionName:  "list",
+			Description: "List the insulin products.",
+		},
+		&discord.SubcommandOption{
+			OptionName:  "add",
+			Description: "Add an insulin product, replacing any with the same name.",
+			Options: []discord.CommandOptionValue{
+				&discord.StringOption{
+					OptionName:  "name",
+					Description: "Name of the product, e.g. aspart.",
+					Required:    true,
+				},
+				&discord.StringOption{
+					OptionName:  "category",
+					Description: "Category of the product.",
+					Choices: []discord.StringChoice{
+						{Name: store.RapidActing, Value: store.RapidActing},
+						{Name: store.UltraRapidActing, Value: store.UltraRapidActing},
+						{Name: store.IntermediateActing, Value: store.IntermediateActing},
+						{Name: store.LongActing, Value: store.LongActing},
+					},
+					Required: true,
+				},
+				&discord.IntegerOption{
+					OptionName:  "onset",
+					Description: "Minutes until the insulin starts acting.",
+					Min:         option.ZeroInt,
+					Required:    true,
+				},
+				&discord.IntegerOption{
+					OptionName:  "duration",
+					Description: "Minutes until the insulin stops acting.",
+					Min:         option.NewInt(1),
+					Required:    true,
+				},
+				&discord.IntegerOption{
+					OptionName:  "peak",
+					Description: "Minutes until the insulin peaks, if it has a peak.",
+					Min:         option.ZeroInt,
+				},
+			},
+		},
+		&discord.SubcommandOption{
+			OptionName:  "remove",
+			Description: "Remove an insulin product. Doses already logged are kept.",
+			Options: []discord.CommandOptionValue{
+				productOption,
+			},
+		},
+	},
+}
+
+func getProducts(sto *store.Store) ([]store.InsulinProduct, error) {
+	var products []store.InsulinProduct
+	if err := sto.GetObject(store.IndexInsulinProducts, &products); err != nil {
+		return nil, fmt.Errorf("unable to load insulin products: %w", err)
+	}
+	return products, nil
+}
+
+// findProduct returns the index of the product with the given name, or -1.
+func findProduct(products []store.InsulinProduct, name string) int {
+	for i, p := range products {
+		if strings.EqualFold(p.Name, name) {
+			return i
+		}
+	}
+	return -1
+}
+
+func describeProduct(p store.InsulinProduct) string {
+	peak := "peakless"
+	if p.Peak > 0 {
+		peak = "peak " + p.Peak.String()
+	}
+	return fmt.Sprintf("%s, onset %s, %s, duration %s", p.Category, p.Onset, peak, p.Duration)
+}
+
+// productAutocomplete suggests insulin products matching the query.
+func productAutocomplete(sto *store.Store, query string) ([]api.AutocompleteChoice, error) {
+	products, err := getProducts(sto)
+	if err != nil {
+		return nil, err
+	}
+
+	query = strings.ToLower(query)
+	choices := make([]api.AutocompleteChoice, 0, len(products))
+	for _, p := range products {
+		if strings.Contains(strings.ToLower(p.Name), query) {
+			choices = append(choices, api.AutocompleteChoice{Name: choiceName(p.Name + " (" + p.Category + ")"), Value: p.Name})
+		}
+	}
+
+	return choices, nil
+}
+
+func productsResponse(opts []discord.CommandInteractionOption, sto *store.Store) (*discord.Embed, error) {
+	if len(opts) == 0 {
+		return nil, fmt.Errorf("missing subcommand")
+	}
+	optsMap := getAllOptions(opts[0].Options)
+
+	products, err := getProducts(sto)
+	if err != nil {
+		return nil, err
+	}
+
+	switch opts[0].Name {
+	case "list":
+		desc := "No insulin products have been added yet."
+		if len(products) > 0 {
+			lines := make([]string, len(products))
+			for i, p := range products {
+				lines[i] = fmt.Sprintf("**%s**: %s", p.Name, describeProduct(p))
+			}
+			desc = strings.Join(lines, "\n")
+		}
+
+		return &discord.Embed{
+			Title:       "Insulin Products",
+			Description: desc,
+			Footer:      &defaultFooter,
+			Color:       discord.Color(WarnLevel1),
+		}, nil
+	case "add":
+		p := store.InsulinProduct{
+			Name:     strings.ToLower(strings.TrimSpace(optsMap["name"])),
+			Category: optsMap["category"],
+		}
+
+		// Names are part of the custom ID of the bolus confirm button.
+		if strings.Contains(p.Name, ":") {
+			return nil, fmt.Errorf("insulin product names cannot contain \":\"")
+		}
+		if len(p.Name) > maxProductName {
+			return nil, fmt.Errorf("insulin product names must be at most %d characters", maxProductName)
+		}
+
+		durations := map[string]*time.Duration{"onset": &p.Onset, "peak": &p.Peak, "duration": &p.Duration}
+		for name, dst := range durations {
+			v, ok := optsMap[name]
+			if !ok {
+				continue
+			}
+			minutes, err := strconv.Atoi(v)
+			if err != nil {
+				return nil, fmt.Errorf("invalid %s: %w", name, err)
+			}
+			*dst = time.Duration(minutes) * time.Minute
+		}
+
+		if err := p.Validate(); err != nil {
+			return nil, err
+		}
+
+		if i := findProduct(products, p.Name); i >= 0 {
+			products[i] = p
+		} else {
+			products = append(products, p)
+		}
+		if err := sto.AddObject(store.IndexInsulinProducts, products); err != nil {
+			return nil, fmt.Errorf("unable to save insulin products: %w", err)
+		}
+
+		return &discord.Embed{
+			Title:       "Insulin Product Added",
+			Description: fmt.Sprintf("**%s**: %s", p.Name, describeProduct(p)),
+			Footer:      &defaultFooter,
+			Color:       discord.Color(WarnLevel1),
+		}, nil
+	case "remove":
+		i := findProduct(products, optsMap["product"])
+		if i < 0 {
+			return nil, fmt.Errorf("unknown insulin product: %s", optsMap["product"])
+		}
+		p := products[i]
+
+		products = append(products[:i], products[i+1:]...)
+		if err := sto.AddObject(store.IndexInsulinProducts, products); err != nil {
+			return nil, fmt.Errorf("unable to save insulin products: %w", err)
+		}
+
+		return &discord.Embed{
+			Title:       "Insulin Product Removed",
+			Description: fmt.Sprintf("**%s**: %s", p.Name, describeProduct(p)),
+			Footer:      &defaultFooter,
+			Color:       discord.Color(WarnLevel1),
+		}, nil
+	default:
+		return nil, fmt.Errorf("unknown subcommand: %s", opts[0].Name)
+	}
+}
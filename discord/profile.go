@@ -0,0 +1,315 @@
+package discord
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/algao1/ichor/store"
+	"github.com/diamondburned/arikawa/v3/api"
+	"github.com/diamondburned/arikawa/v3/discord"
+	"github.com/diamondburned/arikawa/v3/utils/json/option"
+)
+
+const (
+	scheduleBasal       = "basal"
+	scheduleCarbRatio   = "carb-ratio"
+	scheduleSensitivity = "sensitivity"
+	scheduleTarget      = "target"
+)
+
+var scheduleStartOption = &discord.StringOption{
+	OptionName:  "start",
+	Description: "Local time the entry starts at, e.g. 06:30.",
+	Required:    true,
+}
+
+var profileCommand = api.CreateCommandData{
+	Name:        "profile",
+	Description: "View or edit the therapy profile's time-of-day schedules.",
+	Options: discord.CommandOptions{
+		&discord.SubcommandOption{
+			OptionName:  "view",
+			Description: "View the schedules.",
+			Options: []discord.CommandOptionValue{
+				&discord.IntegerOption{
+					OptionName:  "version",
+					Description: "Past version to view. Defaults to the current one.",
+					Min:         option.NewInt(1),
+				},
+			},
+		},
+		&discord.SubcommandOption{
+			OptionName:  "history",
+			Description: "List past versions of the profile.",
+		},
+		&discord.SubcommandOption{
+			OptionName:  scheduleBasal,
+			Description: "Set the basal rate from a time of day.",
+			Options: []discord.CommandOptionValue{
+				scheduleStartOption,
+				&discord.NumberOption{
+					OptionName:  "value",
+					Description: "Units of insulin per hour.",
+					Min:         option.ZeroFloat,
+					Required:    true,
+				},
+			},
+		},
+		&discord.SubcommandOption{
+			OptionName:  scheduleCarbRatio,
+			Description: "Set the carb ratio from a time of day.",
+			Options: []discord.CommandOptionValue{
+				scheduleStartOption,
+				&discord.NumberOption{
+					OptionName:  "value",
+					Description: "Grams of carbohydrates covered by a unit of insulin.",
+					Min:         option.ZeroFloat,
+					Required:    true,
+				},
+			},
+		},
+		&discord.SubcommandOption{
+			OptionName:  scheduleSensitivity,
+			Description: "Set the insulin sensitivity from a time of day.",
+			Options: []discord.CommandOptionValue{
+				scheduleStartOption,
+				&discord.NumberOption{
+					OptionName:  "value",
+					Description: "Glucose drop per unit of insulin, in the configured units.",
+					Min:         option.ZeroFloat,
+					Required:    true,
+				},
+			},
+		},
+		&discord.SubcommandOption{
+			OptionName:  scheduleTarget,
+			Description: "Set the target range from a time of day.",
+			Options: []discord.CommandOptionValue{
+				scheduleStartOption,
+				&discord.NumberOption{
+					OptionName:  "low",
+					Description: "Bottom of the target range, in the configured units.",
+					Min:         option.ZeroFloat,
+					Required:    true,
+				},
+				&discord.NumberOption{
+					OptionName:  "high",
+					Description: "Top of the target range, in the configured units.",
+					Min:         option.ZeroFloat,
+					Required:    true,
+				},
+			},
+		},
+		&discord.SubcommandOption{
+			OptionName:  "remove",
+			Description: "Remove a schedule entry.",
+			Options: []discord.CommandOptionValue{
+				&discord.StringOption{
+					OptionName:  "schedule",
+					Description: "Schedule to remove the entry from.",
+					Choices: []discord.StringChoice{
+						{Name: scheduleBasal, Value: scheduleBasal},
+						{Name: scheduleCarbRatio, Value: scheduleCarbRatio},
+						{Name: scheduleSensitivity, Value: scheduleSensitivity},
+						{Name: scheduleTarget, Value: scheduleTarget},
+					},
+					Required: true,
+				},
+				scheduleStartOption,
+			},
+		},
+	},
+}
+
+// parseTimeOfDay parses a time of day such as 06:30 into the time since
+// midnight.
+func parseTimeOfDay(s string) (time.Duration, error) {
+	t, err := time.Parse("15:04", strings.TrimSpace(s))
+	if err != nil {
+		return 0, fmt.Errorf("invalid time of day, expected HH:MM: %s", s)
+	}
+	return time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute, nil
+}
+
+func timeOfDayString(d time.Duration) string {
+	return fmt.Sprintf("%02d:%02d", int(d.Hours()), int(d.Minutes())%60)
+}
+
+func profileResponse(opts []discord.CommandInteractionOption, sto *store.Store) (*discord.Embed, error) {
+	if len(opts) == 0 {
+		return nil, fmt.Errorf("missing subcommand")
+	}
+	optsMap := getAllOptions(opts[0].Options)
+
+	var conf store.Config
+	if err := sto.GetObject(store.IndexConfig, &conf); err != nil {
+		return nil, fmt.Errorf("unable to load config: %w", err)
+	}
+
+	profile, err := sto.GetProfile()
+	if err != nil {
+		return nil, err
+	}
+
+	switch opts[0].Name {
+	case "view":
+		if v, ok := optsMap["version"]; ok {
+			version, err := strconv.Atoi(v)
+			if err != nil {
+				return nil, fmt.Errorf("invalid version: %w", err)
+			}
+			if profile, err = profileVersion(sto, version); err != nil {
+				return nil, err
+			}
+		}
+		embed := profileEmbed(profile, conf.Units, conf.Location())
+		return &embed, nil
+	case "history":
+		return profileHistoryEmbed(sto, conf.Location())
+	}
+
+	start, err := parseTimeOfDay(optsMap["start"])
+	if err != nil {
+		return nil, err
+	}
+
+	switch opts[0].Name {
+	case scheduleBasal:
+		v, err := strconv.ParseFloat(optsMap["value"], 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid basal rate: %w", err)
+		}
+		profile.Basal = profile.Basal.With(store.ScheduleEntry{Start: start, Value: v})
+	case scheduleCarbRatio:
+		v, err := strconv.ParseFloat(optsMap["value"], 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid carb ratio: %w", err)
+		}
+		profile.CarbRatios = profile.CarbRatios.With(store.ScheduleEntry{Start: start, Value: v})
+	case scheduleSensitivity:
+		v, err := strconv.ParseFloat(optsMap["value"], 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid sensitivity: %w", err)
+		}
+		profile.Sensitivities = profile.Sensitivities.With(store.ScheduleEntry{Start: start, Value: fromUnits(v, conf.Units)})
+	case scheduleTarget:
+		low, err := strconv.ParseFloat(optsMap["low"], 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid low target: %w", err)
+		}
+		high, err := strconv.ParseFloat(optsMap["high"], 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid high target: %w", err)
+		}
+		profile.Targets = profile.Targets.With(store.TargetEntry{
+			Start: start,
+			Low:   fromUnits(low, conf.Units),
+			High:  fromUnits(high, conf.Units),
+		})
+	case "remove":
+		switch optsMap["schedule"] {
+		case scheduleBasal:
+			profile.Basal = profile.Basal.Without(start)
+		case scheduleCarbRatio:
+			profile.CarbRatios = profile.CarbRatios.Without(start)
+		case scheduleSensitivity:
+			profile.Sensitivities = profile.Sensitivities.Without(start)
+		case scheduleTarget:
+			profile.Targets = profile.Targets.Without(start)
+		default:
+			return nil, fmt.Errorf("unknown schedule: %s", optsMap["schedule"])
+		}
+	default:
+		return nil, fmt.Errorf("unknown subcommand: %s", opts[0].Name)
+	}
+
+	if profile, err = sto.SaveProfile(*profile); err != nil {
+		return nil, err
+	}
+
+	embed := profileEmbed(profile, conf.Units, conf.Location())
+	return &embed, nil
+}
+
+// profileVersion returns the given version of the profile.
+func profileVersion(sto *store.Store, version int) (*store.Profile, error) {
+	profiles, err := sto.GetProfiles()
+	if err != nil {
+		return nil, err
+	}
+	for _, p := range profiles {
+		if p.Version == version {
+			return &p, nil
+		}
+	}
+	return nil, fmt.Errorf("unable to find profile version: %d", version)
+}
+
+func profileHistoryEmbed(sto *store.Store, loc *time.Location) (*discord.Embed, error) {
+	profiles, err := sto.GetProfiles()
+	if err != nil {
+		return nil, err
+	}
+
+	lines := make([]string, 0, len(profiles))
+	for i := len(profiles) - 1; i >= 0; i-- {
+		lines = append(lines, fmt.Sprintf("**Version %d**: since %s", profiles[i].Version, sinceString(profiles[i].Since, loc)))
+	}
+
+	return &discord.Embed{
+		Title:       "Profile History",
+		Description: strings.Join(lines, "\n"),
+		Footer:      &discord.EmbedFooter{Text: "Use /profile view with a version to see it."},
+		Color:       discord.Color(WarnLevel1),
+	}, nil
+}
+
+// sinceString formats the time a profile took effect. The first profile is
+// in effect from the start of the history.
+func sinceString(t time.Time, loc *time.Location) string {
+	if t.IsZero() {
+		return "the start"
+	}
+	return t.In(loc).Format("Jan 02 2006 15:04")
+}
+
+func profileEmbed(profile *store.Profile, units string, loc *time.Location) discord.Embed {
+	basal := []string{"No basal schedule."}
+	if len(profile.Basal) > 0 {
+		basal = make([]string, len(profile.Basal))
+		for i, e := range profile.Basal {
+			basal[i] = fmt.Sprintf("%s: %s U/h", timeOfDayString(e.Start), strconv.FormatFloat(e.Value, 'f', -1, 64))
+		}
+	}
+
+	crs := make([]string, len(profile.CarbRatios))
+	for i, e := range profile.CarbRatios {
+		crs[i] = fmt.Sprintf("%s: %s grams", timeOfDayString(e.Start), strconv.FormatFloat(e.Value, 'f', -1, 64))
+	}
+
+	isfs := make([]string, len(profile.Sensitivities))
+	for i, e := range profile.Sensitivities {
+		isfs[i] = fmt.Sprintf("%s: %s %s", timeOfDayString(e.Start), glucoseToString(e.Value, units), units)
+	}
+
+	targets := make([]string, len(profile.Targets))
+	for i, e := range profile.Targets {
+		targets[i] = fmt.Sprintf("%s: %s - %s %s", timeOfDayString(e.Start),
+			glucoseToString(e.Low, units), glucoseToString(e.High, units), units)
+	}
+
+	return discord.Embed{
+		Title:       fmt.Sprintf("Profile (Version %d)", profile.Version),
+		Description: "In effect since " + sinceString(profile.Since, loc) + ".",
+		Fields: []discord.EmbedField{
+			{Name: "Basal Rate", Value: strings.Join(basal, "\n"), Inline: true},
+			{Name: "Carb Ratio (per unit)", Value: strings.Join(crs, "\n"), Inline: true},
+			{Name: "Sensitivity (per unit)", Value: strings.Join(isfs, "\n"), Inline: true},
+			{Name: "Target Range", Value: strings.Join(targets, "\n"), Inline: true},
+		},
+		Footer: &defaultFooter,
+		Color:  discord.Color(WarnLevel1),
+	}
+}
@@ -0,0 +1,199 @@
+package discord
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"time"
+
+	"github.com/algao1/ichor/glucose/metrics"
+	"github.com/algao1/ichor/glucose/report"
+	"github.com/algao1/ichor/store"
+	"github.com/diamondburned/arikawa/v3/api"
+	"github.com/diamondburned/arikawa/v3/discord"
+)
+
+const dateFormat = "2006-01-02"
+
+var reportCommand = api.CreateCommandData{
+	Name:        "report",
+	Description: "Get a report of a period, compared with the period before it.",
+	Options: discord.CommandOptions{
+		&discord.StringOption{
+			OptionName:  "period",
+			Description: "Period to report on. Defaults to the last day.",
+			Choices: []discord.StringChoice{
+				{Name: "day", Value: report.PeriodDay},
+				{Name: "week", Value: report.PeriodWeek},
+				{Name: "month", Value: report.PeriodMonth},
+				{Name: "custom", Value: "custom"},
+			},
+		},
+		&discord.StringOption{
+			OptionName:  "start",
+			Description: "First day of a custom period, as YYYY-MM-DD.",
+		},
+		&discord.StringOption{
+			OptionName:  "end",
+			Description: "Last day of a custom period, as YYYY-MM-DD. Defaults to today.",
+		},
+		patientOption,
+	},
+}
+
+// reportPeriod returns the period to report on. The day, week and month are
+// the last 24 hours, 7 days and 30 days. Custom periods span whole days in
+// loc, with both ends included.
+func reportPeriod(optsMap map[string]string, loc *time.Location) (time.Time, time.Time, error) {
+	now := time.Now().In(loc)
+
+	period := optsMap["period"]
+	if period == "" {
+		period = report.PeriodDay
+	}
+
+	switch period {
+	case "custom":
+		v, ok := optsMap["start"]
+		if !ok {
+			return time.Time{}, time.Time{}, fmt.Errorf("a custom period needs a start date")
+		}
+		start, err := time.ParseInLocation(dateFormat, v, loc)
+		if err != nil {
+			return time.Time{}, time.Time{}, fmt.Errorf("invalid start date: %w", err)
+		}
+
+		end := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, loc)
+		if v, ok := optsMap["end"]; ok {
+			if end, err = time.ParseInLocation(dateFormat, v, loc); err != nil {
+				return time.Time{}, time.Time{}, fmt.Errorf("invalid end date: %w", err)
+			}
+		}
+		end = end.AddDate(0, 0, 1)
+
+		if !end.After(start) {
+			return time.Time{}, time.Time{}, fmt.Errorf("end date is before the start date")
+		}
+		if end.After(now) {
+			end = now
+		}
+		return start, end, nil
+	default:
+		start, err := report.Last(period, now)
+		return start, now, err
+	}
+}
+
+// compareString formats a value with its change since the previous period.
+// The change is left out if either value is missing.
+func compareString(cur, prev float64, format func(float64) string, signed func(float64) string) string {
+	if math.IsNaN(cur) {
+		return "-"
+	}
+	if math.IsNaN(prev) {
+		return format(cur)
+	}
+	return fmt.Sprintf("%s (%s)", format(cur), signed(cur-prev))
+}
+
+func signedIntString(v float64) string {
+	s := strconv.FormatFloat(v, 'f', 0, 64)
+	if v > 0 {
+		s = "+" + s
+	}
+	return s
+}
+
+func reportResponse(opts []discord.CommandInteractionOption, sto *store.Store) (*discord.Embed, error) {
+	var conf store.Config
+	if err := sto.GetObject(store.IndexConfig, &conf); err != nil {
+		return nil, fmt.Errorf("unable to load config: %w", err)
+	}
+	loc := conf.Location()
+
+	start, end, err := reportPeriod(getAllOptions(opts), loc)
+	if err != nil {
+		return nil, err
+	}
+
+	cur, err := report.Build(sto, start, end)
+	if err != nil {
+		return nil, fmt.Errorf("unable to build report: %w", err)
+	}
+	if cur.Readings == 0 {
+		return nil, fmt.Errorf("no readings between %s and %s", localFormat(start, loc), localFormat(end, loc))
+	}
+	prev, err := cur.Previous(sto)
+	if err != nil {
+		return nil, fmt.Errorf("unable to build previous report: %w", err)
+	}
+
+	embed := reportEmbed("Report", cur, prev, &conf)
+	return &embed, nil
+}
+
+// reportEmbed shows the metrics of cur, each with its change since prev.
+func reportEmbed(title string, cur, prev *report.Report, conf *store.Config) discord.Embed {
+	loc := conf.Location()
+	u := conf.Units
+
+	glucose := func(v float64) string { return glucoseToString(v, u) }
+	signedGlucose := func(v float64) string { return signedGlucoseString(v, u) }
+	percent := func(v float64) string { return fmt.Sprintf("%.1f%%", v) }
+	signedPercent := func(v float64) string { return signedFloatString(v) + "%" }
+	count := func(v float64) string { return strconv.FormatFloat(v, 'f', 0, 64) }
+	grams := func(v float64) string { return fmt.Sprintf("%.0fg", v) }
+	units := func(v float64) string { return fmt.Sprintf("%.1fU", v) }
+	signedUnits := func(v float64) string { return signedFloatString(v) + "U" }
+
+	fraction := func(cur, prev float64) string {
+		return compareString(cur, prev, floatToString, signedFloatString)
+	}
+	total := func(cur, prev, daily, prevDaily float64, format, signed func(float64) string) string {
+		return fmt.Sprintf("%s\n%s/day", compareString(cur, prev, format, signed),
+			compareString(daily, prevDaily, format, signed))
+	}
+
+	return discord.Embed{
+		Title: title,
+		Description: fmt.Sprintf("%s - %s (%.1f days)",
+			localFormat(cur.Start, loc), localFormat(cur.End, loc), cur.Days()),
+		Fields: []discord.EmbedField{
+			// Line 1.
+			{Name: "Mean", Value: compareString(cur.Mean, prev.Mean, glucose, signedGlucose), Inline: true},
+			{Name: "Std Dev", Value: compareString(cur.StdDev, prev.StdDev, glucose, signedGlucose), Inline: true},
+			{Name: "GMI", Value: compareString(cur.GMI, prev.GMI, percent, signedPercent), Inline: true},
+			// Line 2.
+			{Name: "In Range", Value: fraction(cur.TimeInRange, prev.TimeInRange), Inline: true},
+			{Name: "Below Range", Value: fraction(cur.TimeBelowRange, prev.TimeBelowRange), Inline: true},
+			{Name: "Above Range", Value: fraction(cur.TimeAboveRange, prev.TimeAboveRange), Inline: true},
+			// Line 3.
+			{Name: "Very Low (<" + glucoseToString(metrics.VeryLow, u) + ")", Value: fraction(cur.VeryLow, prev.VeryLow), Inline: true},
+			{Name: "Very High (>" + glucoseToString(metrics.VeryHigh, u) + ")", Value: fraction(cur.VeryHigh, prev.VeryHigh), Inline: true},
+			{Name: "CV", Value: fraction(cur.CV, prev.CV), Inline: true},
+			// Line 4.
+			{Name: "LBGI", Value: fraction(cur.LBGI, prev.LBGI), Inline: true},
+			{Name: "HBGI", Value: fraction(cur.HBGI, prev.HBGI), Inline: true},
+			{Name: "GRI", Value: fraction(cur.GRI, prev.GRI), Inline: true},
+			// Line 5.
+			{Name: "MAGE", Value: compareString(cur.MAGE, prev.MAGE, glucose, signedGlucose), Inline: true},
+			{Name: "MODD", Value: compareString(cur.MODD, prev.MODD, glucose, signedGlucose), Inline: true},
+			{Name: "CONGA (1h)", Value: compareString(cur.CONGA, prev.CONGA, glucose, signedGlucose), Inline: true},
+			// Line 6.
+			{Name: "Low Episodes", Value: compareString(float64(len(cur.LowEpisodes)), float64(len(prev.LowEpisodes)),
+				count, signedIntString), Inline: true},
+			{Name: "High Episodes", Value: compareString(float64(len(cur.HighEpisodes)), float64(len(prev.HighEpisodes)),
+				count, signedIntString), Inline: true},
+			{Name: "Sensor Wear", Value: fraction(cur.SensorWear, prev.SensorWear), Inline: true},
+			// Line 7.
+			{Name: "Carbohydrates", Value: total(float64(cur.Carbs), float64(prev.Carbs),
+				cur.DailyCarbs(), prev.DailyCarbs(), grams, signedIntString), Inline: true},
+			{Name: "Bolus", Value: total(cur.Bolus, prev.Bolus,
+				cur.DailyBolus(), prev.DailyBolus(), units, signedUnits), Inline: true},
+			{Name: "Basal", Value: total(cur.Basal, prev.Basal,
+				cur.DailyBasal(), prev.DailyBasal(), units, signedUnits), Inline: true},
+		},
+		Footer: &discord.EmbedFooter{Text: "Changes are since the previous period of the same length."},
+		Color:  discord.Color(WarnLevel1),
+	}
+}
@@ -0,0 +1,278 @@
+package discord
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/algao1/ichor/store"
+	"github.com/diamondburned/arikawa/v3/api"
+	"github.com/diamondburned/arikawa/v3/discord"
+	"github.com/diamondburned/arikawa/v3/utils/json/option"
+)
+
+var settingsCommand = api.CreateCommandData{
+	Name:        "settings",
+	Description: "View or update the bot settings.",
+	Options: discord.CommandOptions{
+		&discord.SubcommandOption{
+			OptionName:  "view",
+			Description: "View the current settings.",
+		},
+		&discord.SubcommandOption{
+			OptionName:  "update",
+			Description: "Update one or more settings.",
+			Options: []discord.CommandOptionValue{
+				&discord.NumberOption{
+					OptionName:  "low",
+					Description: "Low glucose threshold, in the configured units.",
+					Min:         option.ZeroFloat,
+				},
+				&discord.NumberOption{
+					OptionName:  "high",
+					Description: "High glucose threshold, in the configured units.",
+					Min:         option.ZeroFloat,
+				},
+				&discord.NumberOption{
+					OptionName:  "urgent",
+					Description: "Urgent low glucose threshold, in the configured units.",
+					Min:         option.ZeroFloat,
+				},
+				&discord.IntegerOption{
+					OptionName:  "timeout",
+					Description: "Minutes to wait between repeated warnings.",
+					Min:         option.ZeroInt,
+				},
+				&discord.StringOption{
+					OptionName:  "timezone",
+					Description: "IANA timezone name, e.g. America/Toronto.",
+				},
+				&discord.BooleanOption{
+					OptionName:  "live",
+					Description: "Keep a live status message pinned in this channel.",
+				},
+				&discord.IntegerOption{
+					OptionName:  "chart-interval",
+					Description: "Minutes between refreshes of the live status chart.",
+					Min:         option.NewInt(5),
+				},
+				&discord.StringOption{
+					OptionName:  "units",
+					Description: "Glucose units.",
+					Choices: []discord.StringChoice{
+						{Name: store.UnitMmol, Value: store.UnitMmol},
+						{Name: store.UnitMgdl, Value: store.UnitMgdl},
+					},
+				},
+				&discord.StringOption{
+					OptionName:  "insulin-curve",
+					Description: "Insulin activity curve used for insulin on board.",
+					Choices: []discord.StringChoice{
+						{Name: store.CurveExponential, Value: store.CurveExponential},
+						{Name: store.CurveWalsh, Value: store.CurveWalsh},
+					},
+				},
+				&discord.StringOption{
+					OptionName:  "carb-absorption",
+					Description: "Carbohydrate absorption model used for carbs on board.",
+					Choices: []discord.StringChoice{
+						{Name: store.AbsorptionLinear, Value: store.AbsorptionLinear},
+						{Name: store.AbsorptionDynamic, Value: store.AbsorptionDynamic},
+					},
+				},
+				&discord.IntegerOption{
+					OptionName:  "sensor-days",
+					Description: "Days a sensor lasts.",
+					Min:         option.NewInt(1),
+				},
+				&discord.IntegerOption{
+					OptionName:  "site-days",
+					Description: "Days an infusion site lasts.",
+					Min:         option.NewInt(1),
+				},
+				&discord.IntegerOption{
+					OptionName:  "expiry-reminder",
+					Description: "Hours before a sensor or site expires to send a reminder.",
+					Min:         option.ZeroInt,
+				},
+				&discord.BooleanOption{
+					OptionName:  "digests",
+					Description: "Send the overnight and weekly digests.",
+				},
+				&discord.StringOption{
+					OptionName:  "daily-digest",
+					Description: "Time of the overnight digest, as HH:MM.",
+				},
+				&discord.StringOption{
+					OptionName:  "weekly-digest",
+					Description: "Time of the weekly digest on Mondays, as HH:MM.",
+				},
+			},
+		},
+	},
+}
+
+func settingsResponse(opts []discord.CommandInteractionOption, sto *store.Store) (*store.Config, error) {
+	if len(opts) == 0 {
+		return nil, fmt.Errorf("missing subcommand")
+	}
+
+	var conf store.Config
+	if err := sto.GetObject(store.IndexConfig, &conf); err != nil {
+		return nil, fmt.Errorf("unable to load config: %w", err)
+	}
+
+	switch opts[0].Name {
+	case "view":
+		return &conf, nil
+	case "update":
+		if err := updateSettings(&conf, getAllOptions(opts[0].Options)); err != nil {
+			return nil, err
+		}
+		if err := sto.AddObject(store.IndexConfig, conf); err != nil {
+			return nil, fmt.Errorf("unable to save config: %w", err)
+		}
+		return &conf, nil
+	default:
+		return nil, fmt.Errorf("unknown subcommand: %s", opts[0].Name)
+	}
+}
+
+// updateSettings applies the given options to conf, and validates the result.
+// Thresholds are given in the configured units, after applying any change of
+// units in the same update.
+func updateSettings(conf *store.Config, optsMap map[string]string) error {
+	if v, ok := optsMap["units"]; ok {
+		conf.Units = v
+	}
+
+	if v, ok := optsMap["low"]; ok {
+		low, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			return fmt.Errorf("invalid low threshold: %w", err)
+		}
+		conf.LowThreshold = fromUnits(low, conf.Units)
+	}
+
+	if v, ok := optsMap["high"]; ok {
+		high, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			return fmt.Errorf("invalid high threshold: %w", err)
+		}
+		conf.HighThreshold = fromUnits(high, conf.Units)
+	}
+
+	if v, ok := optsMap["urgent"]; ok {
+		urgent, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			return fmt.Errorf("invalid urgent low threshold: %w", err)
+		}
+		conf.UrgentLowThreshold = fromUnits(urgent, conf.Units)
+	}
+
+	if v, ok := optsMap["timeout"]; ok {
+		minutes, err := strconv.Atoi(v)
+		if err != nil {
+			return fmt.Errorf("invalid timeout: %w", err)
+		}
+		conf.WarningTimeout = time.Duration(minutes) * time.Minute
+	}
+
+	if v, ok := optsMap["live"]; ok {
+		live, err := strconv.ParseBool(v)
+		if err != nil {
+			return fmt.Errorf("invalid live status: %w", err)
+		}
+		conf.LiveStatus = live
+	}
+
+	if v, ok := optsMap["chart-interval"]; ok {
+		minutes, err := strconv.Atoi(v)
+		if err != nil {
+			return fmt.Errorf("invalid chart interval: %w", err)
+		}
+		conf.StatusChartInterval = time.Duration(minutes) * time.Minute
+	}
+
+	if v, ok := optsMap["timezone"]; ok {
+		conf.Timezone = v
+	}
+
+	if v, ok := optsMap["insulin-curve"]; ok {
+		conf.InsulinCurve = v
+	}
+
+	if v, ok := optsMap["carb-absorption"]; ok {
+		conf.CarbAbsorption = v
+	}
+
+	if v, ok := optsMap["digests"]; ok {
+		digests, err := strconv.ParseBool(v)
+		if err != nil {
+			return fmt.Errorf("invalid digests: %w", err)
+		}
+		conf.Digests = digests
+	}
+
+	if v, ok := optsMap["daily-digest"]; ok {
+		conf.DailyDigest = v
+	}
+
+	if v, ok := optsMap["weekly-digest"]; ok {
+		conf.WeeklyDigest = v
+	}
+
+	durations := map[string]struct {
+		dst  *time.Duration
+		unit time.Duration
+	}{
+		"sensor-days":     {&conf.SensorLifetime, 24 * time.Hour},
+		"site-days":       {&conf.SiteLifetime, 24 * time.Hour},
+		"expiry-reminder": {&conf.ExpiryReminder, time.Hour},
+	}
+	for name, d := range durations {
+		if v, ok := optsMap[name]; ok {
+			n, err := strconv.Atoi(v)
+			if err != nil {
+				return fmt.Errorf("invalid %s: %w", name, err)
+			}
+			*d.dst = time.Duration(n) * d.unit
+		}
+	}
+
+	return conf.Validate()
+}
+
+func settingsEmbed(conf *store.Config) discord.Embed {
+	return discord.Embed{
+		Title: "Settings",
+		Fields: []discord.EmbedField{
+			// Line 1.
+			{Name: "Low Threshold", Value: glucoseToString(conf.LowThreshold, conf.Units), Inline: true},
+			{Name: "High Threshold", Value: glucoseToString(conf.HighThreshold, conf.Units), Inline: true},
+			{Name: "Urgent Low", Value: glucoseToString(conf.UrgentLowThreshold, conf.Units), Inline: true},
+			// Line 2.
+			{Name: "Warning Timeout", Value: conf.WarningTimeout.String(), Inline: true},
+			{Name: "Timezone", Value: conf.Timezone, Inline: true},
+			{Name: "Units", Value: conf.Units, Inline: true},
+			// Line 3.
+			{Name: "Live Status", Value: strconv.FormatBool(conf.LiveStatus), Inline: true},
+			{Name: "Chart Interval", Value: conf.StatusChartInterval.String(), Inline: true},
+			inlineBlankField,
+			// Line 4.
+			{Name: "Insulin Curve", Value: conf.InsulinCurve, Inline: true},
+			{Name: "Carb Absorption", Value: conf.CarbAbsorption, Inline: true},
+			inlineBlankField,
+			// Line 5.
+			{Name: "Sensor Lifetime", Value: ageString(conf.SensorLifetime), Inline: true},
+			{Name: "Site Lifetime", Value: ageString(conf.SiteLifetime), Inline: true},
+			{Name: "Expiry Reminder", Value: conf.ExpiryReminder.String(), Inline: true},
+			// Line 6.
+			{Name: "Digests", Value: strconv.FormatBool(conf.Digests), Inline: true},
+			{Name: "Daily Digest", Value: conf.DailyDigest, Inline: true},
+			{Name: "Weekly Digest", Value: conf.WeeklyDigest + " Mon", Inline: true},
+		},
+		Footer: &defaultFooter,
+		Color:  discord.Color(WarnLevel1),
+	}
+}
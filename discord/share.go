@@ -0,0 +1,185 @@
+package discord
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/algao1/ichor/store"
+	"github.com/diamondburned/arikawa/v3/api"
+	"github.com/diamondburned/arikawa/v3/discord"
+)
+
+// readOnlyCommands may be used by followers on the data of a patient they
+// follow. All other commands act on the invoking user's own data.
+var readOnlyCommands = map[string]bool{
+	"glucose":  true,
+	"weekly":   true,
+	"agp":      true,
+	"report":   true,
+	"meals":    true,
+	"patterns": true,
+	"episodes": true,
+}
+
+var patientOption = &discord.UserOption{
+	OptionName:  "patient",
+	Description: "Patient to show, if you follow someone.",
+}
+
+var shareCommand = api.CreateCommandData{
+	Name:        "share",
+	Description: "Share your glucose data and alerts with other users.",
+	Options: discord.CommandOptions{
+		&discord.SubcommandOption{
+			OptionName:  "add",
+			Description: "Grant a user read access, or change their alerts.",
+			Options: []discord.CommandOptionValue{
+				&discord.UserOption{
+					OptionName:  "user",
+					Description: "User to share with.",
+					Required:    true,
+				},
+				&discord.StringOption{
+					OptionName:  "alerts",
+					Description: "Alerts the user receives, defaults to urgent lows only.",
+					Choices: []discord.StringChoice{
+						{Name: "all alerts", Value: store.AlertsAll},
+						{Name: "lows and urgent lows", Value: store.AlertsLows},
+						{Name: "urgent lows only", Value: store.AlertsUrgent},
+						{Name: "none", Value: store.AlertsNone},
+					},
+				},
+			},
+		},
+		&discord.SubcommandOption{
+			OptionName:  "remove",
+			Description: "Revoke a user's access.",
+			Options: []discord.CommandOptionValue{
+				&discord.UserOption{
+					OptionName:  "user",
+					Description: "User to revoke.",
+					Required:    true,
+				},
+			},
+		},
+		&discord.SubcommandOption{
+			OptionName:  "list",
+			Description: "List the users you share with.",
+		},
+	},
+}
+
+// registeredStore returns the store of a registered user.
+func registeredStore(root *store.Store, uid discord.UserID) (*store.Store, error) {
+	if _, err := root.GetUser(uid.String()); err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			return nil, fmt.Errorf("you are not registered with this bot")
+		}
+		return nil, err
+	}
+	return root.ForUser(uid.String()), nil
+}
+
+// commandStore returns the store that a command should act on. Followers
+// may only use read-only commands, on the data of a patient they follow.
+func commandStore(root *store.Store, uid discord.UserID, data *discord.CommandInteraction) (*store.Store, error) {
+	id := uid.String()
+
+	_, err := root.GetUser(id)
+	if err != nil && !errors.Is(err, store.ErrNotFound) {
+		return nil, err
+	}
+	registered := err == nil
+
+	if !readOnlyCommands[data.Name] {
+		if !registered {
+			return nil, fmt.Errorf("you are not registered with this bot")
+		}
+		return root.ForUser(id), nil
+	}
+
+	followed, err := root.GetFollowed(id)
+	if err != nil {
+		return nil, err
+	}
+
+	patient, ok := getAllOptions(data.Options)["patient"]
+	if !ok || patient == id {
+		if registered {
+			return root.ForUser(id), nil
+		}
+
+		// Followers of a single patient don't need to name them.
+		switch len(followed) {
+		case 0:
+			return nil, fmt.Errorf("you are not registered with this bot, and do not follow anyone")
+		case 1:
+			return root.ForUser(followed[0].ID), nil
+		default:
+			return nil, fmt.Errorf("you follow several patients, please choose one")
+		}
+	}
+
+	for _, u := range followed {
+		if u.ID == patient {
+			return root.ForUser(patient), nil
+		}
+	}
+
+	return nil, fmt.Errorf("you do not have access to <@%s>'s data", patient)
+}
+
+func shareResponse(opts []discord.CommandInteractionOption, root *store.Store, uid discord.UserID) (*store.User, error) {
+	if len(opts) == 0 {
+		return nil, fmt.Errorf("missing subcommand")
+	}
+	optsMap := getAllOptions(opts[0].Options)
+
+	switch opts[0].Name {
+	case "add":
+		if optsMap["user"] == uid.String() {
+			return nil, fmt.Errorf("you already have access to your own data")
+		}
+
+		alerts, ok := optsMap["alerts"]
+		if !ok {
+			alerts = store.AlertsUrgent
+		}
+
+		err := root.AddFollower(uid.String(), store.Follower{
+			ID:     optsMap["user"],
+			Alerts: alerts,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("unable to add follower: %w", err)
+		}
+	case "remove":
+		if err := root.RemoveFollower(uid.String(), optsMap["user"]); err != nil {
+			return nil, fmt.Errorf("unable to remove follower: %w", err)
+		}
+	case "list":
+	default:
+		return nil, fmt.Errorf("unknown subcommand: %s", opts[0].Name)
+	}
+
+	return root.GetUser(uid.String())
+}
+
+func shareEmbed(u *store.User) discord.Embed {
+	desc := "You are not sharing your data with anyone."
+	if len(u.Followers) > 0 {
+		lines := make([]string, len(u.Followers))
+		for i, f := range u.Followers {
+			lines[i] = fmt.Sprintf("<@%s> (alerts: %s)", f.ID, f.Alerts)
+		}
+		desc = strings.Join(lines, "\n")
+	}
+
+	return discord.Embed{
+		Title:       "Followers",
+		Description: desc,
+		Footer:      &defaultFooter,
+		Color:       discord.Color(WarnLevel1),
+	}
+}
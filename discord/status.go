@@ -0,0 +1,223 @@
+package discord
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/algao1/ichor/glucose/onboard"
+	"github.com/algao1/ichor/store"
+	"github.com/diamondburned/arikawa/v3/api"
+	"github.com/diamondburned/arikawa/v3/discord"
+	"github.com/diamondburned/arikawa/v3/utils/sendpart"
+	"go.uber.org/zap"
+)
+
+const (
+	statusInterval = 1 * time.Minute
+	// statusWindow is the time shown on the status chart, as on /glucose.
+	// Without a reading in it, a stale status is shown instead.
+	statusWindow = 12 * time.Hour
+)
+
+// runStatus keeps the live status message of every registered user up to
+// date.
+func (b *Bot) runStatus() {
+	ticker := time.NewTicker(statusInterval)
+	defer ticker.Stop()
+
+	for ; true; <-ticker.C {
+		users, err := b.sto.GetUsers()
+		if err != nil {
+			b.logger.Info("failed to get registered users",
+				zap.Error(err),
+			)
+			continue
+		}
+
+		for _, u := range users {
+			if err := b.updateStatus(u.ID); err != nil {
+				b.logger.Info("failed to update status message",
+					zap.String("user", u.ID),
+					zap.Error(err),
+				)
+			}
+		}
+	}
+}
+
+// updateStatus edits the user's live status message, refreshing the chart
+// at the configured interval. A new message is sent and pinned if the
+// previous one cannot be edited.
+func (b *Bot) updateStatus(uid string) error {
+	sto := b.sto.ForUser(uid)
+
+	var conf store.Config
+	if err := sto.GetObject(store.IndexConfig, &conf); err != nil {
+		return fmt.Errorf("unable to load config: %w", err)
+	}
+	if !conf.LiveStatus {
+		return nil
+	}
+
+	var pts []store.TimePoint
+	if err := sto.GetLastPoints(store.FieldGlucose, 2, &pts); err != nil {
+		return fmt.Errorf("unable to get points: %w", err)
+	}
+	if len(pts) == 0 {
+		return nil
+	}
+
+	var preds []store.TimePoint
+	cur := pts[len(pts)-1]
+	err := sto.GetPoints(cur.Time.Add(time.Second), cur.Time.Add(1*time.Hour), store.FieldGlucosePred, &preds)
+	if err != nil {
+		return fmt.Errorf("unable to get predictions: %w", err)
+	}
+
+	var sm store.StatusMessage
+	err = sto.GetObject(store.IndexStatusMessage, &sm)
+	if err != nil && !errors.Is(err, store.ErrNotFound) {
+		return fmt.Errorf("unable to load status message: %w", err)
+	}
+
+	ob, err := onboard.New(sto, time.Now(), time.Now())
+	if err != nil {
+		return err
+	}
+
+	// After a sensor gap longer than the chart, there is nothing to plot.
+	// The chart is then left out, and refreshed as soon as readings resume.
+	stale := time.Since(cur.Time) >= statusWindow
+
+	embed := statusEmbed(pts, preds, ob.IOB(time.Now()), ob.COB(time.Now()), &conf)
+	if stale {
+		embed = staleStatusEmbed(cur, &conf)
+		sm.LastChart = time.Time{}
+	}
+
+	var files []sendpart.File
+	if !stale && (sm.MessageID == 0 || time.Since(sm.LastChart) >= conf.StatusChartInterval) {
+		gr, err := glucoseReport(sto)
+		if err != nil {
+			return fmt.Errorf("unable to get glucose report: %w", err)
+		}
+		files = []sendpart.File{gr.Chart}
+		sm.LastChart = time.Now()
+	}
+
+	if sm.MessageID != 0 {
+		data := api.EditMessageData{Embeds: &[]discord.Embed{embed}}
+		if len(files) > 0 || stale {
+			// Replace the previous chart rather than keeping it.
+			data.Attachments = &[]discord.Attachment{}
+			data.Files = files
+		}
+
+		_, err := b.ses.EditMessageComplex(discord.ChannelID(sm.ChannelID), discord.MessageID(sm.MessageID), data)
+		if err == nil {
+			return sto.AddObject(store.IndexStatusMessage, sm)
+		}
+
+		b.logger.Info("failed to edit status message, sending a new one",
+			zap.String("user", uid),
+			zap.Error(err),
+		)
+
+		// The old chart is gone along with the message.
+		if !stale {
+			gr, err := glucoseReport(sto)
+			if err != nil {
+				return fmt.Errorf("unable to get glucose report: %w", err)
+			}
+			files = []sendpart.File{gr.Chart}
+			sm.LastChart = time.Now()
+		}
+	}
+
+	chid, err := b.channel(uid)
+	if err != nil {
+		return err
+	}
+
+	msg, err := b.ses.SendMessageComplex(chid, api.SendMessageData{
+		Embeds: []discord.Embed{embed},
+		Files:  files,
+	})
+	if err != nil {
+		return fmt.Errorf("unable to send status message: %w", err)
+	}
+
+	if err := b.ses.PinMessage(chid, msg.ID, ""); err != nil {
+		b.logger.Info("failed to pin status message",
+			zap.String("user", uid),
+			zap.Error(err),
+		)
+	}
+
+	sm.ChannelID = uint64(chid)
+	sm.MessageID = uint64(msg.ID)
+
+	return sto.AddObject(store.IndexStatusMessage, sm)
+}
+
+// statusEmbed describes the latest reading in pts, the change since the
+// reading before it, the next predicted value, and the insulin and carbs on
+// board.
+func statusEmbed(pts, preds []store.TimePoint, iob, cob float64, conf *store.Config) discord.Embed {
+	cur := pts[len(pts)-1]
+
+	delta := "-"
+	if len(pts) > 1 {
+		delta = signedGlucoseString(cur.Value-pts[len(pts)-2].Value, conf.Units)
+	}
+
+	next := "-"
+	if len(preds) > 0 {
+		next = glucoseToString(preds[0].Value, conf.Units)
+	}
+
+	age := int(time.Since(cur.Time).Minutes())
+
+	color := WarnLevel1
+	if cur.Value <= conf.LowThreshold || cur.Value >= conf.HighThreshold {
+		color = WarnLevel5
+	} else if age > 15 {
+		color = WarnLevel3
+	}
+
+	return discord.Embed{
+		Title:       "Live Status",
+		Description: "Last reading at " + localFormat(cur.Time, conf.Location()),
+		Image:       &discord.EmbedImage{URL: "attachment://" + glucoseChartName},
+		Fields: []discord.EmbedField{
+			// Line 1.
+			{Name: "Current", Value: glucoseToString(cur.Value, conf.Units) + " " + conf.Units, Inline: true},
+			{Name: "Trend", Value: "\\" + trendToString(cur.Trend), Inline: true},
+			{Name: "Delta", Value: delta, Inline: true},
+			// Line 2.
+			{Name: "Next Predicted", Value: next, Inline: true},
+			{Name: "Age", Value: strconv.Itoa(age) + " min", Inline: true},
+			{Name: "On Board", Value: onBoardString(iob, cob), Inline: true},
+		},
+		Footer: &defaultFooter,
+		Color:  discord.Color(color),
+	}
+}
+
+// staleStatusEmbed is shown instead of the status when there has been no
+// reading within statusWindow, cur being the last one.
+func staleStatusEmbed(cur store.TimePoint, conf *store.Config) discord.Embed {
+	return discord.Embed{
+		Title: "Live Status",
+		Description: fmt.Sprintf("No readings in the last %d hours. Last reading at %s.",
+			int(statusWindow.Hours()), localFormat(cur.Time, conf.Location())),
+		Fields: []discord.EmbedField{
+			{Name: "Last", Value: glucoseToString(cur.Value, conf.Units) + " " + conf.Units, Inline: true},
+			{Name: "Age", Value: durationString(time.Since(cur.Time).Minutes()), Inline: true},
+		},
+		Footer: &defaultFooter,
+		Color:  discord.Color(WarnLevel3),
+	}
+}
@@ -61,15 +61,21 @@ func WithLocation(loc *time.Location) Option {
 	}
 }
 
-func New(accountName, password string, logger *zap.Logger, options ...Option) *Client {
-	loc, _ := time.LoadLocation("America/Toronto")
+// SetLocation changes the location reading times are returned in, for the
+// timezone setting to take effect without recreating the client.
+func (c *Client) SetLocation(loc *time.Location) {
+	c.loc = loc
+}
 
+// New creates a Dexcom Share client. Reading times are returned in UTC,
+// unless a location is given with WithLocation.
+func New(accountName, password string, logger *zap.Logger, options ...Option) *Client {
 	c := &Client{
 		client:      &http.Client{},
 		logger:      logger,
 		accountName: accountName,
 		password:    password,
-		loc:         loc,
+		loc:         time.UTC,
 	}
 
 	for _, option := range options {
@@ -143,7 +149,7 @@ func Transform(r *Reading, loc *time.Location) (*TransformedReading, error) {
 
 	return &TransformedReading{
 		Time:  time.Unix(int64(unix/1000), 0).In(loc),
-		Mmol:  r.Value / 18,
+		Mmol:  r.Value / store.MgdlPerMmol,
 		Trend: trend,
 	}, nil
 }
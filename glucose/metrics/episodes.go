@@ -0,0 +1,109 @@
+package metrics
+
+import (
+	"time"
+
+	"github.com/algao1/ichor/store"
+)
+
+// EpisodeDuration is how long glucose must stay past a threshold for an
+// episode to start, and back within it for the episode to end.
+const EpisodeDuration = 15 * time.Minute
+
+// Episode is a period spent below or above a threshold. Extreme is the
+// lowest value of a low episode, or the highest of a high one.
+type Episode struct {
+	Start   time.Time
+	End     time.Time
+	Extreme float64
+}
+
+func (e Episode) Duration() time.Duration {
+	return e.End.Sub(e.Start)
+}
+
+// LowEpisodes returns the episodes below threshold, using the consensus
+// definition: at least EpisodeDuration below the threshold, ending after
+// EpisodeDuration back above it. An episode still ongoing at the last
+// reading ends there.
+func LowEpisodes(pts []store.TimePoint, threshold float64) []Episode {
+	return episodes(pts, func(v float64) bool { return v < threshold }, func(a, b float64) bool { return a < b })
+}
+
+// HighEpisodes returns the episodes above threshold, defined as for
+// LowEpisodes.
+func HighEpisodes(pts []store.TimePoint, threshold float64) []Episode {
+	return episodes(pts, func(v float64) bool { return v > threshold }, func(a, b float64) bool { return a > b })
+}
+
+// episodes finds the runs of readings past a threshold, where past reports
+// whether a value is past it and worse whether a value is further past than
+// another. Gaps in the readings end a run.
+func episodes(pts []store.TimePoint, past func(float64) bool, worse func(a, b float64) bool) []Episode {
+	var eps []Episode
+
+	var cur *Episode
+	var runStart, backSince time.Time
+	inRun, back := false, false
+
+	for i, pt := range pts {
+		if i > 0 && pt.Time.Sub(pts[i-1].Time) > MaxGap {
+			if cur != nil {
+				eps = append(eps, *cur)
+			}
+			cur, inRun, back = nil, false, false
+		}
+
+		if past(pt.Value) {
+			back = false
+			if cur != nil {
+				cur.End = pt.Time
+				if worse(pt.Value, cur.Extreme) {
+					cur.Extreme = pt.Value
+				}
+				continue
+			}
+			if !inRun {
+				inRun = true
+				runStart = pt.Time
+			}
+			if pt.Time.Sub(runStart) >= EpisodeDuration {
+				cur = &Episode{Start: runStart, End: pt.Time, Extreme: extreme(pts, runStart, pt.Time, worse)}
+			}
+			continue
+		}
+
+		inRun = false
+		if cur == nil {
+			continue
+		}
+		if !back {
+			back = true
+			backSince = pt.Time
+		}
+		if pt.Time.Sub(backSince) >= EpisodeDuration {
+			eps = append(eps, *cur)
+			cur, back = nil, false
+		}
+	}
+
+	if cur != nil {
+		eps = append(eps, *cur)
+	}
+	return eps
+}
+
+// extreme returns the worst value between start and end.
+func extreme(pts []store.TimePoint, start, end time.Time, worse func(a, b float64) bool) float64 {
+	var found float64
+	first := true
+	for _, pt := range pts {
+		if pt.Time.Before(start) || pt.Time.After(end) {
+			continue
+		}
+		if first || worse(pt.Value, found) {
+			found, first = pt.Value, false
+		}
+	}
+	return found
+}
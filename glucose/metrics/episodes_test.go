@@ -0,0 +1,71 @@
+package metrics
+
+import (
+	"testing"
+	"time"
+
+	"github.com/algao1/ichor/store"
+)
+
+func TestLowEpisodes(t *testing.T) {
+	min := time.Minute
+	tests := []struct {
+		name string
+		pts  []store.TimePoint
+		want []Episode
+	}{
+		{"too short", series(ReadingInterval, 6, 3, 3, 3, 6, 6, 6, 6), nil},
+		{"exactly 15 minutes", series(ReadingInterval, 6, 3, 3, 2.8, 3, 6, 6, 6, 6), []Episode{
+			{Start: start.Add(5 * min), End: start.Add(20 * min), Extreme: 2.8},
+		}},
+		// Back above the threshold for less than 15 minutes, the episode
+		// carries on.
+		{"short recovery", series(ReadingInterval, 3, 3, 3, 3, 6, 6, 2.5, 6, 6, 6, 6), []Episode{
+			{Start: start, End: start.Add(30 * min), Extreme: 2.5},
+		}},
+		{"long recovery", series(ReadingInterval, 3, 3, 3, 3, 6, 6, 6, 6, 3, 3, 3, 3), []Episode{
+			{Start: start, End: start.Add(15 * min), Extreme: 3},
+			{Start: start.Add(40 * min), End: start.Add(55 * min), Extreme: 3},
+		}},
+		// A gap longer than MaxGap ends the episode, even though the readings
+		// on both sides are low.
+		{"gap", []store.TimePoint{
+			reading(0, 3), reading(5*min, 3), reading(10*min, 3), reading(15*min, 3),
+			reading(45*min, 2.9), reading(50*min, 3), reading(55*min, 3), reading(60*min, 3),
+		}, []Episode{
+			{Start: start, End: start.Add(15 * min), Extreme: 3},
+			{Start: start.Add(45 * min), End: start.Add(60 * min), Extreme: 2.9},
+		}},
+		{"ongoing", series(ReadingInterval, 6, 3, 3, 3, 2.9, 3), []Episode{
+			{Start: start.Add(5 * min), End: start.Add(25 * min), Extreme: 2.9},
+		}},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			got := LowEpisodes(tc.pts, Low)
+			if len(got) != len(tc.want) {
+				t.Fatalf("got %d episodes %+v, want %d", len(got), got, len(tc.want))
+			}
+			for i := range got {
+				if !got[i].Start.Equal(tc.want[i].Start) || !got[i].End.Equal(tc.want[i].End) || got[i].Extreme != tc.want[i].Extreme {
+					t.Errorf("episode %d: got %+v, want %+v", i, got[i], tc.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestHighEpisodes(t *testing.T) {
+	got := HighEpisodes(series(ReadingInterval, 8, 11, 12, 14, 11, 8, 8, 8, 8), High)
+	want := Episode{Start: start.Add(5 * time.Minute), End: start.Add(20 * time.Minute), Extreme: 14}
+	if len(got) != 1 {
+		t.Fatalf("got %d episodes %+v, want 1", len(got), got)
+	}
+	if !got[0].Start.Equal(want.Start) || !got[0].End.Equal(want.End) || got[0].Extreme != want.Extreme {
+		t.Errorf("got %+v, want %+v", got[0], want)
+	}
+	if got[0].Duration() != 15*time.Minute {
+		t.Errorf("duration: got %s, want 15m", got[0].Duration())
+	}
+}
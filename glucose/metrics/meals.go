@@ -0,0 +1,90 @@
+package metrics
+
+import (
+	"time"
+
+	"github.com/algao1/ichor/store"
+)
+
+const (
+	// MealBefore and MealAfter bound the window of readings around a meal.
+	MealBefore = 30 * time.Minute
+	MealAfter  = 240 * time.Minute
+)
+
+// MealTimes are the times of day meals are grouped by, in order.
+var MealTimes = []string{"morning", "afternoon", "evening", "night"}
+
+// mealTimeStarts are the hours each of MealTimes starts at. Night wraps
+// around midnight.
+var mealTimeStarts = []int{5, 11, 17, 22}
+
+// MealTime returns the time of day of a meal at t, in loc.
+func MealTime(t time.Time, loc *time.Location) string {
+	hour := t.In(loc).Hour()
+	name := MealTimes[len(MealTimes)-1]
+	for i, start := range mealTimeStarts {
+		if hour >= start {
+			name = MealTimes[i]
+		}
+	}
+	return name
+}
+
+// MealResponse is the change in glucose after a meal, relative to the
+// reading at the time of the meal.
+type MealResponse struct {
+	Meal     store.Carbohydrate
+	Baseline float64
+	// Readings between MealBefore the meal and MealAfter it.
+	Readings []store.TimePoint
+
+	Peak       float64 // Largest rise above the baseline.
+	TimeToPeak time.Duration
+	// Time until glucose is back at the baseline after the peak, if it was
+	// within the window.
+	TimeToBaseline time.Duration
+	Returned       bool
+}
+
+// MealResponses returns the response to each meal with a reading at its
+// time and after it. Meals are matched to the readings around them only,
+// so responses to meals close together overlap.
+func MealResponses(pts []store.TimePoint, meals []store.Carbohydrate) []MealResponse {
+	responses := make([]MealResponse, 0, len(meals))
+	for _, meal := range meals {
+		baseline, ok := At(pts, meal.Time)
+		if !ok {
+			continue
+		}
+
+		r := MealResponse{Meal: meal, Baseline: baseline}
+		for _, pt := range pts {
+			if pt.Time.Before(meal.Time.Add(-MealBefore)) || pt.Time.After(meal.Time.Add(MealAfter)) {
+				continue
+			}
+			r.Readings = append(r.Readings, pt)
+		}
+
+		var peakTime time.Time
+		for _, pt := range r.Readings {
+			if pt.Time.After(meal.Time) && (peakTime.IsZero() || pt.Value-baseline > r.Peak) {
+				r.Peak, peakTime = pt.Value-baseline, pt.Time
+			}
+		}
+		if peakTime.IsZero() {
+			continue
+		}
+		r.TimeToPeak = peakTime.Sub(meal.Time)
+
+		for _, pt := range r.Readings {
+			if pt.Time.After(peakTime) && pt.Value <= baseline {
+				r.TimeToBaseline, r.Returned = pt.Time.Sub(meal.Time), true
+				break
+			}
+		}
+
+		responses = append(responses, r)
+	}
+	return responses
+}
@@ -0,0 +1,341 @@
+// Package metrics computes glycemic metrics over glucose readings. Readings
+// are in mmol/L and sorted by time. Metrics are weighted by the time each
+// reading stands for, and gaps in the readings are not counted.
+package metrics
+
+import (
+	"math"
+	"sort"
+	"time"
+
+	"github.com/algao1/ichor/store"
+	"gonum.org/v1/gonum/stat"
+)
+
+const (
+	// ReadingInterval is the usual time between two readings.
+	ReadingInterval = 5 * time.Minute
+	// MaxGap is the longest time between two readings that is still
+	// counted. Longer gaps are missing data.
+	MaxGap = 15 * time.Minute
+	// matchTolerance is how far a reading may be from the time it is
+	// compared at, in MODD and CONGA and by At.
+	matchTolerance = ReadingInterval / 2
+)
+
+// Consensus thresholds of the time in ranges, in mmol/L.
+const (
+	VeryLow  = 3.0
+	Low      = 3.9
+	High     = 10.0
+	VeryHigh = 13.9
+)
+
+// weights returns the minutes each reading stands for: the time until the
+// next reading, or the usual reading interval before a gap and at the end.
+func weights(pts []store.TimePoint) []float64 {
+	w := make([]float64, len(pts))
+	for i := range pts {
+		d := ReadingInterval
+		if i+1 < len(pts) {
+			if gap := pts[i+1].Time.Sub(pts[i].Time); gap <= MaxGap {
+				d = gap
+			}
+		}
+		w[i] = d.Minutes()
+	}
+	return w
+}
+
+// weightedMean returns the mean of f over the readings, weighted by time.
+func weightedMean(pts []store.TimePoint, f func(v float64) float64) float64 {
+	var sum, total float64
+	for i, w := range weights(pts) {
+		sum += w * f(pts[i].Value)
+		total += w
+	}
+	if total == 0 {
+		return math.NaN()
+	}
+	return sum / total
+}
+
+// Mean returns the mean glucose.
+func Mean(pts []store.TimePoint) float64 {
+	return weightedMean(pts, func(v float64) float64 { return v })
+}
+
+// StdDev returns the standard deviation of glucose.
+func StdDev(pts []store.TimePoint) float64 {
+	mean := Mean(pts)
+	return math.Sqrt(weightedMean(pts, func(v float64) float64 { return (v - mean) * (v - mean) }))
+}
+
+// CV returns the coefficient of variation, the standard deviation over the
+// mean.
+func CV(pts []store.TimePoint) float64 {
+	return StdDev(pts) / Mean(pts)
+}
+
+// GMI returns the glucose management indicator, the HbA1c in % estimated
+// from the mean glucose.
+func GMI(pts []store.TimePoint) float64 {
+	return 3.31 + 0.02392*Mean(pts)*store.MgdlPerMmol
+}
+
+// TimeBelow returns the fraction of time spent below threshold.
+func TimeBelow(pts []store.TimePoint, threshold float64) float64 {
+	return weightedMean(pts, func(v float64) float64 { return indicator(v < threshold) })
+}
+
+// TimeAbove returns the fraction of time spent above threshold.
+func TimeAbove(pts []store.TimePoint, threshold float64) float64 {
+	return weightedMean(pts, func(v float64) float64 { return indicator(v > threshold) })
+}
+
+// TimeInRange returns the fraction of time spent between low and high,
+// inclusive.
+func TimeInRange(pts []store.TimePoint, low, high float64) float64 {
+	return weightedMean(pts, func(v float64) float64 { return indicator(v >= low && v <= high) })
+}
+
+func indicator(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+// Ranges are the fractions of time spent in each of the consensus ranges.
+type Ranges struct {
+	VeryLow  float64 // Below 3.0.
+	Low      float64 // 3.0 to 3.9.
+	InRange  float64 // 3.9 to 10.0.
+	High     float64 // 10.0 to 13.9.
+	VeryHigh float64 // Above 13.9.
+}
+
+// ConsensusRanges returns the time spent in each of the consensus ranges.
+func ConsensusRanges(pts []store.TimePoint) Ranges {
+	return Ranges{
+		VeryLow:  TimeBelow(pts, VeryLow),
+		Low:      TimeBelow(pts, Low) - TimeBelow(pts, VeryLow),
+		InRange:  TimeInRange(pts, Low, High),
+		High:     TimeAbove(pts, High) - TimeAbove(pts, VeryHigh),
+		VeryHigh: TimeAbove(pts, VeryHigh),
+	}
+}
+
+// Coverage returns the fraction of the time between start and end covered
+// by readings, such as the time the sensor was worn.
+func Coverage(pts []store.TimePoint, start, end time.Time) float64 {
+	if !end.After(start) {
+		return 0
+	}
+	var total float64
+	for _, w := range weights(pts) {
+		total += w
+	}
+	return math.Min(1, total/end.Sub(start).Minutes())
+}
+
+// riskFunction is the symmetrized glucose scale of Kovatchev et al, which
+// is negative for lows and positive for highs.
+func riskFunction(v float64) float64 {
+	return 1.509 * (math.Pow(math.Log(v*store.MgdlPerMmol), 1.084) - 5.381)
+}
+
+// LBGI returns the low blood glucose index.
+func LBGI(pts []store.TimePoint) float64 {
+	return weightedMean(pts, func(v float64) float64 {
+		f := riskFunction(v)
+		return 10 * f * f * indicator(f < 0)
+	})
+}
+
+// HBGI returns the high blood glucose index.
+func HBGI(pts []store.TimePoint) float64 {
+	return weightedMean(pts, func(v float64) float64 {
+		f := riskFunction(v)
+		return 10 * f * f * indicator(f > 0)
+	})
+}
+
+// GRI returns the glycemia risk index, from 0 to 100, which weighs the time
+// in each range outside of the target by its risk.
+func GRI(pts []store.TimePoint) float64 {
+	r := ConsensusRanges(pts)
+	hypo := 100 * (r.VeryLow + 0.8*r.Low)
+	hyper := 100 * (r.VeryHigh + 0.5*r.High)
+	return math.Min(100, 3*hypo+1.6*hyper)
+}
+
+// MAGE returns the mean amplitude of glycemic excursions: the mean rise or
+// fall between a peak and a nadir, counting only excursions larger than the
+// standard deviation. Excursions in both directions are counted.
+func MAGE(pts []store.TimePoint) float64 {
+	if len(pts) == 0 {
+		return math.NaN()
+	}
+	sd := StdDev(pts)
+
+	// Turning points, alternating between peaks and nadirs.
+	var ext []float64
+	for _, pt := range pts {
+		if len(ext) > 0 && pt.Value == ext[len(ext)-1] {
+			continue
+		}
+		if len(ext) >= 2 {
+			prev, last := ext[len(ext)-2], ext[len(ext)-1]
+			if (last-prev)*(pt.Value-last) > 0 {
+				ext[len(ext)-1] = pt.Value
+				continue
+			}
+		}
+		ext = append(ext, pt.Value)
+	}
+
+	// Excursions smaller than the standard deviation are merged into their
+	// neighbours, keeping the most extreme peak and nadir.
+	for merged := true; merged; {
+		merged = false
+		for i := 0; i+1 < len(ext); i++ {
+			if math.Abs(ext[i+1]-ext[i]) >= sd {
+				continue
+			}
+			switch {
+			case i == 0:
+				ext = ext[1:]
+			case i+2 == len(ext):
+				ext = ext[:i+1]
+			default:
+				a, b := ext[i-1], ext[i]
+				if a > b {
+					a, b = math.Max(a, ext[i+1]), math.Min(b, ext[i+2])
+				} else {
+					a, b = math.Min(a, ext[i+1]), math.Max(b, ext[i+2])
+				}
+				ext = append(append(ext[:i-1:i-1], a, b), ext[i+3:]...)
+			}
+			merged = true
+			break
+		}
+	}
+
+	if len(ext) < 2 {
+		return 0
+	}
+	var sum float64
+	for i := 1; i < len(ext); i++ {
+		sum += math.Abs(ext[i] - ext[i-1])
+	}
+	return sum / float64(len(ext)-1)
+}
+
+// At returns the reading closest to t, if any is within half the reading
+// interval.
+func At(pts []store.TimePoint, t time.Time) (float64, bool) {
+	i := sort.Search(len(pts), func(i int) bool { return !pts[i].Time.Before(t) })
+
+	best, found := matchTolerance+1, math.NaN()
+	for _, j := range []int{i - 1, i} {
+		if j < 0 || j >= len(pts) {
+			continue
+		}
+		d := pts[j].Time.Sub(t)
+		if d < 0 {
+			d = -d
+		}
+		if d < best {
+			best, found = d, pts[j].Value
+		}
+	}
+	return found, best <= matchTolerance
+}
+
+// differences returns the change in glucose over every interval of length
+// d with readings at both ends.
+func differences(pts []store.TimePoint, d time.Duration) []float64 {
+	diffs := make([]float64, 0, len(pts))
+	for _, pt := range pts {
+		if prev, ok := At(pts, pt.Time.Add(-d)); ok {
+			diffs = append(diffs, pt.Value-prev)
+		}
+	}
+	return diffs
+}
+
+// MODD returns the mean of daily differences, the mean absolute change in
+// glucose between the same times on consecutive days.
+func MODD(pts []store.TimePoint) float64 {
+	diffs := differences(pts, 24*time.Hour)
+	if len(diffs) == 0 {
+		return math.NaN()
+	}
+	var sum float64
+	for _, d := range diffs {
+		sum += math.Abs(d)
+	}
+	return sum / float64(len(diffs))
+}
+
+// CONGA returns the continuous overall net glycemic action over n, the
+// standard deviation of the changes in glucose over n.
+func CONGA(pts []store.TimePoint, n time.Duration) float64 {
+	diffs := differences(pts, n)
+	if len(diffs) < 2 {
+		return math.NaN()
+	}
+	var mean float64
+	for _, d := range diffs {
+		mean += d
+	}
+	mean /= float64(len(diffs))
+
+	var ss float64
+	for _, d := range diffs {
+		ss += (d - mean) * (d - mean)
+	}
+	return math.Sqrt(ss / float64(len(diffs)-1))
+}
+
+// Percentiles are the 5th, 25th, 50th, 75th and 95th percentiles of glucose
+// at a time of day, given as the time since midnight.
+type Percentiles struct {
+	Time time.Duration
+	P5   float64
+	P25  float64
+	P50  float64
+	P75  float64
+	P95  float64
+}
+
+// AGP returns the percentiles of the ambulatory glucose profile: the
+// readings by time of day in loc, over bins of the given width. Times are
+// the middle of each bin, and bins without readings are left out.
+func AGP(pts []store.TimePoint, loc *time.Location, width time.Duration) []Percentiles {
+	bins := make([][]float64, int((24*time.Hour)/width))
+	for _, pt := range pts {
+		hour, min, sec := pt.Time.In(loc).Clock()
+		clock := time.Duration(hour)*time.Hour + time.Duration(min)*time.Minute + time.Duration(sec)*time.Second
+		i := int(clock / width)
+		bins[i] = append(bins[i], pt.Value)
+	}
+
+	agp := make([]Percentiles, 0, len(bins))
+	for i, bin := range bins {
+		if len(bin) == 0 {
+			continue
+		}
+		sort.Float64s(bin)
+		agp = append(agp, Percentiles{
+			Time: time.Duration(i)*width + width/2,
+			P5:   stat.Quantile(0.05, stat.Empirical, bin, nil),
+			P25:  stat.Quantile(0.25, stat.Empirical, bin, nil),
+			P50:  stat.Quantile(0.5, stat.Empirical, bin, nil),
+			P75:  stat.Quantile(0.75, stat.Empirical, bin, nil),
+			P95:  stat.Quantile(0.95, stat.Empirical, bin, nil),
+		})
+	}
+	return agp
+}
@@ -0,0 +1,265 @@
+package metrics
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/algao1/ichor/store"
+)
+
+var start = time.Date(2022, 3, 1, 0, 0, 0, 0, time.UTC)
+
+// series returns readings of the given values, in mmol/L, every interval
+// from start.
+func series(interval time.Duration, values ...float64) []store.TimePoint {
+	pts := make([]store.TimePoint, len(values))
+	for i, v := range values {
+		pts[i] = store.TimePoint{Time: start.Add(time.Duration(i) * interval), Value: v}
+	}
+	return pts
+}
+
+// reading returns a reading of v, in mmol/L, at d after start.
+func reading(d time.Duration, v float64) store.TimePoint {
+	return store.TimePoint{Time: start.Add(d), Value: v}
+}
+
+func mgdl(v float64) float64 {
+	return v / store.MgdlPerMmol
+}
+
+func almostEqual(a, b, tolerance float64) bool {
+	return math.Abs(a-b) <= tolerance
+}
+
+func TestGMI(t *testing.T) {
+	tests := []struct {
+		mean float64 // mg/dL.
+		want float64 // %.
+	}{
+		{100, 5.7},
+		{150, 6.9},
+		{200, 8.1},
+	}
+
+	for _, tc := range tests {
+		// The mean is the same whichever way it is split across readings.
+		pts := series(ReadingInterval, mgdl(tc.mean-20), mgdl(tc.mean+20))
+		got := GMI(pts)
+		if math.Round(got*10)/10 != tc.want {
+			t.Errorf("GMI at %g mg/dL: got %.3f, want %.1f", tc.mean, got, tc.want)
+		}
+		if want := 3.31 + 0.02392*tc.mean; !almostEqual(got, want, 1e-9) {
+			t.Errorf("GMI at %g mg/dL: got %.6f, want %.6f", tc.mean, got, want)
+		}
+	}
+}
+
+func TestRiskIndices(t *testing.T) {
+	tests := []struct {
+		glucose  float64 // mg/dL.
+		wantLBGI float64
+		wantHBGI float64
+	}{
+		// The risk function is zero at 112.5 mg/dL, and reaches 100 at the
+		// extremes of the glucose scale.
+		{112.5, 0, 0},
+		{20, 100, 0},
+		{600, 0, 100},
+	}
+
+	for _, tc := range tests {
+		pts := series(ReadingInterval, mgdl(tc.glucose))
+		if got := LBGI(pts); !almostEqual(got, tc.wantLBGI, 0.5) {
+			t.Errorf("LBGI at %g mg/dL: got %.3f, want %g", tc.glucose, got, tc.wantLBGI)
+		}
+		if got := HBGI(pts); !almostEqual(got, tc.wantHBGI, 0.5) {
+			t.Errorf("HBGI at %g mg/dL: got %.3f, want %g", tc.glucose, got, tc.wantHBGI)
+		}
+	}
+
+	// The scale is symmetrized so that the bounds of the target range, 70
+	// and 180 mg/dL, are equally far from zero at about 0.88, and so carry
+	// the same risk (Kovatchev et al, Diabetes Care 1997).
+	if got := LBGI(series(ReadingInterval, mgdl(70))); !almostEqual(got, 7.75, 0.05) {
+		t.Errorf("LBGI at 70 mg/dL: got %.3f, want 7.75", got)
+	}
+	if got := HBGI(series(ReadingInterval, mgdl(180))); !almostEqual(got, 7.75, 0.05) {
+		t.Errorf("HBGI at 180 mg/dL: got %.3f, want 7.75", got)
+	}
+
+	// The indices are the mean risk over time, only counting lows or highs.
+	pts := series(ReadingInterval, mgdl(20), mgdl(112.5), mgdl(600), mgdl(112.5))
+	if got := LBGI(pts); !almostEqual(got, 25, 0.5) {
+		t.Errorf("LBGI: got %.3f, want 25", got)
+	}
+	if got := HBGI(pts); !almostEqual(got, 25, 0.5) {
+		t.Errorf("HBGI: got %.3f, want 25", got)
+	}
+}
+
+func TestGRI(t *testing.T) {
+	tests := []struct {
+		name   string
+		values []float64
+		want   float64
+	}{
+		{"in range", []float64{6, 6, 6, 6}, 0},
+		// 5% very low, 5% low, 5% high, 5% very high.
+		{"all ranges", []float64{2.5, 3.5, 12, 15, 6, 6, 6, 6, 6, 6, 6, 6, 6, 6, 6, 6, 6, 6, 6, 6},
+			3.0*5 + 2.4*5 + 1.6*5 + 0.8*5},
+		{"high", []float64{6, 12, 12, 12}, 0.8 * 75},
+		{"capped", []float64{2.5, 2.5, 2.5, 2.5}, 100},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := GRI(series(ReadingInterval, tc.values...)); !almostEqual(got, tc.want, 1e-9) {
+				t.Errorf("got %.3f, want %.3f", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestMAGE(t *testing.T) {
+	tests := []struct {
+		name   string
+		values []float64
+		want   float64
+	}{
+		{"flat", []float64{6, 6, 6}, 0},
+		{"swings", []float64{5, 10, 5, 10, 5}, 5},
+		{"ramps", []float64{5, 7.5, 10, 7.5, 5}, 5},
+		// The small dip from 10 to 9.8 is merged into the excursion, keeping
+		// the peak of 10.2.
+		{"small excursion", []float64{5, 10, 9.8, 10.2, 5}, 5.2},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := MAGE(series(ReadingInterval, tc.values...)); !almostEqual(got, tc.want, 1e-9) {
+				t.Errorf("got %.3f, want %.3f", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestMODD(t *testing.T) {
+	day := 24 * time.Hour
+	tests := []struct {
+		name string
+		pts  []store.TimePoint
+		want float64
+	}{
+		{"same times", []store.TimePoint{
+			reading(0, 5), reading(5*time.Minute, 6), reading(day, 8), reading(day+5*time.Minute, 8),
+		}, 2.5},
+		// Readings within half the reading interval are matched.
+		{"close times", []store.TimePoint{
+			reading(0, 5), reading(day+2*time.Minute, 4),
+		}, 1},
+		{"no match", []store.TimePoint{
+			reading(0, 5), reading(day+10*time.Minute, 4),
+		}, math.NaN()},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			got := MODD(tc.pts)
+			if math.IsNaN(tc.want) != math.IsNaN(got) || !math.IsNaN(got) && !almostEqual(got, tc.want, 1e-9) {
+				t.Errorf("got %.3f, want %.3f", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestCONGA(t *testing.T) {
+	tests := []struct {
+		name   string
+		values []float64
+		want   float64
+	}{
+		{"steady rise", []float64{5, 6, 7, 8}, 0},
+		// Changes of 1, 2 and -1 have a sample standard deviation of
+		// sqrt(7/3).
+		{"changes", []float64{5, 6, 8, 7}, math.Sqrt(7.0 / 3)},
+		{"one change", []float64{5, 6}, math.NaN()},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			got := CONGA(series(time.Hour, tc.values...), time.Hour)
+			if math.IsNaN(tc.want) != math.IsNaN(got) || !math.IsNaN(got) && !almostEqual(got, tc.want, 1e-9) {
+				t.Errorf("got %.3f, want %.3f", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestEmpty(t *testing.T) {
+	tests := []struct {
+		name string
+		f    func([]store.TimePoint) float64
+	}{
+		{"Mean", Mean},
+		{"StdDev", StdDev},
+		{"CV", CV},
+		{"GMI", GMI},
+		{"TimeInRange", func(pts []store.TimePoint) float64 { return TimeInRange(pts, Low, High) }},
+		{"LBGI", LBGI},
+		{"HBGI", HBGI},
+		{"GRI", GRI},
+		{"MAGE", MAGE},
+		{"MODD", MODD},
+		{"CONGA", func(pts []store.TimePoint) float64 { return CONGA(pts, time.Hour) }},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := tc.f(nil); !math.IsNaN(got) {
+				t.Errorf("got %g, want NaN", got)
+			}
+		})
+	}
+}
+
+func TestGaps(t *testing.T) {
+	tests := []struct {
+		name         string
+		pts          []store.TimePoint
+		wantBelow    float64
+		wantCoverage float64
+	}{
+		{"no gap", []store.TimePoint{
+			reading(0, 3.5), reading(5*time.Minute, 3.5), reading(10*time.Minute, 6), reading(15*time.Minute, 6),
+		}, 0.5, 20.0 / 80},
+		// A gap up to MaxGap is counted as the reading before it.
+		{"short gap", []store.TimePoint{
+			reading(0, 3.5), reading(10*time.Minute, 6), reading(15*time.Minute, 6), reading(20*time.Minute, 6),
+		}, 10.0 / 25, 25.0 / 80},
+		// A longer gap is missing data, and the reading before it only
+		// stands for the usual interval.
+		{"long gap", []store.TimePoint{
+			reading(0, 3.5), reading(5*time.Minute, 3.5), reading(65*time.Minute, 6), reading(70*time.Minute, 6),
+		}, 0.5, 20.0 / 80},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := TimeBelow(tc.pts, Low); !almostEqual(got, tc.wantBelow, 1e-9) {
+				t.Errorf("time below: got %.3f, want %.3f", got, tc.wantBelow)
+			}
+			if got := Coverage(tc.pts, start, start.Add(80*time.Minute)); !almostEqual(got, tc.wantCoverage, 1e-9) {
+				t.Errorf("coverage: got %.3f, want %.3f", got, tc.wantCoverage)
+			}
+		})
+	}
+
+	r := ConsensusRanges([]store.TimePoint{
+		reading(0, 2.5), reading(5*time.Minute, 6), reading(time.Hour, 6), reading(65*time.Minute, 15),
+	})
+	if r.VeryLow != 0.25 || r.InRange != 0.5 || r.VeryHigh != 0.25 || r.Low != 0 || r.High != 0 {
+		t.Errorf("got ranges %+v, want a quarter very low, half in range and a quarter very high", r)
+	}
+}
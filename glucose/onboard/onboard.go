@@ -0,0 +1,292 @@
+// Package onboard computes the insulin and carbohydrates still active in the
+// body, from the logged doses and intakes.
+package onboard
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/algao1/ichor/store"
+)
+
+const (
+	// LookBack is how far back doses and intakes can still be active.
+	LookBack = 8 * time.Hour
+	// CarbDelay is the time before carbohydrates start to be absorbed.
+	CarbDelay = 10 * time.Minute
+	// maxReadingGap is the longest gap between readings that dynamic
+	// absorption is computed over.
+	maxReadingGap = 15 * time.Minute
+)
+
+// absorptionTimes are how long carbohydrates take to be absorbed, by
+// glycemic index.
+var absorptionTimes = map[string]time.Duration{
+	store.GIFast:   2 * time.Hour,
+	store.GIMedium: 3 * time.Hour,
+	store.GISlow:   4 * time.Hour,
+}
+
+func absorptionTime(gi string) time.Duration {
+	if d, ok := absorptionTimes[gi]; ok {
+		return d
+	}
+	return absorptionTimes[store.GIMedium]
+}
+
+// Calculator computes insulin and carbs on board from a window of history.
+type Calculator struct {
+	Products   map[string]store.InsulinProduct
+	Curve      string
+	Absorption string
+
+	// Profiles and Location give the sensitivity and carb ratio used for
+	// dynamic absorption. Profiles holds every version, from oldest to
+	// latest, so that past absorption uses the profile in effect then.
+	Profiles []store.Profile
+	Location *time.Location
+
+	Doses   []store.Insulin
+	Carbs   []store.Carbohydrate
+	Glucose []store.TimePoint
+}
+
+// New loads the history needed to compute insulin and carbs on board at
+// any time between start and end.
+func New(s *store.Store, start, end time.Time) (*Calculator, error) {
+	var conf store.Config
+	if err := s.GetObject(store.IndexConfig, &conf); err != nil {
+		return nil, fmt.Errorf("unable to load config: %w", err)
+	}
+
+	var products []store.InsulinProduct
+	if err := s.GetObject(store.IndexInsulinProducts, &products); err != nil {
+		return nil, fmt.Errorf("unable to load insulin products: %w", err)
+	}
+
+	profiles, err := s.GetProfiles()
+	if err != nil {
+		return nil, err
+	}
+
+	c := &Calculator{
+		Products:   make(map[string]store.InsulinProduct),
+		Curve:      conf.InsulinCurve,
+		Absorption: conf.CarbAbsorption,
+		Profiles:   profiles,
+		Location:   conf.Location(),
+	}
+	for _, p := range products {
+		c.Products[p.Name] = p
+	}
+
+	if err := s.GetPoints(start.Add(-LookBack), end, store.FieldInsulin, &c.Doses); err != nil {
+		return nil, fmt.Errorf("unable to get insulin doses: %w", err)
+	}
+	if err := s.GetPoints(start.Add(-LookBack), end, store.FieldCarbohydrate, &c.Carbs); err != nil {
+		return nil, fmt.Errorf("unable to get carbohydrates: %w", err)
+	}
+	if err := s.GetPoints(start.Add(-LookBack), end, store.FieldGlucose, &c.Glucose); err != nil {
+		return nil, fmt.Errorf("unable to get points: %w", err)
+	}
+
+	return c, nil
+}
+
+// IOB returns the units of insulin on board at t. Only rapid-acting doses
+// are counted, since basal insulin covers background needs rather than
+// meals and corrections.
+func (c *Calculator) IOB(t time.Time) float64 {
+	var iob float64
+	for _, dose := range c.Doses {
+		if dose.Type != store.RapidActing && dose.Type != store.UltraRapidActing {
+			continue
+		}
+		if dose.Time.After(t) {
+			continue
+		}
+
+		p, ok := c.Products[dose.Product]
+		if !ok {
+			continue
+		}
+
+		elapsed := t.Sub(dose.Time)
+		if c.Curve == store.CurveWalsh {
+			iob += dose.Value * walsh(elapsed, p.Duration)
+		} else {
+			iob += dose.Value * exponential(elapsed, p)
+		}
+	}
+	return iob
+}
+
+// COB returns the grams of carbohydrates on board at t.
+func (c *Calculator) COB(t time.Time) float64 {
+	if c.Absorption == store.AbsorptionDynamic {
+		return c.dynamicCOB(t)
+	}
+
+	var cob float64
+	for _, carb := range c.Carbs {
+		if carb.Time.After(t) {
+			continue
+		}
+		cob += linear(float64(carb.Value), t.Sub(carb.Time), absorptionTime(carb.GI))
+	}
+	return cob
+}
+
+// dynamicCOB absorbs carbohydrates according to the rise in glucose not
+// explained by insulin, oldest intake first. Each intake is absorbed at
+// least as fast as it would be linearly over 1.5 times its absorption time,
+// so that it wears off even when glucose does not rise.
+func (c *Calculator) dynamicCOB(t time.Time) float64 {
+	carbs := make([]store.Carbohydrate, 0, len(c.Carbs))
+	for _, carb := range c.Carbs {
+		if !carb.Time.After(t) {
+			carbs = append(carbs, carb)
+		}
+	}
+	sort.Slice(carbs, func(i, j int) bool { return carbs[i].Time.Before(carbs[j].Time) })
+	if len(carbs) == 0 {
+		return 0
+	}
+
+	remaining := make([]float64, len(carbs))
+	for i, carb := range carbs {
+		remaining[i] = float64(carb.Value)
+	}
+
+	// Readings are walked from the first intake, so that absorption is
+	// accounted for between readings too.
+	prev := carbs[0].Time
+	prevValue := math.NaN()
+	for _, pt := range c.Glucose {
+		if pt.Time.Before(carbs[0].Time) {
+			prevValue = pt.Value
+			prev = pt.Time
+			continue
+		}
+		if pt.Time.After(t) {
+			break
+		}
+		c.absorb(carbs, remaining, prev, pt.Time, prevValue, pt.Value)
+		prev, prevValue = pt.Time, pt.Value
+	}
+	c.absorb(carbs, remaining, prev, t, math.NaN(), math.NaN())
+
+	var cob float64
+	for _, r := range remaining {
+		cob += r
+	}
+	return cob
+}
+
+// absorb removes the carbohydrates absorbed between from and to from
+// remaining. The glucose values at both ends are NaN if unknown.
+func (c *Calculator) absorb(carbs []store.Carbohydrate, remaining []float64, from, to time.Time, fromValue, toValue float64) {
+	dt := to.Sub(from)
+	if dt <= 0 {
+		return
+	}
+
+	// Grams of carbohydrates that explain the rise in glucose not caused
+	// by insulin, when the readings are close enough to trust.
+	var observed float64
+	if !math.IsNaN(fromValue) && !math.IsNaN(toValue) && dt <= maxReadingGap {
+		profile := store.ProfileAt(c.Profiles, from)
+		isf := profile.Sensitivities.At(from, c.Location)
+		insulinEffect := -(c.IOB(from) - c.IOB(to)) * isf
+		deviation := (toValue - fromValue) - insulinEffect
+		observed = math.Max(deviation, 0) * profile.CarbRatios.At(from, c.Location) / isf
+	}
+
+	for i, carb := range carbs {
+		start := carb.Time.Add(CarbDelay)
+		if remaining[i] <= 0 || !to.After(start) {
+			continue
+		}
+
+		active := to.Sub(start)
+		if active > dt {
+			active = dt
+		}
+		minimum := float64(carb.Value) * active.Minutes() / (1.5 * absorptionTime(carb.GI).Minutes())
+
+		absorbed := math.Min(remaining[i], math.Max(observed, minimum))
+		remaining[i] -= absorbed
+		observed = math.Max(observed-absorbed, 0)
+	}
+}
+
+// linear returns the carbohydrates left of amount after elapsed, absorbed at
+// a constant rate over the absorption time.
+func linear(amount float64, elapsed, absorption time.Duration) float64 {
+	elapsed -= CarbDelay
+	if elapsed <= 0 {
+		return amount
+	}
+	if elapsed >= absorption {
+		return 0
+	}
+	return amount * (1 - float64(elapsed)/float64(absorption))
+}
+
+// exponential returns the fraction of a dose left after elapsed, using the
+// exponential activity curve with the product's peak and duration, both
+// counted from its onset. Peakless products, and peaks too late for the
+// curve, decay linearly instead.
+func exponential(elapsed time.Duration, p store.InsulinProduct) float64 {
+	t := (elapsed - p.Onset).Minutes()
+	dia := (p.Duration - p.Onset).Minutes()
+	peak := (p.Peak - p.Onset).Minutes()
+
+	if t <= 0 {
+		return 1
+	}
+	if t >= dia {
+		return 0
+	}
+	if p.Peak == 0 || peak <= 0 || peak >= dia/2 {
+		return 1 - t/dia
+	}
+
+	tau := peak * (1 - peak/dia) / (1 - 2*peak/dia)
+	a := 2 * tau / dia
+	s := 1 / (1 - a + (1+a)*math.Exp(-dia/tau))
+
+	iob := 1 - s*(1-a)*((t*t/(tau*dia*(1-a))-t/tau-1)*math.Exp(-t/tau)+1)
+	return math.Max(0, math.Min(1, iob))
+}
+
+// walsh returns the fraction of a dose left after elapsed, using the Walsh
+// curves for 3 to 6 hour durations. Other durations are clamped to that
+// range, and scaled from the nearest shorter curve.
+func walsh(elapsed, duration time.Duration) float64 {
+	if elapsed <= 0 {
+		return 1
+	}
+	if elapsed >= duration {
+		return 0
+	}
+
+	hours := math.Floor(duration.Hours())
+	hours = math.Max(3, math.Min(6, hours))
+	t := elapsed.Minutes() * hours * 60 / duration.Minutes()
+
+	var iob float64
+	switch hours {
+	case 3:
+		iob = -3.203e-9*math.Pow(t, 4) + 1.354e-6*math.Pow(t, 3) - 1.759e-4*t*t + 9.255e-4*t + 0.99951
+	case 4:
+		iob = -3.31e-10*math.Pow(t, 4) + 2.53e-7*math.Pow(t, 3) - 5.51e-5*t*t - 9.086e-4*t + 0.9995
+	case 5:
+		iob = -2.95e-10*math.Pow(t, 4) + 2.32e-7*math.Pow(t, 3) - 5.55e-5*t*t + 4.49e-4*t + 0.993
+	default:
+		iob = -1.493e-10*math.Pow(t, 4) + 1.413e-7*math.Pow(t, 3) - 4.095e-5*t*t + 6.365e-4*t + 0.997
+	}
+	return math.Max(0, math.Min(1, iob))
+}
@@ -0,0 +1,165 @@
+package onboard
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/algao1/ichor/store"
+)
+
+var start = time.Date(2022, 3, 1, 12, 0, 0, 0, time.UTC)
+
+func almostEqual(a, b, tolerance float64) bool {
+	return math.Abs(a-b) <= tolerance
+}
+
+func TestIOB(t *testing.T) {
+	// The rapid-acting and ultra-rapid defaults of oref0, which has no onset.
+	rapid := store.InsulinProduct{Name: "rapid", Category: store.RapidActing, Peak: 75 * time.Minute, Duration: 5 * time.Hour}
+	ultra := store.InsulinProduct{Name: "ultra", Category: store.UltraRapidActing, Peak: 55 * time.Minute, Duration: 5 * time.Hour}
+	// The same curve as rapid, starting 15 minutes later.
+	delayed := store.InsulinProduct{Name: "delayed", Category: store.RapidActing, Onset: 15 * time.Minute, Peak: 90 * time.Minute, Duration: 5*time.Hour + 15*time.Minute}
+	short := store.InsulinProduct{Name: "short", Category: store.RapidActing, Peak: time.Hour, Duration: 3 * time.Hour}
+	regular := store.InsulinProduct{Name: "regular", Category: store.RapidActing, Peak: time.Hour, Duration: 4 * time.Hour}
+
+	tests := []struct {
+		name    string
+		curve   string
+		product store.InsulinProduct
+		elapsed time.Duration
+		want    float64 // Fraction of the dose left.
+	}{
+		// Values of the oref0 exponential curve.
+		{"exponential at 0", store.CurveExponential, rapid, 0, 1},
+		{"exponential at peak", store.CurveExponential, rapid, 75 * time.Minute, 0.6726},
+		{"exponential halfway", store.CurveExponential, rapid, 150 * time.Minute, 0.2681},
+		{"exponential at DIA", store.CurveExponential, rapid, 5 * time.Hour, 0},
+		{"ultra-rapid at peak", store.CurveExponential, ultra, 55 * time.Minute, 0.7054},
+		{"ultra-rapid halfway", store.CurveExponential, ultra, 150 * time.Minute, 0.1676},
+		{"before onset", store.CurveExponential, delayed, 15 * time.Minute, 1},
+		{"peak after onset", store.CurveExponential, delayed, 90 * time.Minute, 0.6726},
+		{"DIA after onset", store.CurveExponential, delayed, 5*time.Hour + 15*time.Minute, 0},
+
+		// Values of the Walsh curves for 3 and 4 hours.
+		{"walsh at 0", store.CurveWalsh, short, 0, 1},
+		{"walsh 3h at 1h", store.CurveWalsh, short, time.Hour, 0.6728},
+		{"walsh 3h at 2h", store.CurveWalsh, short, 2 * time.Hour, 0.2531},
+		{"walsh 4h at 2h", store.CurveWalsh, regular, 2 * time.Hour, 0.4656},
+		{"walsh at DIA", store.CurveWalsh, short, 3 * time.Hour, 0},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			c := &Calculator{
+				Products: map[string]store.InsulinProduct{tc.product.Name: tc.product},
+				Curve:    tc.curve,
+				Doses: []store.Insulin{
+					{Time: start, Type: tc.product.Category, Product: tc.product.Name, Value: 2},
+				},
+			}
+			if got := c.IOB(start.Add(tc.elapsed)); !almostEqual(got, 2*tc.want, 1e-3) {
+				t.Errorf("got %.4f, want %.4f", got, 2*tc.want)
+			}
+		})
+	}
+}
+
+func TestIOBSkipsDoses(t *testing.T) {
+	lispro := store.InsulinProduct{Name: "lispro", Category: store.RapidActing, Peak: 75 * time.Minute, Duration: 5 * time.Hour}
+	c := &Calculator{
+		Products: map[string]store.InsulinProduct{lispro.Name: lispro},
+		Curve:    store.CurveExponential,
+		Doses: []store.Insulin{
+			{Time: start, Type: store.LongActing, Product: lispro.Name, Value: 10},
+			{Time: start, Type: store.RapidActing, Product: "unknown", Value: 1},
+			{Time: start.Add(time.Hour), Type: store.RapidActing, Product: lispro.Name, Value: 1},
+		},
+	}
+	// Long-acting doses, unknown products and later doses are not counted.
+	if got := c.IOB(start.Add(30 * time.Minute)); got != 0 {
+		t.Errorf("got %.4f, want 0", got)
+	}
+}
+
+func TestLinearCOB(t *testing.T) {
+	tests := []struct {
+		gi      string
+		elapsed time.Duration
+		want    float64
+	}{
+		{store.GIFast, CarbDelay, 30},
+		{store.GIFast, CarbDelay + time.Hour, 15},
+		{store.GIFast, CarbDelay + 2*time.Hour, 0},
+		{store.GIMedium, CarbDelay + 90*time.Minute, 15},
+		{store.GIMedium, CarbDelay + 3*time.Hour, 0},
+		{store.GISlow, CarbDelay + 2*time.Hour, 15},
+		{store.GISlow, CarbDelay + 4*time.Hour, 0},
+		// Intakes without a glycemic index are absorbed as medium ones.
+		{"", CarbDelay + 90*time.Minute, 15},
+	}
+
+	for _, tc := range tests {
+		c := &Calculator{
+			Absorption: store.AbsorptionLinear,
+			Carbs:      []store.Carbohydrate{{Time: start, Value: 30, GI: tc.gi}},
+		}
+		if got := c.COB(start.Add(tc.elapsed)); !almostEqual(got, tc.want, 1e-9) {
+			t.Errorf("%q after %s: got %.3f, want %.3f", tc.gi, tc.elapsed, got, tc.want)
+		}
+	}
+}
+
+func TestDynamicCOB(t *testing.T) {
+	// 1 mmol/L of glucose is explained by 5 grams of carbohydrates.
+	profiles := []store.Profile{{
+		CarbRatios:    store.Schedule{{Start: 0, Value: 10}},
+		Sensitivities: store.Schedule{{Start: 0, Value: 2}},
+	}}
+
+	// Readings every 5 minutes for the hour after the intake, rising by
+	// 0.5 mmol/L every 5 minutes once absorption starts.
+	var rising []store.TimePoint
+	for d := -5 * time.Minute; d <= time.Hour; d += 5 * time.Minute {
+		rising = append(rising, store.TimePoint{
+			Time:  start.Add(d),
+			Value: 5 + 0.5*math.Max(0, float64((d-CarbDelay)/(5*time.Minute))),
+		})
+	}
+	var gap []store.TimePoint
+	for _, pt := range rising {
+		if d := pt.Time.Sub(start); d <= 20*time.Minute || d >= 50*time.Minute {
+			gap = append(gap, pt)
+		}
+	}
+
+	tests := []struct {
+		name    string
+		glucose []store.TimePoint
+		want    float64
+	}{
+		// 2.5 grams are absorbed in each of the 10 intervals.
+		{"readings", rising, 5},
+		// Over the 30 minute gap, only the minimum of 30 grams over 4.5
+		// hours is absorbed.
+		{"gap", gap, 30 - 4*2.5 - 30*30.0/270},
+		// Without readings, the minimum is absorbed over the 50 minutes after
+		// the delay.
+		{"no readings", nil, 30 - 30*50.0/270},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			c := &Calculator{
+				Absorption: store.AbsorptionDynamic,
+				Profiles:   profiles,
+				Location:   time.UTC,
+				Carbs:      []store.Carbohydrate{{Time: start, Value: 30, GI: store.GIMedium}},
+				Glucose:    tc.glucose,
+			}
+			if got := c.COB(start.Add(time.Hour)); !almostEqual(got, tc.want, 1e-9) {
+				t.Errorf("got %.3f, want %.3f", got, tc.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,374 @@
+// Package patterns looks for patterns that recur over days of glucose,
+// carbohydrate and insulin data, such as lows at the same time of night.
+package patterns
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/algao1/ichor/glucose/metrics"
+	"github.com/algao1/ichor/store"
+)
+
+// DefaultDays is the number of days scanned for patterns by default.
+const DefaultDays = 14
+
+const (
+	// MinOccurrences is the number of times a pattern must be seen to be
+	// reported.
+	MinOccurrences = 3
+
+	// nightStart and nightEnd are the hours overnight lows may start
+	// between.
+	nightStart = 22
+	nightEnd   = 7
+	// lowWindow is the width of the time of day window lows must recur in.
+	lowWindow = 2 * time.Hour
+
+	// dawnStart and dawnEnd bound the hours the dawn phenomenon is looked
+	// for in, and dawnRise is the smallest rise between them counted.
+	dawnStart = 4 * time.Hour
+	dawnEnd   = 8 * time.Hour
+	dawnRise  = 1.0
+	// dawnFasting is how long before dawnStart no carbohydrates may have
+	// been logged for the morning to be counted.
+	dawnFasting = 3 * time.Hour
+
+	// maxDoseSpread is how far apart in time of day doses of long-acting
+	// insulin may be before they are reported.
+	maxDoseSpread = 2 * time.Hour
+
+	// evidenceMargin is the time shown around the window of a pattern.
+	evidenceMargin = time.Hour
+)
+
+// Kinds of findings.
+const (
+	KindOvernightLows  = "overnight-lows"
+	KindDawn           = "dawn-phenomenon"
+	KindPostMealSpikes = "post-meal-spikes"
+	KindBasalDrift     = "basal-drift"
+)
+
+// Window is a period of readings supporting a finding.
+type Window struct {
+	Start time.Time
+	End   time.Time
+}
+
+// Finding is a recurring pattern, with the evidence for it.
+type Finding struct {
+	Kind string
+	// Times of day the pattern was seen between, as the time since
+	// midnight. From is after To when the pattern spans midnight.
+	From time.Duration
+	To   time.Duration
+	// Time of day of the meals, for post-meal spikes.
+	Meal string
+
+	// Nights, mornings, meals or doses the pattern was seen on, out of those
+	// checked.
+	Occurrences int
+	Checked     int
+	// Median rise for the dawn phenomenon, or median peak for post-meal
+	// spikes, in mmol/L.
+	Value float64
+
+	Windows []Window
+	Meals   []metrics.MealResponse
+	Doses   []store.Insulin
+}
+
+// Detect scans the days between start and end for patterns.
+func Detect(s *store.Store, start, end time.Time) ([]Finding, error) {
+	var conf store.Config
+	if err := s.GetObject(store.IndexConfig, &conf); err != nil {
+		return nil, fmt.Errorf("unable to load config: %w", err)
+	}
+	loc := conf.Location()
+
+	var pts []store.TimePoint
+	if err := s.GetPoints(start, end, store.FieldGlucose, &pts); err != nil {
+		return nil, fmt.Errorf("unable to get points: %w", err)
+	}
+
+	var carbs []store.Carbohydrate
+	if err := s.GetPoints(start, end, store.FieldCarbohydrate, &carbs); err != nil {
+		return nil, fmt.Errorf("unable to get carbohydrates: %w", err)
+	}
+
+	var insulin []store.Insulin
+	if err := s.GetPoints(start, end, store.FieldInsulin, &insulin); err != nil {
+		return nil, fmt.Errorf("unable to get insulin doses: %w", err)
+	}
+
+	findings := make([]Finding, 0)
+	if f, ok := overnightLows(pts, conf.LowThreshold, loc); ok {
+		findings = append(findings, f)
+	}
+	if f, ok := dawnPhenomenon(pts, carbs, start, end, loc); ok {
+		findings = append(findings, f)
+	}
+	findings = append(findings, postMealSpikes(pts, carbs, conf.HighThreshold, loc)...)
+	if f, ok := basalDrift(insulin, loc); ok {
+		findings = append(findings, f)
+	}
+
+	return findings, nil
+}
+
+// clock returns the time since midnight of t, in loc.
+func clock(t time.Time, loc *time.Location) time.Duration {
+	t = t.In(loc)
+	return time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute
+}
+
+// at returns the time on the day of t, in loc, at the given time since
+// midnight.
+func at(t time.Time, d time.Duration, loc *time.Location) time.Time {
+	t = t.In(loc)
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, int(d.Minutes()), 0, 0, loc)
+}
+
+// nightOf returns the date of the evening a night starts on, so that lows on
+// both sides of midnight are counted on the same night.
+func nightOf(t time.Time, loc *time.Location) string {
+	return t.In(loc).Add(-12 * time.Hour).Format("2006-01-02")
+}
+
+// median returns the median of vs, which must not be empty.
+func median(vs []float64) float64 {
+	sorted := append([]float64(nil), vs...)
+	sort.Float64s(sorted)
+	if n := len(sorted); n%2 == 0 {
+		return (sorted[n/2-1] + sorted[n/2]) / 2
+	}
+	return sorted[len(sorted)/2]
+}
+
+// overnightLows looks for low episodes starting in the same window of the
+// night on several nights, and returns the window seen on the most nights.
+func overnightLows(pts []store.TimePoint, threshold float64, loc *time.Location) (Finding, bool) {
+	episodes := metrics.LowEpisodes(pts, threshold)
+
+	nights := make(map[string]bool)
+	for _, pt := range pts {
+		nights[nightOf(pt.Time, loc)] = true
+	}
+
+	var best Finding
+	for h := nightStart; h != nightEnd; h = (h + 1) % 24 {
+		from := time.Duration(h) * time.Hour
+		to := (from + lowWindow) % (24 * time.Hour)
+
+		seen := make(map[string]bool)
+		var windows []Window
+		for _, e := range episodes {
+			c := clock(e.Start, loc)
+			if (c-from+24*time.Hour)%(24*time.Hour) >= lowWindow || seen[nightOf(e.Start, loc)] {
+				continue
+			}
+			seen[nightOf(e.Start, loc)] = true
+
+			start := at(e.Start, from, loc)
+			if start.After(e.Start) {
+				start = start.AddDate(0, 0, -1)
+			}
+			windows = append(windows, Window{
+				Start: start.Add(-evidenceMargin),
+				End:   start.Add(lowWindow + evidenceMargin),
+			})
+		}
+
+		if len(windows) > best.Occurrences {
+			best = Finding{
+				Kind:        KindOvernightLows,
+				From:        from,
+				To:          to,
+				Occurrences: len(windows),
+				Checked:     len(nights),
+				Windows:     windows,
+			}
+		}
+	}
+
+	return best, best.Occurrences >= MinOccurrences
+}
+
+// dawnPhenomenon looks for a rise in glucose early in the morning, before
+// any carbohydrates, on most mornings.
+func dawnPhenomenon(pts []store.TimePoint, carbs []store.Carbohydrate, start, end time.Time,
+	loc *time.Location) (Finding, bool) {
+	f := Finding{Kind: KindDawn, From: dawnStart, To: dawnEnd}
+
+	var rises []float64
+	for day := at(start, 0, loc); day.Before(end); day = day.AddDate(0, 0, 1) {
+		from, to := at(day, dawnStart, loc), at(day, dawnEnd, loc)
+
+		fasting := true
+		for _, carb := range carbs {
+			if !carb.Time.Before(from.Add(-dawnFasting)) && !carb.Time.After(to) {
+				fasting = false
+				break
+			}
+		}
+		if !fasting {
+			continue
+		}
+
+		v1, ok1 := metrics.At(pts, from)
+		v2, ok2 := metrics.At(pts, to)
+		if !ok1 || !ok2 {
+			continue
+		}
+
+		f.Checked++
+		if v2-v1 >= dawnRise {
+			rises = append(rises, v2-v1)
+			f.Windows = append(f.Windows, Window{Start: from.Add(-evidenceMargin), End: to.Add(evidenceMargin)})
+		}
+	}
+
+	f.Occurrences = len(rises)
+	if f.Occurrences < MinOccurrences || 2*f.Occurrences < f.Checked {
+		return f, false
+	}
+	f.Value = median(rises)
+	return f, true
+}
+
+// postMealSpikes looks for meals at the same time of day that are followed
+// by a high more often than not.
+func postMealSpikes(pts []store.TimePoint, carbs []store.Carbohydrate, threshold float64,
+	loc *time.Location) []Finding {
+	responses := metrics.MealResponses(pts, carbs)
+
+	findings := make([]Finding, 0)
+	for _, name := range metrics.MealTimes {
+		f := Finding{Kind: KindPostMealSpikes, Meal: name}
+
+		var peaks []float64
+		for _, r := range responses {
+			if metrics.MealTime(r.Meal.Time, loc) != name {
+				continue
+			}
+			f.Checked++
+			if r.Baseline+r.Peak > threshold {
+				peaks = append(peaks, r.Baseline+r.Peak)
+				f.Meals = append(f.Meals, r)
+			}
+		}
+
+		f.Occurrences = len(peaks)
+		if f.Occurrences < MinOccurrences || 2*f.Occurrences < f.Checked {
+			continue
+		}
+		f.Value = median(peaks)
+		findings = append(findings, f)
+	}
+
+	return findings
+}
+
+// basalDrift looks for doses of long-acting insulin taken at times of day
+// far apart, which leaves gaps or overlaps in basal coverage. Doses are
+// compared within daily slots, as many as doses are usually taken a day, so
+// that a twice-daily regimen is not mistaken for drift.
+func basalDrift(insulin []store.Insulin, loc *time.Location) (Finding, bool) {
+	f := Finding{Kind: KindBasalDrift}
+	perDay := make(map[string]int)
+	for _, dose := range insulin {
+		if dose.Type == store.IntermediateActing || dose.Type == store.LongActing {
+			f.Doses = append(f.Doses, dose)
+			perDay[dose.Time.In(loc).Format("2006-01-02")]++
+		}
+	}
+	f.Checked = len(f.Doses)
+	if f.Checked < MinOccurrences {
+		return f, false
+	}
+
+	counts := make([]float64, 0, len(perDay))
+	for _, n := range perDay {
+		counts = append(counts, float64(n))
+	}
+	n := int(math.Round(median(counts)))
+
+	// The slot with the widest spread of times is reported.
+	var widest []time.Duration
+	for _, slot := range doseSlots(f.Doses, n, loc) {
+		minutes := make([]float64, len(slot))
+		for i, t := range slot {
+			minutes[i] = t.Minutes()
+		}
+		mid := median(minutes)
+		for _, m := range minutes {
+			if math.Abs(m-mid) > maxDoseSpread.Minutes()/2 {
+				f.Occurrences++
+			}
+		}
+
+		if widest == nil || slot[len(slot)-1]-slot[0] > widest[len(widest)-1]-widest[0] {
+			widest = slot
+		}
+	}
+
+	day := 24 * time.Hour
+	f.From = widest[0] % day
+	f.To = widest[len(widest)-1] % day
+	return f, widest[len(widest)-1]-widest[0] > maxDoseSpread
+}
+
+// doseSlots groups the times of day of doses into n slots, by cutting the
+// day at the n longest gaps between doses. The times of each slot are
+// sorted, and those past midnight in a slot spanning it are over a day.
+func doseSlots(doses []store.Insulin, n int, loc *time.Location) [][]time.Duration {
+	day := 24 * time.Hour
+	times := make([]time.Duration, len(doses))
+	for i, dose := range doses {
+		times[i] = clock(dose.Time, loc)
+	}
+	sort.Slice(times, func(i, j int) bool { return times[i] < times[j] })
+	if n < 1 {
+		n = 1
+	} else if n > len(times) {
+		n = len(times)
+	}
+
+	// The gap after each time, the last one wrapping around midnight.
+	gaps := make([]time.Duration, len(times))
+	order := make([]int, len(times))
+	for i := range times {
+		next := times[(i+1)%len(times)]
+		if i == len(times)-1 {
+			next += day
+		}
+		gaps[i] = next - times[i]
+		order[i] = i
+	}
+	sort.SliceStable(order, func(a, b int) bool { return gaps[order[a]] > gaps[order[b]] })
+	cut := make([]bool, len(times))
+	for _, i := range order[:n] {
+		cut[i] = true
+	}
+
+	// Start after a cut, so that no slot is split.
+	first := (order[0] + 1) % len(times)
+	slots := make([][]time.Duration, 0, n)
+	var slot []time.Duration
+	for k := range times {
+		i := (first + k) % len(times)
+		t := times[i]
+		if i < first {
+			t += day
+		}
+		slot = append(slot, t)
+		if cut[i] {
+			slots = append(slots, slot)
+			slot = nil
+		}
+	}
+	return slots
+}
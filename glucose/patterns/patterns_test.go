@@ -0,0 +1,189 @@
+package patterns
+
+import (
+	"testing"
+	"time"
+
+	"github.com/algao1/ichor/glucose/metrics"
+	"github.com/algao1/ichor/store"
+)
+
+var start = time.Date(2022, 3, 1, 0, 0, 0, 0, time.UTC)
+
+// readings returns readings every 5 minutes from d after the start of each
+// day, for the given duration, of the value returned by f.
+func readings(days int, from, duration time.Duration, f func(day int, t time.Duration) float64) []store.TimePoint {
+	var pts []store.TimePoint
+	for day := 0; day < days; day++ {
+		for t := from; t <= from+duration; t += metrics.ReadingInterval {
+			pts = append(pts, store.TimePoint{
+				Time:  start.AddDate(0, 0, day).Add(t),
+				Value: f(day, t),
+			})
+		}
+	}
+	return pts
+}
+
+// doses returns long-acting doses at the given times of day, each on the
+// day of its index.
+func doses(perDay int, times ...time.Duration) []store.Insulin {
+	insulin := make([]store.Insulin, len(times))
+	for i, t := range times {
+		insulin[i] = store.Insulin{
+			Time:  start.AddDate(0, 0, i/perDay).Add(t),
+			Type:  store.LongActing,
+			Value: 10,
+		}
+	}
+	return insulin
+}
+
+func TestOvernightLows(t *testing.T) {
+	tests := []struct {
+		name     string
+		lowNight []bool
+		wantOK   bool
+	}{
+		{"three of four nights", []bool{true, false, true, true}, true},
+		{"two of four nights", []bool{true, false, false, true}, false},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			// Readings from 22:00 to 07:00, low from 02:00 to 02:40.
+			pts := readings(len(tc.lowNight), 22*time.Hour, 9*time.Hour, func(day int, t time.Duration) float64 {
+				if tc.lowNight[day] && t >= 26*time.Hour && t <= 26*time.Hour+40*time.Minute {
+					return 3.2
+				}
+				return 6
+			})
+
+			f, ok := overnightLows(pts, 3.9, time.UTC)
+			if ok != tc.wantOK {
+				t.Fatalf("got found %t, want %t", ok, tc.wantOK)
+			}
+			if !ok {
+				return
+			}
+			// The first window holding the most lows is reported.
+			if f.From != time.Hour || f.To != 3*time.Hour {
+				t.Errorf("got window %s to %s, want 1h0m0s to 3h0m0s", f.From, f.To)
+			}
+			if f.Occurrences != 3 || f.Checked != 4 {
+				t.Errorf("got %d of %d nights, want 3 of 4", f.Occurrences, f.Checked)
+			}
+			if len(f.Windows) != 3 {
+				t.Errorf("got %d windows, want 3", len(f.Windows))
+			}
+		})
+	}
+}
+
+func TestDawnPhenomenon(t *testing.T) {
+	// Readings from 03:00 to 09:00, rising by 2 mmol/L from 04:00 to 08:00
+	// on all but the second morning.
+	pts := readings(4, 3*time.Hour, 6*time.Hour, func(day int, t time.Duration) float64 {
+		if day == 1 || t < dawnStart {
+			return 5
+		}
+		if t > dawnEnd {
+			return 7
+		}
+		return 5 + 2*float64(t-dawnStart)/float64(dawnEnd-dawnStart)
+	})
+	end := start.AddDate(0, 0, 4)
+
+	f, ok := dawnPhenomenon(pts, nil, start, end, time.UTC)
+	if !ok {
+		t.Fatal("got no finding, want one")
+	}
+	if f.Occurrences != 3 || f.Checked != 4 {
+		t.Errorf("got %d of %d mornings, want 3 of 4", f.Occurrences, f.Checked)
+	}
+	if f.Value != 2 {
+		t.Errorf("got median rise %.2f, want 2", f.Value)
+	}
+
+	// Mornings with carbohydrates shortly before are not counted, which
+	// leaves too few rises.
+	carbs := []store.Carbohydrate{{Time: start.AddDate(0, 0, 2).Add(2 * time.Hour), Value: 15}}
+	f, ok = dawnPhenomenon(pts, carbs, start, end, time.UTC)
+	if ok {
+		t.Errorf("got a finding with %d of %d mornings, want none", f.Occurrences, f.Checked)
+	}
+	if f.Checked != 3 {
+		t.Errorf("got %d mornings checked, want 3", f.Checked)
+	}
+}
+
+func TestPostMealSpikes(t *testing.T) {
+	// Breakfast at 08:00 every day, peaking at 09:00.
+	peaks := []float64{12, 8, 13, 11}
+	pts := readings(len(peaks), 7*time.Hour, 5*time.Hour, func(day int, t time.Duration) float64 {
+		if t == 9*time.Hour {
+			return peaks[day]
+		}
+		return 6
+	})
+	var carbs []store.Carbohydrate
+	for day := range peaks {
+		carbs = append(carbs, store.Carbohydrate{Time: start.AddDate(0, 0, day).Add(8 * time.Hour), Value: 50})
+	}
+
+	findings := postMealSpikes(pts, carbs, 10, time.UTC)
+	if len(findings) != 1 {
+		t.Fatalf("got %d findings, want 1", len(findings))
+	}
+	f := findings[0]
+	if f.Meal != "morning" {
+		t.Errorf("got meal %q, want morning", f.Meal)
+	}
+	if f.Occurrences != 3 || f.Checked != 4 {
+		t.Errorf("got %d of %d meals, want 3 of 4", f.Occurrences, f.Checked)
+	}
+	if f.Value != 12 {
+		t.Errorf("got median peak %.2f, want 12", f.Value)
+	}
+
+	// Below the threshold after most meals, nothing is reported.
+	if findings := postMealSpikes(pts, carbs, 12.5, time.UTC); len(findings) != 0 {
+		t.Errorf("got %d findings above 12.5, want none", len(findings))
+	}
+}
+
+func TestBasalDrift(t *testing.T) {
+	h := time.Hour
+	m := time.Minute
+	tests := []struct {
+		name     string
+		insulin  []store.Insulin
+		wantOK   bool
+		wantFrom time.Duration
+		wantTo   time.Duration
+	}{
+		{"steady", doses(1, 22*h, 22*h+30*m, 21*h+45*m, 22*h), false, 0, 0},
+		{"drifting", doses(1, 8*h, 9*h+30*m, 11*h, 8*h), true, 8 * h, 11 * h},
+		{"around midnight", doses(1, 23*h+30*m, 30*m, 23*h+45*m, 0), false, 0, 0},
+		{"twice daily", doses(2, 8*h, 20*h, 8*h+15*m, 20*h, 7*h+45*m, 19*h+30*m), false, 0, 0},
+		{"twice daily drifting", doses(2, 8*h, 20*h, 8*h, 22*h+30*m, 8*h+15*m, 19*h+45*m), true, 19*h + 45*m, 22*h + 30*m},
+		{"too few", doses(1, 8*h, 14*h), false, 0, 0},
+		{"rapid-acting", []store.Insulin{
+			{Time: start.Add(8 * h), Type: store.RapidActing},
+			{Time: start.Add(13 * h), Type: store.RapidActing},
+			{Time: start.Add(19 * h), Type: store.RapidActing},
+		}, false, 0, 0},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			f, ok := basalDrift(tc.insulin, time.UTC)
+			if ok != tc.wantOK {
+				t.Fatalf("got found %t (%s to %s), want %t", ok, f.From, f.To, tc.wantOK)
+			}
+			if ok && (f.From != tc.wantFrom || f.To != tc.wantTo) {
+				t.Errorf("got %s to %s, want %s to %s", f.From, f.To, tc.wantFrom, tc.wantTo)
+			}
+		})
+	}
+}
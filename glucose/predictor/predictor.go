@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"time"
 
+	"github.com/algao1/ichor/glucose/onboard"
 	"github.com/algao1/ichor/pb"
 	"github.com/algao1/ichor/store"
 	"go.uber.org/zap"
@@ -26,8 +27,11 @@ func New(conn *grpc.ClientConn, logger *zap.Logger) *Client {
 
 // TODO: Needs a rewrite, but let's get it working first...
 
+// Predict forecasts glucose values following pts. The insulin and carbs on
+// board at each point are included if ob is not nil, and events are
+// optional.
 func (c *Client) Predict(ctx context.Context, pts []store.TimePoint, insulin []store.Insulin,
-	carbs []store.Carbohydrate) ([]store.TimePoint, error) {
+	carbs []store.Carbohydrate, events []store.Event, ob *onboard.Calculator) ([]store.TimePoint, error) {
 	if len(pts) == 0 {
 		return nil, fmt.Errorf("no points given")
 	}
@@ -38,18 +42,22 @@ func (c *Client) Predict(ctx context.Context, pts []store.TimePoint, insulin []s
 			Time:    timestamppb.New(pt.Time),
 			Glucose: pt.Value,
 		}
+		if ob != nil {
+			feats[i].Iob = ob.IOB(pt.Time)
+			feats[i].Cob = ob.COB(pt.Time)
+		}
 	}
 
 	var pcounter int
 	for _, insul := range insulin {
-		if insul.Type == store.RapidActing {
+		if insul.Type == store.RapidActing || insul.Type == store.UltraRapidActing {
 			continue
 		}
 
 		for pcounter < len(pts)-1 && insul.Time.After(pts[pcounter].Time) {
 			pcounter++
 		}
-		feats[pcounter].Insulin = float64(insul.Value)
+		feats[pcounter].Insulin = insul.Value
 	}
 
 	pcounter = 0
@@ -58,6 +66,19 @@ func (c *Client) Predict(ctx context.Context, pts []store.TimePoint, insulin []s
 			pcounter++
 		}
 		feats[pcounter].Carbs = float64(carb.Value)
+		feats[pcounter].Fat = float64(carb.Fat)
+		feats[pcounter].Protein = float64(carb.Protein)
+		feats[pcounter].GlycemicIndex = carb.GI
+	}
+
+	pcounter = 0
+	for _, event := range events {
+		for pcounter < len(pts)-1 && event.Time.After(pts[pcounter].Time) {
+			pcounter++
+		}
+		feats[pcounter].Event = event.Type
+		feats[pcounter].EventIntensity = event.Intensity
+		feats[pcounter].EventDuration = event.Duration.Minutes()
 	}
 
 	res, err := c.gc.Predict(ctx, &pb.Features{
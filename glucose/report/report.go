@@ -0,0 +1,155 @@
+// Package report builds summaries of the glucose readings and treatments
+// logged over a period, shared by the Discord commands and the dashboard.
+package report
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/algao1/ichor/glucose/metrics"
+	"github.com/algao1/ichor/store"
+)
+
+// Rolling periods, ending now.
+const (
+	PeriodDay   = "day"
+	PeriodWeek  = "week"
+	PeriodMonth = "month"
+)
+
+// Last returns the start of the rolling period of the given name ending at
+// now: the last 24 hours, 7 days or 30 days.
+func Last(period string, now time.Time) (time.Time, error) {
+	switch period {
+	case PeriodDay:
+		return now.AddDate(0, 0, -1), nil
+	case PeriodWeek:
+		return now.AddDate(0, 0, -7), nil
+	case PeriodMonth:
+		return now.AddDate(0, 0, -30), nil
+	default:
+		return time.Time{}, fmt.Errorf("unknown period: %s", period)
+	}
+}
+
+// Report summarizes a period. Glucose values are in mmol/L, and fractions
+// of time are between 0 and 1.
+type Report struct {
+	Start    time.Time
+	End      time.Time
+	Readings int
+
+	Mean   float64
+	StdDev float64
+	CV     float64
+	GMI    float64
+
+	// Time in the configured range, and in each of the consensus ranges.
+	TimeInRange    float64
+	TimeBelowRange float64
+	TimeAboveRange float64
+	metrics.Ranges
+
+	LBGI  float64
+	HBGI  float64
+	GRI   float64
+	MAGE  float64
+	MODD  float64
+	CONGA float64 // Over an hour.
+
+	LowEpisodes  []metrics.Episode
+	HighEpisodes []metrics.Episode
+	SensorWear   float64
+
+	Carbs int     // Grams.
+	Bolus float64 // Units of rapid and ultra-rapid acting insulin.
+	Basal float64 // Units of intermediate and long acting insulin.
+}
+
+// Days returns the length of the period in days.
+func (r *Report) Days() float64 {
+	return r.End.Sub(r.Start).Hours() / 24
+}
+
+// DailyCarbs returns the average grams of carbohydrates per day.
+func (r *Report) DailyCarbs() float64 {
+	return float64(r.Carbs) / r.Days()
+}
+
+// DailyBolus returns the average units of bolus insulin per day.
+func (r *Report) DailyBolus() float64 {
+	return r.Bolus / r.Days()
+}
+
+// DailyBasal returns the average units of basal insulin per day.
+func (r *Report) DailyBasal() float64 {
+	return r.Basal / r.Days()
+}
+
+// Build summarizes the period between start and end.
+func Build(s *store.Store, start, end time.Time) (*Report, error) {
+	if !end.After(start) {
+		return nil, fmt.Errorf("report must end after it starts")
+	}
+
+	var conf store.Config
+	if err := s.GetObject(store.IndexConfig, &conf); err != nil {
+		return nil, fmt.Errorf("unable to load config: %w", err)
+	}
+
+	var pts []store.TimePoint
+	if err := s.GetPoints(start, end, store.FieldGlucose, &pts); err != nil {
+		return nil, fmt.Errorf("unable to get points: %w", err)
+	}
+
+	var carbs []store.Carbohydrate
+	if err := s.GetPoints(start, end, store.FieldCarbohydrate, &carbs); err != nil {
+		return nil, fmt.Errorf("unable to get carbohydrates: %w", err)
+	}
+
+	var insulin []store.Insulin
+	if err := s.GetPoints(start, end, store.FieldInsulin, &insulin); err != nil {
+		return nil, fmt.Errorf("unable to get insulin doses: %w", err)
+	}
+
+	r := &Report{
+		Start:          start,
+		End:            end,
+		Readings:       len(pts),
+		Mean:           metrics.Mean(pts),
+		StdDev:         metrics.StdDev(pts),
+		CV:             metrics.CV(pts),
+		GMI:            metrics.GMI(pts),
+		TimeInRange:    metrics.TimeInRange(pts, conf.LowThreshold, conf.HighThreshold),
+		TimeBelowRange: metrics.TimeBelow(pts, conf.LowThreshold),
+		TimeAboveRange: metrics.TimeAbove(pts, conf.HighThreshold),
+		Ranges:         metrics.ConsensusRanges(pts),
+		LBGI:           metrics.LBGI(pts),
+		HBGI:           metrics.HBGI(pts),
+		GRI:            metrics.GRI(pts),
+		MAGE:           metrics.MAGE(pts),
+		MODD:           metrics.MODD(pts),
+		CONGA:          metrics.CONGA(pts, time.Hour),
+		LowEpisodes:    metrics.LowEpisodes(pts, conf.LowThreshold),
+		HighEpisodes:   metrics.HighEpisodes(pts, conf.HighThreshold),
+		SensorWear:     metrics.Coverage(pts, start, end),
+	}
+
+	for _, carb := range carbs {
+		r.Carbs += carb.Value
+	}
+	for _, dose := range insulin {
+		if dose.Type == store.RapidActing || dose.Type == store.UltraRapidActing {
+			r.Bolus += dose.Value
+		} else {
+			r.Basal += dose.Value
+		}
+	}
+
+	return r, nil
+}
+
+// Previous summarizes the period of the same length just before r.
+func (r *Report) Previous(s *store.Store) (*Report, error) {
+	return Build(s, r.Start.Add(-r.End.Sub(r.Start)), r.Start)
+}
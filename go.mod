@@ -6,8 +6,10 @@ require (
 	github.com/boltdb/bolt v1.3.1
 	github.com/diamondburned/arikawa/v3 v3.0.0-rc.4
 	github.com/gin-gonic/gin v1.7.4
+	github.com/gocarina/gocsv v0.0.0-20211203214250-4735fba0c1d9
 	github.com/golang/protobuf v1.5.0
 	github.com/lucasb-eyer/go-colorful v1.2.0
+	go.uber.org/zap v1.19.1
 	gonum.org/v1/gonum v0.9.3
 	gonum.org/v1/plot v0.10.0
 	google.golang.org/grpc v1.42.0
@@ -24,7 +26,6 @@ require (
 	github.com/go-playground/locales v0.13.0 // indirect
 	github.com/go-playground/universal-translator v0.17.0 // indirect
 	github.com/go-playground/validator/v10 v10.4.1 // indirect
-	github.com/gocarina/gocsv v0.0.0-20211203214250-4735fba0c1d9 // indirect
 	github.com/golang/freetype v0.0.0-20170609003504-e2365dfdc4a0 // indirect
 	github.com/gorilla/schema v1.2.0 // indirect
 	github.com/gorilla/websocket v1.4.2 // indirect
@@ -37,7 +38,6 @@ require (
 	github.com/ugorji/go/codec v1.1.7 // indirect
 	go.uber.org/atomic v1.9.0 // indirect
 	go.uber.org/multierr v1.7.0 // indirect
-	go.uber.org/zap v1.19.1 // indirect
 	golang.org/x/crypto v0.0.0-20210921155107-089bfa567519 // indirect
 	golang.org/x/image v0.0.0-20210628002857-a66eb6448b8d // indirect
 	golang.org/x/net v0.0.0-20210405180319-a5a99cb37ef4 // indirect
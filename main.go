@@ -5,13 +5,15 @@ import (
 	"flag"
 	"os"
 	"os/signal"
-	"strconv"
+	"path/filepath"
 	"syscall"
 	"time"
+	_ "time/tzdata" // Timezones must load even without system zoneinfo.
 
 	"github.com/algao1/ichor/discord"
 	"github.com/algao1/ichor/glucose/dexcom"
 	"github.com/algao1/ichor/glucose/predictor"
+	"github.com/algao1/ichor/router"
 	"github.com/algao1/ichor/store"
 	"go.uber.org/zap"
 	"google.golang.org/grpc"
@@ -23,10 +25,44 @@ var (
 	dexAccount  string
 	dexPassword string
 	serverAddr  string
+	timezone    string
+	usersFile   string
+	httpAddr    string
+	apiKey      string
 
 	export bool
 )
 
+var defaultConfig = store.Config{
+	WarningTimeout:      1 * time.Hour,
+	LowThreshold:        3.7,
+	HighThreshold:       10.0,
+	UrgentLowThreshold:  3.0,
+	Timezone:            "America/Toronto",
+	Units:               store.UnitMmol,
+	LiveStatus:          true,
+	StatusChartInterval: 15 * time.Minute,
+	InsulinCurve:        store.CurveExponential,
+	CarbAbsorption:      store.AbsorptionLinear,
+	SensorLifetime:      10 * 24 * time.Hour,
+	SiteLifetime:        3 * 24 * time.Hour,
+	ExpiryReminder:      12 * time.Hour,
+	Digests:             true,
+	DailyDigest:         "07:00",
+	WeeklyDigest:        "08:00",
+}
+
+var defaultProfile = store.Profile{
+	CarbRatios:    store.Schedule{{Start: 0, Value: 10.0}},
+	Sensitivities: store.Schedule{{Start: 0, Value: 2.0}},
+	Targets:       store.TargetSchedule{{Start: 0, Low: 5.0, High: 7.0}},
+}
+
+var defaultInsulinProducts = []store.InsulinProduct{
+	{Name: "lispro", Category: store.RapidActing, Onset: 15 * time.Minute, Peak: 1 * time.Hour, Duration: 4 * time.Hour},
+	{Name: "degludec", Category: store.LongActing, Onset: 1 * time.Hour, Duration: 42 * time.Hour},
+}
+
 func init() {
 	flag.BoolVar(&export, "e", false, "export db as csv")
 
@@ -35,6 +71,10 @@ func init() {
 	flag.StringVar(&dexAccount, "a", "", "dexcom account")
 	flag.StringVar(&dexPassword, "p", "", "dexcom password")
 	flag.StringVar(&serverAddr, "s", "localhost:50051", "inference server address")
+	flag.StringVar(&timezone, "z", "", "timezone, e.g. America/Toronto (overrides the stored setting)")
+	flag.StringVar(&usersFile, "r", "", "path to a JSON file of users to register")
+	flag.StringVar(&httpAddr, "w", "localhost:8080", "address to serve the dashboard on, empty to disable it")
+	flag.StringVar(&apiKey, "k", "", "bearer token required by the dashboard and API")
 
 	flag.Parse()
 }
@@ -53,42 +93,75 @@ func main() {
 	}
 	s.Initialize()
 
-	if export {
-		if err := s.Export("data"); err != nil {
-			logger.Fatal("failed to export store as csv",
+	regs, err := registrations()
+	if err != nil {
+		logger.Fatal("failed to read user registrations",
+			zap.Error(err),
+		)
+	}
+
+	for _, reg := range regs {
+		if err := register(s, reg, logger); err != nil {
+			logger.Fatal("failed to register user",
+				zap.String("user", reg.ID),
 				zap.Error(err),
 			)
 		}
-		return
 	}
 
-	// Will temporarily overwrite all previous configs.
-	storeConfig := store.Config{
-		WarningTimeout: 1 * time.Hour,
-		LowThreshold:   3.7,
-		HighThreshold:  10.0,
-	}
-
-	if err := s.AddObject(store.IndexConfig, storeConfig); err != nil {
-		logger.Panic("failed to save default store configuration",
+	users, err := s.GetUsers()
+	if err != nil {
+		logger.Fatal("failed to get registered users",
 			zap.Error(err),
 		)
 	}
-	logger.Info("saved default store configuration",
-		zap.Any("store config", storeConfig),
-	)
 
-	alertCh := make(chan discord.Alert)
+	for _, u := range users {
+		if err := s.ForUser(u.ID).Initialize(); err != nil {
+			logger.Fatal("failed to initialize user buckets",
+				zap.String("user", u.ID),
+				zap.Error(err),
+			)
+		}
+		if err := setupProfile(s.ForUser(u.ID)); err != nil {
+			logger.Fatal("failed to set up profile",
+				zap.String("user", u.ID),
+				zap.Error(err),
+			)
+		}
+		// Migrations may rely on the default profile above.
+		if err := s.ForUser(u.ID).Migrate(); err != nil {
+			logger.Fatal("failed to migrate user data",
+				zap.String("user", u.ID),
+				zap.Error(err),
+			)
+		}
+	}
 
-	puid, err := strconv.ParseFloat(uid, 64)
-	if err != nil {
-		logger.Fatal("failed to parse Discord uid",
-			zap.String("uid", uid),
-			zap.Error(err),
-		)
+	if export {
+		for _, u := range users {
+			dir := filepath.Join("data", u.ID)
+			if err := os.MkdirAll(dir, os.ModePerm); err != nil {
+				logger.Fatal("failed to create export directory",
+					zap.String("directory", dir),
+					zap.Error(err),
+				)
+			}
+			if err := s.ForUser(u.ID).Export(dir); err != nil {
+				logger.Fatal("failed to export store as csv",
+					zap.String("user", u.ID),
+					zap.Error(err),
+				)
+			}
+		}
+		return
 	}
 
-	db, err := discord.Create(token, puid, s, logger.Named("discord"), alertCh)
+	alertCh := make(chan discord.Alert)
+	reminderCh := make(chan discord.Reminder)
+	digestCh := make(chan discord.Digest)
+
+	db, err := discord.Create(token, s, logger.Named("discord"), alertCh, reminderCh, digestCh)
 	if err != nil {
 		logger.Fatal("failed to create Discord bot",
 			zap.Error(err),
@@ -103,11 +176,31 @@ func main() {
 		)
 	}
 
-	dc := dexcom.New(dexAccount, dexPassword, logger.Named("dexcom client"))
-	go RunUploader(dc, s, logger)
-
 	p := predictor.New(conn, logger.Named("predictor"))
-	go RunPredictor(p, s, logger, alertCh)
+
+	for _, u := range users {
+		us := s.ForUser(u.ID)
+		ul := logger.With(zap.String("user", u.ID))
+
+		dc := dexcom.New(u.DexcomAccount, u.DexcomPassword, ul.Named("dexcom client"))
+		go RunUploader(dc, us, ul)
+		go RunPredictor(p, us, u.ID, ul, alertCh)
+		go RunReminders(us, u.ID, ul, reminderCh)
+		go RunDigests(us, u.ID, ul, digestCh)
+	}
+
+	if httpAddr != "" && apiKey == "" {
+		logger.Warn("not serving dashboard without an API key, set one with -k")
+	} else if httpAddr != "" {
+		go func() {
+			if err := router.Create(s, apiKey).Run(httpAddr); err != nil {
+				logger.Error("failed to serve dashboard",
+					zap.String("address", httpAddr),
+					zap.Error(err),
+				)
+			}
+		}()
+	}
 
 	db.Run(context.Background())
 	defer db.Stop()
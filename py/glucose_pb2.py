@@ -20,7 +20,7 @@ DESCRIPTOR = _descriptor.FileDescriptor(
   syntax='proto3',
   serialized_options=b'Z\032github.com/algao1/ichor/pb',
   create_key=_descriptor._internal_create_key,
-  serialized_pb=b'\n\rglucose.proto\x12\x05proto\x1a\x1fgoogle/protobuf/timestamp.proto\",\n\x08\x46\x65\x61tures\x12 \n\x08\x66\x65\x61tures\x18\x01 \x03(\x0b\x32\x0e.proto.Feature\"d\n\x07\x46\x65\x61ture\x12(\n\x04time\x18\x01 \x01(\x0b\x32\x1a.google.protobuf.Timestamp\x12\x0f\n\x07glucose\x18\x02 \x01(\x01\x12\x0f\n\x07insulin\x18\x03 \x01(\x01\x12\r\n\x05\x63\x61rbs\x18\x04 \x01(\x01\"@\n\x05Label\x12(\n\x04time\x18\x01 \x01(\x0b\x32\x1a.google.protobuf.Timestamp\x12\r\n\x05value\x18\x02 \x01(\x01\"&\n\x06Labels\x12\x1c\n\x06labels\x18\x01 \x03(\x0b\x32\x0c.proto.Label26\n\x07Glucose\x12+\n\x07Predict\x12\x0f.proto.Features\x1a\r.proto.Labels\"\x00\x42\x1cZ\x1agithub.com/algao1/ichor/pbb\x06proto3'
+  serialized_pb=b'\n\rglucose.proto\x12\x05proto\x1a\x1fgoogle/protobuf/timestamp.proto\",\n\x08\x46\x65\x61tures\x12 \n\x08\x66\x65\x61tures\x18\x01 \x03(\x0b\x32\x0e.proto.Feature\"\xf4\x01\n\x07\x46\x65\x61ture\x12(\n\x04time\x18\x01 \x01(\x0b\x32\x1a.google.protobuf.Timestamp\x12\x0f\n\x07glucose\x18\x02 \x01(\x01\x12\x0f\n\x07insulin\x18\x03 \x01(\x01\x12\r\n\x05\x63\x61rbs\x18\x04 \x01(\x01\x12\x0b\n\x03\x66\x61t\x18\x05 \x01(\x01\x12\x0f\n\x07protein\x18\x06 \x01(\x01\x12\x16\n\x0eglycemic_index\x18\x07 \x01(\t\x12\x0b\n\x03iob\x18\x08 \x01(\x01\x12\x0b\n\x03\x63ob\x18\t \x01(\x01\x12\r\n\x05\x65vent\x18\n \x01(\t\x12\x17\n\x0f\x65vent_intensity\x18\x0b \x01(\t\x12\x16\n\x0e\x65vent_duration\x18\x0c \x01(\x01\"@\n\x05Label\x12(\n\x04time\x18\x01 \x01(\x0b\x32\x1a.google.protobuf.Timestamp\x12\r\n\x05value\x18\x02 \x01(\x01\"&\n\x06Labels\x12\x1c\n\x06labels\x18\x01 \x03(\x0b\x32\x0c.proto.Label26\n\x07Glucose\x12+\n\x07Predict\x12\x0f.proto.Features\x1a\r.proto.Labels\"\x00\x42\x1cZ\x1agithub.com/algao1/ichor/pbb\x06proto3'
   ,
   dependencies=[google_dot_protobuf_dot_timestamp__pb2.DESCRIPTOR,])
 
@@ -95,6 +95,62 @@ _FEATURE = _descriptor.Descriptor(
       message_type=None, enum_type=None, containing_type=None,
       is_extension=False, extension_scope=None,
       serialized_options=None, file=DESCRIPTOR,  create_key=_descriptor._internal_create_key),
+    _descriptor.FieldDescriptor(
+      name='fat', full_name='proto.Feature.fat', index=4,
+      number=5, type=1, cpp_type=5, label=1,
+      has_default_value=False, default_value=float(0),
+      message_type=None, enum_type=None, containing_type=None,
+      is_extension=False, extension_scope=None,
+      serialized_options=None, file=DESCRIPTOR,  create_key=_descriptor._internal_create_key),
+    _descriptor.FieldDescriptor(
+      name='protein', full_name='proto.Feature.protein', index=5,
+      number=6, type=1, cpp_type=5, label=1,
+      has_default_value=False, default_value=float(0),
+      message_type=None, enum_type=None, containing_type=None,
+      is_extension=False, extension_scope=None,
+      serialized_options=None, file=DESCRIPTOR,  create_key=_descriptor._internal_create_key),
+    _descriptor.FieldDescriptor(
+      name='glycemic_index', full_name='proto.Feature.glycemic_index', index=6,
+      number=7, type=9, cpp_type=9, label=1,
+      has_default_value=False, default_value=b"".decode('utf-8'),
+      message_type=None, enum_type=None, containing_type=None,
+      is_extension=False, extension_scope=None,
+      serialized_options=None, file=DESCRIPTOR,  create_key=_descriptor._internal_create_key),
+    _descriptor.FieldDescriptor(
+      name='iob', full_name='proto.Feature.iob', index=7,
+      number=8, type=1, cpp_type=5, label=1,
+      has_default_value=False, default_value=float(0),
+      message_type=None, enum_type=None, containing_type=None,
+      is_extension=False, extension_scope=None,
+      serialized_options=None, file=DESCRIPTOR,  create_key=_descriptor._internal_create_key),
+    _descriptor.FieldDescriptor(
+      name='cob', full_name='proto.Feature.cob', index=8,
+      number=9, type=1, cpp_type=5, label=1,
+      has_default_value=False, default_value=float(0),
+      message_type=None, enum_type=None, containing_type=None,
+      is_extension=False, extension_scope=None,
+      serialized_options=None, file=DESCRIPTOR,  create_key=_descriptor._internal_create_key),
+    _descriptor.FieldDescriptor(
+      name='event', full_name='proto.Feature.event', index=9,
+      number=10, type=9, cpp_type=9, label=1,
+      has_default_value=False, default_value=b"".decode('utf-8'),
+      message_type=None, enum_type=None, containing_type=None,
+      is_extension=False, extension_scope=None,
+      serialized_options=None, file=DESCRIPTOR,  create_key=_descriptor._internal_create_key),
+    _descriptor.FieldDescriptor(
+      name='event_intensity', full_name='proto.Feature.event_intensity', index=10,
+      number=11, type=9, cpp_type=9, label=1,
+      has_default_value=False, default_value=b"".decode('utf-8'),
+      message_type=None, enum_type=None, containing_type=None,
+      is_extension=False, extension_scope=None,
+      serialized_options=None, file=DESCRIPTOR,  create_key=_descriptor._internal_create_key),
+    _descriptor.FieldDescriptor(
+      name='event_duration', full_name='proto.Feature.event_duration', index=11,
+      number=12, type=1, cpp_type=5, label=1,
+      has_default_value=False, default_value=float(0),
+      message_type=None, enum_type=None, containing_type=None,
+      is_extension=False, extension_scope=None,
+      serialized_options=None, file=DESCRIPTOR,  create_key=_descriptor._internal_create_key),
   ],
   extensions=[
   ],
@@ -107,8 +163,8 @@ _FEATURE = _descriptor.Descriptor(
   extension_ranges=[],
   oneofs=[
   ],
-  serialized_start=103,
-  serialized_end=203,
+  serialized_start=104,
+  serialized_end=348,
 )
 
 
@@ -146,8 +202,8 @@ _LABEL = _descriptor.Descriptor(
   extension_ranges=[],
   oneofs=[
   ],
-  serialized_start=205,
-  serialized_end=269,
+  serialized_start=350,
+  serialized_end=414,
 )
 
 
@@ -178,8 +234,8 @@ _LABELS = _descriptor.Descriptor(
   extension_ranges=[],
   oneofs=[
   ],
-  serialized_start=271,
-  serialized_end=309,
+  serialized_start=416,
+  serialized_end=454,
 )
 
 _FEATURES.fields_by_name['features'].message_type = _FEATURE
@@ -230,8 +286,8 @@ _GLUCOSE = _descriptor.ServiceDescriptor(
   index=0,
   serialized_options=None,
   create_key=_descriptor._internal_create_key,
-  serialized_start=311,
-  serialized_end=365,
+  serialized_start=456,
+  serialized_end=510,
   methods=[
   _descriptor.MethodDescriptor(
     name='Predict',
@@ -47,7 +47,9 @@ class GlucoseServicer(glucose_pb2_grpc.GlucoseServicer):
       context.set_details("not enough points")
       return glucose_pb2.Labels()
     else:
-      # Processing.
+      # Processing. The model is only trained on carbs, insulin and glucose,
+      # so fat, protein, glycemic_index, iob, cob and the event fields are
+      # ignored for now.
       data = []
       for feat in request.features:
         data.append([feat.carbs, feat.insulin, feat.glucose])
@@ -0,0 +1,439 @@
+package router
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/algao1/ichor/glucose/report"
+	"github.com/algao1/ichor/store"
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	defaultRange    = 24 * time.Hour
+	defaultPageSize = 500
+	maxPageSize     = 5000
+)
+
+// pointRange returns the time range given by the start and end query
+// parameters, as RFC 3339 times. The range defaults to the last day.
+func pointRange(c *gin.Context) (time.Time, time.Time, error) {
+	end := time.Now()
+	if v := c.Query("end"); v != "" {
+		t, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			return time.Time{}, time.Time{}, fmt.Errorf("invalid end: %w", err)
+		}
+		end = t
+	}
+
+	start := end.Add(-defaultRange)
+	if v := c.Query("start"); v != "" {
+		t, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			return time.Time{}, time.Time{}, fmt.Errorf("invalid start: %w", err)
+		}
+		start = t
+	}
+
+	if !end.After(start) {
+		return time.Time{}, time.Time{}, fmt.Errorf("end must be after start")
+	}
+	return start, end, nil
+}
+
+// pageBounds returns the page given by the offset and limit query
+// parameters.
+func pageBounds(c *gin.Context) (Page, error) {
+	p := Page{Limit: defaultPageSize}
+	if v := c.Query("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > maxPageSize {
+			return p, fmt.Errorf("limit must be between 1 and %d, got %q", maxPageSize, v)
+		}
+		p.Limit = n
+	}
+	if v := c.Query("offset"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			return p, fmt.Errorf("offset must not be negative, got %q", v)
+		}
+		p.Offset = n
+	}
+	return p, nil
+}
+
+// slice returns the bounds of the page within total entries.
+func (p *Page) slice(total int) (int, int) {
+	p.Total = total
+	lo, hi := p.Offset, p.Offset+p.Limit
+	if lo > total {
+		lo = total
+	}
+	if hi > total {
+		hi = total
+	}
+	return lo, hi
+}
+
+// pointQuery parses the range and page of a request, and returns the store
+// of the requested user.
+func pointQuery(s *store.Store, c *gin.Context) (*store.Store, time.Time, time.Time, Page, bool) {
+	start, end, err := pointRange(c)
+	if err != nil {
+		errorResponse(c, http.StatusBadRequest, err)
+		return nil, start, end, Page{}, false
+	}
+
+	p, err := pageBounds(c)
+	if err != nil {
+		errorResponse(c, http.StatusBadRequest, err)
+		return nil, start, end, p, false
+	}
+
+	us, status, err := userStore(s, c)
+	if err != nil {
+		errorResponse(c, status, err)
+		return nil, start, end, p, false
+	}
+
+	return us, start, end, p, true
+}
+
+// apiPoints lists the glucose readings or predictions of a time range.
+func apiPoints(s *store.Store, field string) func(*gin.Context) {
+	return func(c *gin.Context) {
+		us, start, end, p, ok := pointQuery(s, c)
+		if !ok {
+			return
+		}
+
+		var pts []store.TimePoint
+		if err := us.GetPoints(start, end, field, &pts); err != nil {
+			errorResponse(c, http.StatusInternalServerError, fmt.Errorf("unable to get points: %w", err))
+			return
+		}
+
+		lo, hi := p.slice(len(pts))
+		p.Items = toPoints(pts[lo:hi])
+		c.JSON(http.StatusOK, p)
+	}
+}
+
+func toCarbohydrate(carb store.Carbohydrate) Carbohydrate {
+	return Carbohydrate{
+		Time:    carb.Time,
+		Value:   carb.Value,
+		Fat:     carb.Fat,
+		Protein: carb.Protein,
+		GI:      carb.GI,
+		Notes:   carb.Notes,
+		Tags:    carb.Tags,
+	}
+}
+
+func toInsulinDose(dose store.Insulin) InsulinDose {
+	return InsulinDose{Time: dose.Time, Product: dose.Product, Type: dose.Type, Value: dose.Value}
+}
+
+// apiCarbs lists the carbohydrate intakes of a time range.
+func apiCarbs(s *store.Store) func(*gin.Context) {
+	return func(c *gin.Context) {
+		us, start, end, p, ok := pointQuery(s, c)
+		if !ok {
+			return
+		}
+
+		var carbs []store.Carbohydrate
+		if err := us.GetPoints(start, end, store.FieldCarbohydrate, &carbs); err != nil {
+			errorResponse(c, http.StatusInternalServerError, fmt.Errorf("unable to get carbohydrates: %w", err))
+			return
+		}
+
+		lo, hi := p.slice(len(carbs))
+		items := make([]Carbohydrate, 0, hi-lo)
+		for _, carb := range carbs[lo:hi] {
+			items = append(items, toCarbohydrate(carb))
+		}
+		p.Items = items
+		c.JSON(http.StatusOK, p)
+	}
+}
+
+// apiInsulin lists the insulin doses of a time range.
+func apiInsulin(s *store.Store) func(*gin.Context) {
+	return func(c *gin.Context) {
+		us, start, end, p, ok := pointQuery(s, c)
+		if !ok {
+			return
+		}
+
+		var insulin []store.Insulin
+		if err := us.GetPoints(start, end, store.FieldInsulin, &insulin); err != nil {
+			errorResponse(c, http.StatusInternalServerError, fmt.Errorf("unable to get insulin doses: %w", err))
+			return
+		}
+
+		lo, hi := p.slice(len(insulin))
+		items := make([]InsulinDose, 0, hi-lo)
+		for _, dose := range insulin[lo:hi] {
+			items = append(items, toInsulinDose(dose))
+		}
+		p.Items = items
+		c.JSON(http.StatusOK, p)
+	}
+}
+
+// apiAddCarbs logs a carbohydrate intake, at the current time if none is
+// given.
+func apiAddCarbs(s *store.Store) func(*gin.Context) {
+	return func(c *gin.Context) {
+		var in Carbohydrate
+		if err := c.ShouldBindJSON(&in); err != nil {
+			errorResponse(c, http.StatusBadRequest, fmt.Errorf("invalid carbohydrate intake: %w", err))
+			return
+		}
+		if in.Value <= 0 || in.Fat < 0 || in.Protein < 0 {
+			errorResponse(c, http.StatusBadRequest, fmt.Errorf("carbohydrates must be positive, and fat and protein not negative"))
+			return
+		}
+		if in.GI != "" && in.GI != store.GIFast && in.GI != store.GIMedium && in.GI != store.GISlow {
+			errorResponse(c, http.StatusBadRequest, fmt.Errorf("gi must be one of %s, %s or %s, got %s",
+				store.GIFast, store.GIMedium, store.GISlow, in.GI))
+			return
+		}
+		if in.Time.IsZero() {
+			in.Time = time.Now()
+		}
+
+		us, status, err := userStore(s, c)
+		if err != nil {
+			errorResponse(c, status, err)
+			return
+		}
+
+		// Entries are keyed by the second they were logged at, so a new entry
+		// must not overwrite another one.
+		var existing []store.Carbohydrate
+		if err := us.GetPoints(in.Time, in.Time, store.FieldCarbohydrate, &existing); err != nil {
+			errorResponse(c, http.StatusInternalServerError, fmt.Errorf("unable to get carbohydrates: %w", err))
+			return
+		}
+		if len(existing) > 0 {
+			errorResponse(c, http.StatusConflict, fmt.Errorf("another carbohydrate intake already exists at that time"))
+			return
+		}
+
+		tags := make([]string, 0, len(in.Tags))
+		for _, tag := range in.Tags {
+			if tag = strings.ToLower(strings.TrimSpace(tag)); tag != "" {
+				tags = append(tags, tag)
+			}
+		}
+
+		carb := store.Carbohydrate{
+			Time:    in.Time,
+			Value:   in.Value,
+			Fat:     in.Fat,
+			Protein: in.Protein,
+			GI:      in.GI,
+			Notes:   strings.TrimSpace(in.Notes),
+			Tags:    tags,
+		}
+		if err := us.AddPoint(store.FieldCarbohydrate, carb.Time, carb); err != nil {
+			errorResponse(c, http.StatusInternalServerError, fmt.Errorf("unable to save carbohydrate intake: %w", err))
+			return
+		}
+
+		c.JSON(http.StatusCreated, toCarbohydrate(carb))
+	}
+}
+
+// apiAddInsulin logs a dose of one of the user's insulin products, the
+// first rapid-acting one if none is given, at the current time if none is
+// given.
+func apiAddInsulin(s *store.Store) func(*gin.Context) {
+	return func(c *gin.Context) {
+		var in InsulinDose
+		if err := c.ShouldBindJSON(&in); err != nil {
+			errorResponse(c, http.StatusBadRequest, fmt.Errorf("invalid insulin dose: %w", err))
+			return
+		}
+		if in.Value <= 0 {
+			errorResponse(c, http.StatusBadRequest, fmt.Errorf("insulin dose must be positive, got %g", in.Value))
+			return
+		}
+		if in.Time.IsZero() {
+			in.Time = time.Now()
+		}
+
+		us, status, err := userStore(s, c)
+		if err != nil {
+			errorResponse(c, status, err)
+			return
+		}
+
+		var products []store.InsulinProduct
+		if err := us.GetObject(store.IndexInsulinProducts, &products); err != nil {
+			errorResponse(c, http.StatusInternalServerError, fmt.Errorf("unable to load insulin products: %w", err))
+			return
+		}
+		product, err := store.FindInsulinProduct(products, in.Product)
+		if err != nil {
+			errorResponse(c, http.StatusBadRequest, err)
+			return
+		}
+
+		var existing []store.Insulin
+		if err := us.GetPoints(in.Time, in.Time, store.FieldInsulin, &existing); err != nil {
+			errorResponse(c, http.StatusInternalServerError, fmt.Errorf("unable to get insulin doses: %w", err))
+			return
+		}
+		if len(existing) > 0 {
+			errorResponse(c, http.StatusConflict, fmt.Errorf("another insulin dose already exists at that time"))
+			return
+		}
+
+		dose := store.Insulin{Time: in.Time, Type: product.Category, Product: product.Name, Value: in.Value}
+		if err := us.AddPoint(store.FieldInsulin, dose.Time, dose); err != nil {
+			errorResponse(c, http.StatusInternalServerError, fmt.Errorf("unable to save insulin dose: %w", err))
+			return
+		}
+
+		c.JSON(http.StatusCreated, toInsulinDose(dose))
+	}
+}
+
+// apiDeleteEntry deletes the carbohydrate intake or insulin dose logged at
+// the Unix time given by the time path parameter, and records it in the
+// audit trail as /log delete does.
+func apiDeleteEntry(s *store.Store, field string) func(*gin.Context) {
+	return func(c *gin.Context) {
+		unix, err := strconv.ParseInt(c.Param("time"), 10, 64)
+		if err != nil {
+			errorResponse(c, http.StatusBadRequest, fmt.Errorf("time must be a Unix time in seconds, got %q", c.Param("time")))
+			return
+		}
+		t := time.Unix(unix, 0)
+
+		us, status, err := userStore(s, c)
+		if err != nil {
+			errorResponse(c, status, err)
+			return
+		}
+
+		var before interface{}
+		if field == store.FieldCarbohydrate {
+			var carbs []store.Carbohydrate
+			err = us.GetPoints(t, t, field, &carbs)
+			if len(carbs) > 0 {
+				before = carbs[0]
+			}
+		} else {
+			var insulin []store.Insulin
+			err = us.GetPoints(t, t, field, &insulin)
+			if len(insulin) > 0 {
+				before = insulin[0]
+			}
+		}
+		if err != nil {
+			errorResponse(c, http.StatusInternalServerError, fmt.Errorf("unable to get entry: %w", err))
+			return
+		}
+		if before == nil {
+			errorResponse(c, http.StatusNotFound, fmt.Errorf("no %s entry at %d", field, unix))
+			return
+		}
+
+		if err := us.DeletePoint(field, t); err != nil {
+			errorResponse(c, http.StatusInternalServerError, fmt.Errorf("unable to delete entry: %w", err))
+			return
+		}
+
+		err = us.AddAudit(store.AuditEntry{
+			Time:   time.Now(),
+			Action: store.AuditDelete,
+			Field:  field,
+			Before: before,
+		})
+		if err != nil {
+			errorResponse(c, http.StatusInternalServerError, fmt.Errorf("unable to record audit entry: %w", err))
+			return
+		}
+
+		c.Status(http.StatusNoContent)
+	}
+}
+
+// apiMetrics returns the report of the rolling period given by the range
+// query parameter, compared with the period before it.
+func apiMetrics(s *store.Store) func(*gin.Context) {
+	return func(c *gin.Context) {
+		end := time.Now()
+		start, err := report.Last(c.DefaultQuery("range", report.PeriodDay), end)
+		if err != nil {
+			errorResponse(c, http.StatusBadRequest, err)
+			return
+		}
+
+		us, conf, ok := userConfig(s, c)
+		if !ok {
+			return
+		}
+
+		cur, err := report.Build(us, start, end)
+		if err != nil {
+			errorResponse(c, http.StatusInternalServerError, fmt.Errorf("unable to build report: %w", err))
+			return
+		}
+		prev, err := cur.Previous(us)
+		if err != nil {
+			errorResponse(c, http.StatusInternalServerError, fmt.Errorf("unable to build previous report: %w", err))
+			return
+		}
+
+		c.JSON(http.StatusOK, MetricsData{Units: conf.Units, Current: toMetrics(cur), Previous: toMetrics(prev)})
+	}
+}
+
+// apiConfig returns the configuration of the user. Durations are given in
+// nanoseconds.
+func apiConfig(s *store.Store) func(*gin.Context) {
+	return func(c *gin.Context) {
+		_, conf, ok := userConfig(s, c)
+		if !ok {
+			return
+		}
+		c.JSON(http.StatusOK, conf)
+	}
+}
+
+// apiUpdateConfig updates the fields of the configuration given in the
+// body, keeping the others, and saves it if it is valid.
+func apiUpdateConfig(s *store.Store) func(*gin.Context) {
+	return func(c *gin.Context) {
+		us, conf, ok := userConfig(s, c)
+		if !ok {
+			return
+		}
+
+		if err := c.ShouldBindJSON(conf); err != nil {
+			errorResponse(c, http.StatusBadRequest, fmt.Errorf("invalid config: %w", err))
+			return
+		}
+		if err := conf.Validate(); err != nil {
+			errorResponse(c, http.StatusBadRequest, err)
+			return
+		}
+
+		if err := us.AddObject(store.IndexConfig, conf); err != nil {
+			errorResponse(c, http.StatusInternalServerError, fmt.Errorf("unable to save config: %w", err))
+			return
+		}
+
+		c.JSON(http.StatusOK, conf)
+	}
+}
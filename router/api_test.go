@@ -0,0 +1,335 @@
+package router
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/algao1/ichor/store"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+const (
+	testAPIKey = "secret"
+	testUser   = "1234"
+)
+
+var testConfig = store.Config{
+	WarningTimeout:      time.Hour,
+	LowThreshold:        3.7,
+	HighThreshold:       10.0,
+	UrgentLowThreshold:  3.0,
+	Timezone:            "America/Toronto",
+	Units:               store.UnitMmol,
+	StatusChartInterval: 15 * time.Minute,
+	InsulinCurve:        store.CurveExponential,
+	CarbAbsorption:      store.AbsorptionLinear,
+	SensorLifetime:      10 * 24 * time.Hour,
+	SiteLifetime:        3 * 24 * time.Hour,
+	ExpiryReminder:      12 * time.Hour,
+	DailyDigest:         "07:00",
+	WeeklyDigest:        "08:00",
+}
+
+// newTestRouter creates a router over a new store in a temporary directory,
+// with a single registered user.
+func newTestRouter(t *testing.T) (*Router, *store.Store) {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.Chdir(wd) })
+	if err := os.Mkdir("data", 0700); err != nil {
+		t.Fatal(err)
+	}
+
+	s, err := store.Create(zap.NewNop())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { s.DB.Close() })
+	if err := s.Initialize(); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.AddUser(store.User{ID: testUser}); err != nil {
+		t.Fatal(err)
+	}
+
+	us := s.ForUser(testUser)
+	if err := us.Initialize(); err != nil {
+		t.Fatal(err)
+	}
+	if err := us.AddObject(store.IndexConfig, testConfig); err != nil {
+		t.Fatal(err)
+	}
+	products := []store.InsulinProduct{
+		{Name: "lispro", Category: store.RapidActing, Onset: 15 * time.Minute, Peak: time.Hour, Duration: 4 * time.Hour},
+	}
+	if err := us.AddObject(store.IndexInsulinProducts, products); err != nil {
+		t.Fatal(err)
+	}
+
+	return Create(s, testAPIKey), us
+}
+
+// do sends a request with the test API key, encoding body as JSON if it is
+// not nil.
+func do(r *Router, method, path string, body interface{}) *httptest.ResponseRecorder {
+	var buf bytes.Buffer
+	if body != nil {
+		json.NewEncoder(&buf).Encode(body)
+	}
+	req := httptest.NewRequest(method, path, &buf)
+	req.Header.Set("Authorization", "Bearer "+testAPIKey)
+	req.Header.Set("Content-Type", "application/json")
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func TestAuth(t *testing.T) {
+	r, _ := newTestRouter(t)
+
+	tests := []struct {
+		name   string
+		path   string
+		header string
+		want   int
+	}{
+		{"no header", "/api/v1/config", "", http.StatusUnauthorized},
+		{"wrong key", "/api/v1/config", "Bearer wrong", http.StatusUnauthorized},
+		{"not bearer", "/api/v1/config", testAPIKey, http.StatusUnauthorized},
+		{"dashboard", "/dashboard/live", "", http.StatusUnauthorized},
+		{"valid key", "/api/v1/config", "Bearer " + testAPIKey, http.StatusOK},
+		{"page", "/", "", http.StatusOK},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, tc.path, nil)
+			if tc.header != "" {
+				req.Header.Set("Authorization", tc.header)
+			}
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+			if w.Code != tc.want {
+				t.Errorf("got status %d, want %d: %s", w.Code, tc.want, w.Body)
+			}
+		})
+	}
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/upload/glucose", nil))
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("upload without key: got status %d, want %d", w.Code, http.StatusUnauthorized)
+	}
+}
+
+func TestEmptyAPIKey(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := Create(nil, "")
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/config", nil)
+	req.Header.Set("Authorization", "Bearer ")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("got status %d, want %d", w.Code, http.StatusUnauthorized)
+	}
+}
+
+func TestPointsPagination(t *testing.T) {
+	r, us := newTestRouter(t)
+
+	end := time.Date(2022, 3, 1, 12, 0, 0, 0, time.UTC)
+	for i := 0; i < 10; i++ {
+		pt := end.Add(-time.Duration(i+1) * 5 * time.Minute)
+		if err := us.AddPoint(store.FieldGlucose, pt, store.TimePoint{Time: pt, Value: 5 + float64(i)/10}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	query := "start=" + end.Add(-time.Hour).Format(time.RFC3339) + "&end=" + end.Format(time.RFC3339)
+
+	tests := []struct {
+		page      string
+		wantCount int
+		wantLimit int
+	}{
+		{"", 10, defaultPageSize},
+		{"&limit=3", 3, 3},
+		{"&limit=3&offset=9", 1, 3},
+		{"&limit=3&offset=20", 0, 3},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.page, func(t *testing.T) {
+			w := do(r, http.MethodGet, "/api/v1/glucose?"+query+tc.page, nil)
+			if w.Code != http.StatusOK {
+				t.Fatalf("got status %d: %s", w.Code, w.Body)
+			}
+
+			var p struct {
+				Total  int
+				Offset int
+				Limit  int
+				Items  []Point
+			}
+			if err := json.Unmarshal(w.Body.Bytes(), &p); err != nil {
+				t.Fatal(err)
+			}
+			if p.Total != 10 {
+				t.Errorf("got total %d, want 10", p.Total)
+			}
+			if p.Limit != tc.wantLimit {
+				t.Errorf("got limit %d, want %d", p.Limit, tc.wantLimit)
+			}
+			if len(p.Items) != tc.wantCount {
+				t.Errorf("got %d items, want %d", len(p.Items), tc.wantCount)
+			}
+		})
+	}
+}
+
+func TestBadRequests(t *testing.T) {
+	r, _ := newTestRouter(t)
+
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		body   interface{}
+		want   int
+	}{
+		{"bad start", http.MethodGet, "/api/v1/glucose?start=yesterday", nil, http.StatusBadRequest},
+		{"bad end", http.MethodGet, "/api/v1/carbs?end=2022-03-01", nil, http.StatusBadRequest},
+		{"end before start", http.MethodGet, "/api/v1/insulin?start=2022-03-02T00:00:00Z&end=2022-03-01T00:00:00Z",
+			nil, http.StatusBadRequest},
+		{"bad limit", http.MethodGet, "/api/v1/glucose?limit=0", nil, http.StatusBadRequest},
+		{"bad offset", http.MethodGet, "/api/v1/glucose?offset=-1", nil, http.StatusBadRequest},
+		{"unknown user", http.MethodGet, "/api/v1/glucose?user=42", nil, http.StatusNotFound},
+		{"unknown user config", http.MethodGet, "/api/v1/config?user=42", nil, http.StatusNotFound},
+		{"no carbs", http.MethodPost, "/api/v1/carbs", Carbohydrate{}, http.StatusBadRequest},
+		{"bad gi", http.MethodPost, "/api/v1/carbs", Carbohydrate{Value: 10, GI: "instant"}, http.StatusBadRequest},
+		{"unknown product", http.MethodPost, "/api/v1/insulin", InsulinDose{Product: "glargine", Value: 2},
+			http.StatusBadRequest},
+		{"bad delete time", http.MethodDelete, "/api/v1/carbs/now", nil, http.StatusBadRequest},
+		{"missing entry", http.MethodDelete, "/api/v1/insulin/1646136000", nil, http.StatusNotFound},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			w := do(r, tc.method, tc.path, tc.body)
+			if w.Code != tc.want {
+				t.Errorf("got status %d, want %d: %s", w.Code, tc.want, w.Body)
+			}
+
+			var body map[string]string
+			if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil || body["error"] == "" {
+				t.Errorf("got body %s, want an error", w.Body)
+			}
+		})
+	}
+}
+
+func TestAddDeleteEntries(t *testing.T) {
+	r, us := newTestRouter(t)
+
+	at := time.Date(2022, 3, 1, 12, 0, 0, 0, time.UTC)
+	unix := strconv.FormatInt(at.Unix(), 10)
+
+	tests := []struct {
+		name  string
+		path  string
+		field string
+		entry interface{}
+	}{
+		{"carbs", "/api/v1/carbs", store.FieldCarbohydrate, Carbohydrate{Time: at, Value: 45, GI: store.GIFast}},
+		{"insulin", "/api/v1/insulin", store.FieldInsulin, InsulinDose{Time: at, Product: "lispro", Value: 3.5}},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if w := do(r, http.MethodPost, tc.path, tc.entry); w.Code != http.StatusCreated {
+				t.Fatalf("add: got status %d: %s", w.Code, w.Body)
+			}
+			if w := do(r, http.MethodPost, tc.path, tc.entry); w.Code != http.StatusConflict {
+				t.Errorf("add again: got status %d, want %d", w.Code, http.StatusConflict)
+			}
+
+			var entries []json.RawMessage
+			if err := us.GetPoints(at, at, tc.field, &entries); err != nil {
+				t.Fatal(err)
+			}
+			if len(entries) != 1 {
+				t.Fatalf("got %d stored entries, want 1", len(entries))
+			}
+
+			if w := do(r, http.MethodDelete, tc.path+"/"+unix, nil); w.Code != http.StatusNoContent {
+				t.Fatalf("delete: got status %d: %s", w.Code, w.Body)
+			}
+			if w := do(r, http.MethodDelete, tc.path+"/"+unix, nil); w.Code != http.StatusNotFound {
+				t.Errorf("delete again: got status %d, want %d", w.Code, http.StatusNotFound)
+			}
+
+			entries = nil
+			if err := us.GetPoints(at, at, tc.field, &entries); err != nil {
+				t.Fatal(err)
+			}
+			if len(entries) != 0 {
+				t.Errorf("got %d stored entries after delete, want 0", len(entries))
+			}
+		})
+	}
+
+	audits, err := us.GetAudits(time.Now().Add(-time.Minute), time.Now().Add(time.Minute))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(audits) != 2 {
+		t.Errorf("got %d audit entries, want 2", len(audits))
+	}
+}
+
+func TestUpdateConfig(t *testing.T) {
+	r, us := newTestRouter(t)
+
+	tests := []struct {
+		name string
+		body map[string]interface{}
+		want int
+	}{
+		{"low above high", map[string]interface{}{"LowThreshold": 12.0}, http.StatusBadRequest},
+		{"unknown timezone", map[string]interface{}{"Timezone": "Mars/Olympus"}, http.StatusBadRequest},
+		{"bad units", map[string]interface{}{"Units": "mg"}, http.StatusBadRequest},
+		{"valid", map[string]interface{}{"HighThreshold": 11.0, "Units": store.UnitMgdl}, http.StatusOK},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if w := do(r, http.MethodPut, "/api/v1/config", tc.body); w.Code != tc.want {
+				t.Errorf("got status %d, want %d: %s", w.Code, tc.want, w.Body)
+			}
+		})
+	}
+
+	var conf store.Config
+	if err := us.GetObject(store.IndexConfig, &conf); err != nil {
+		t.Fatal(err)
+	}
+	if conf.HighThreshold != 11 || conf.Units != store.UnitMgdl || conf.LowThreshold != testConfig.LowThreshold {
+		t.Errorf("got config %+v, want only the valid update saved", conf)
+	}
+}
@@ -0,0 +1,263 @@
+package router
+
+import (
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/algao1/ichor/glucose/metrics"
+	"github.com/algao1/ichor/glucose/report"
+	"github.com/algao1/ichor/store"
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// liveWindow and predictionWindow are the time shown before and after
+	// now on the live chart, as on /glucose.
+	liveWindow       = 12 * time.Hour
+	predictionWindow = 6 * time.Hour
+
+	defaultAGPDays = 14
+	maxAGPDays     = 90
+	agpBinWidth    = 15 * time.Minute
+)
+
+// static holds the dashboard page and its scripts, so that it is served
+// without any network access.
+//
+//go:embed static
+var static embed.FS
+
+// errorResponse aborts the request with the given status and error.
+func errorResponse(c *gin.Context, status int, err error) {
+	c.AbortWithStatusJSON(status, gin.H{"error": err.Error()})
+}
+
+// userStore returns the store of the user given by the user query
+// parameter, or of the only registered user if it is left out.
+func userStore(s *store.Store, c *gin.Context) (*store.Store, int, error) {
+	uid := c.Query("user")
+	if uid == "" {
+		users, err := s.GetUsers()
+		if err != nil {
+			return nil, http.StatusInternalServerError, fmt.Errorf("unable to get users: %w", err)
+		}
+		if len(users) != 1 {
+			return nil, http.StatusBadRequest, fmt.Errorf("user must be given when %d users are registered", len(users))
+		}
+		uid = users[0].ID
+	}
+
+	if _, err := s.GetUser(uid); errors.Is(err, store.ErrNotFound) {
+		return nil, http.StatusNotFound, fmt.Errorf("unknown user: %s", uid)
+	} else if err != nil {
+		return nil, http.StatusInternalServerError, fmt.Errorf("unable to get user: %w", err)
+	}
+
+	return s.ForUser(uid), http.StatusOK, nil
+}
+
+// userConfig returns the store and config of the requested user.
+func userConfig(s *store.Store, c *gin.Context) (*store.Store, *store.Config, bool) {
+	us, status, err := userStore(s, c)
+	if err != nil {
+		errorResponse(c, status, err)
+		return nil, nil, false
+	}
+
+	var conf store.Config
+	if err := us.GetObject(store.IndexConfig, &conf); err != nil {
+		errorResponse(c, http.StatusInternalServerError, fmt.Errorf("unable to load config: %w", err))
+		return nil, nil, false
+	}
+
+	return us, &conf, true
+}
+
+func toPoints(pts []store.TimePoint) []Point {
+	out := make([]Point, len(pts))
+	for i, pt := range pts {
+		out[i] = Point{Time: pt.Time, Value: Number(pt.Value)}
+	}
+	return out
+}
+
+func toMetrics(r *report.Report) Metrics {
+	return Metrics{
+		Start:        r.Start,
+		End:          r.End,
+		Readings:     r.Readings,
+		Mean:         Number(r.Mean),
+		StdDev:       Number(r.StdDev),
+		CV:           Number(r.CV),
+		GMI:          Number(r.GMI),
+		InRange:      Number(r.TimeInRange),
+		BelowRange:   Number(r.TimeBelowRange),
+		AboveRange:   Number(r.TimeAboveRange),
+		VeryLow:      Number(r.VeryLow),
+		VeryHigh:     Number(r.VeryHigh),
+		LBGI:         Number(r.LBGI),
+		HBGI:         Number(r.HBGI),
+		GRI:          Number(r.GRI),
+		MAGE:         Number(r.MAGE),
+		LowEpisodes:  len(r.LowEpisodes),
+		HighEpisodes: len(r.HighEpisodes),
+		SensorWear:   Number(r.SensorWear),
+		Carbs:        r.Carbs,
+		Bolus:        Number(r.Bolus),
+		Basal:        Number(r.Basal),
+	}
+}
+
+func dashboardPage(c *gin.Context) {
+	page, err := static.ReadFile("static/index.html")
+	if err != nil {
+		errorResponse(c, http.StatusInternalServerError, err)
+		return
+	}
+	c.Data(http.StatusOK, "text/html; charset=utf-8", page)
+}
+
+// staticFiles serves the scripts and styles of the dashboard.
+func staticFiles() http.FileSystem {
+	sub, err := fs.Sub(static, "static")
+	if err != nil {
+		panic(err)
+	}
+	return http.FS(sub)
+}
+
+// dashboardLive returns the readings, predictions and treatments shown on
+// the live chart.
+func dashboardLive(s *store.Store) func(*gin.Context) {
+	return func(c *gin.Context) {
+		us, conf, ok := userConfig(s, c)
+		if !ok {
+			return
+		}
+
+		end := time.Now()
+		start := end.Add(-liveWindow)
+
+		var pts []store.TimePoint
+		if err := us.GetPoints(start, end, store.FieldGlucose, &pts); err != nil {
+			errorResponse(c, http.StatusInternalServerError, fmt.Errorf("unable to get points: %w", err))
+			return
+		}
+
+		var preds []store.TimePoint
+		if err := us.GetPoints(end, end.Add(predictionWindow), store.FieldGlucosePred, &preds); err != nil {
+			errorResponse(c, http.StatusInternalServerError, fmt.Errorf("unable to get predictions: %w", err))
+			return
+		}
+
+		var carbs []store.Carbohydrate
+		if err := us.GetPoints(start, end, store.FieldCarbohydrate, &carbs); err != nil {
+			errorResponse(c, http.StatusInternalServerError, fmt.Errorf("unable to get carbohydrates: %w", err))
+			return
+		}
+
+		var insulin []store.Insulin
+		if err := us.GetPoints(start, end, store.FieldInsulin, &insulin); err != nil {
+			errorResponse(c, http.StatusInternalServerError, fmt.Errorf("unable to get insulin doses: %w", err))
+			return
+		}
+
+		data := LiveData{
+			Thresholds:  Thresholds{Units: conf.Units, Low: Number(conf.LowThreshold), High: Number(conf.HighThreshold)},
+			Readings:    toPoints(pts),
+			Predictions: toPoints(preds),
+			Carbs:       make([]Treatment, len(carbs)),
+			Insulin:     make([]Treatment, len(insulin)),
+		}
+		for i, carb := range carbs {
+			data.Carbs[i] = Treatment{Time: carb.Time, Value: Number(carb.Value), Label: carb.GI}
+		}
+		for i, dose := range insulin {
+			data.Insulin[i] = Treatment{Time: dose.Time, Value: Number(dose.Value), Label: dose.Product}
+		}
+
+		c.JSON(http.StatusOK, data)
+	}
+}
+
+// dashboardAGP returns the ambulatory glucose profile over the last days,
+// given by the days query parameter.
+func dashboardAGP(s *store.Store) func(*gin.Context) {
+	return func(c *gin.Context) {
+		days := defaultAGPDays
+		if v := c.Query("days"); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil || n < 1 || n > maxAGPDays {
+				errorResponse(c, http.StatusBadRequest, fmt.Errorf("days must be between 1 and %d, got %q", maxAGPDays, v))
+				return
+			}
+			days = n
+		}
+
+		us, conf, ok := userConfig(s, c)
+		if !ok {
+			return
+		}
+
+		end := time.Now()
+		var pts []store.TimePoint
+		if err := us.GetPoints(end.AddDate(0, 0, -days), end, store.FieldGlucose, &pts); err != nil {
+			errorResponse(c, http.StatusInternalServerError, fmt.Errorf("unable to get points: %w", err))
+			return
+		}
+
+		data := AGPData{
+			Thresholds: Thresholds{Units: conf.Units, Low: metrics.Low, High: metrics.High},
+			Days:       days,
+			Bins:       make([]AGPBin, 0),
+		}
+		for _, bin := range metrics.AGP(pts, conf.Location(), agpBinWidth) {
+			data.Bins = append(data.Bins, AGPBin{
+				Minute: int(bin.Time.Minutes()),
+				P5:     Number(bin.P5),
+				P25:    Number(bin.P25),
+				P50:    Number(bin.P50),
+				P75:    Number(bin.P75),
+				P95:    Number(bin.P95),
+			})
+		}
+
+		c.JSON(http.StatusOK, data)
+	}
+}
+
+// dashboardMetrics returns the report of the rolling period given by the
+// period query parameter, compared with the period before it.
+func dashboardMetrics(s *store.Store) func(*gin.Context) {
+	return func(c *gin.Context) {
+		end := time.Now()
+		start, err := report.Last(c.DefaultQuery("period", report.PeriodDay), end)
+		if err != nil {
+			errorResponse(c, http.StatusBadRequest, err)
+			return
+		}
+
+		us, conf, ok := userConfig(s, c)
+		if !ok {
+			return
+		}
+
+		cur, err := report.Build(us, start, end)
+		if err != nil {
+			errorResponse(c, http.StatusInternalServerError, fmt.Errorf("unable to build report: %w", err))
+			return
+		}
+		prev, err := cur.Previous(us)
+		if err != nil {
+			errorResponse(c, http.StatusInternalServerError, fmt.Errorf("unable to build previous report: %w", err))
+			return
+		}
+
+		c.JSON(http.StatusOK, MetricsData{Units: conf.Units, Current: toMetrics(cur), Previous: toMetrics(prev)})
+	}
+}
@@ -1,10 +1,162 @@
 package router
 
+import (
+	"encoding/json"
+	"math"
+	"time"
+)
+
+// HealthData is the samples exported from Apple Health by a Shortcut. Data
+// holds the glucose readings, and any of the blocks may be left out.
 type HealthData struct {
-	Data DataBlock `json:"data" binding:"required"`
+	Data    DataBlock `json:"data"`
+	Carbs   DataBlock `json:"carbs"`
+	Insulin DataBlock `json:"insulin"`
 }
 
+// DataBlock holds one sample per line of Values, taken at the RFC 3339 time
+// on the same line of Dates. Units only applies to glucose, in mmol/L unless
+// given as mg/dL, and Product to insulin, the first rapid-acting product
+// unless given.
 type DataBlock struct {
-	Values string `json:"values"`
-	Dates  string `json:"dates"`
+	Values  string `json:"values"`
+	Dates   string `json:"dates"`
+	Units   string `json:"units"`
+	Product string `json:"product"`
+}
+
+// LineError is a sample that could not be uploaded. Lines start at 1.
+type LineError struct {
+	Sample string `json:"sample"`
+	Line   int    `json:"line"`
+	Error  string `json:"error"`
+}
+
+// UploadResult counts the samples inserted, and those skipped for being
+// already stored at the same time.
+type UploadResult struct {
+	Inserted int         `json:"inserted"`
+	Skipped  int         `json:"skipped"`
+	Errors   []LineError `json:"errors,omitempty"`
+}
+
+// Number is a float that encodes missing values, NaN or infinite, as null.
+type Number float64
+
+func (n Number) MarshalJSON() ([]byte, error) {
+	if math.IsNaN(float64(n)) || math.IsInf(float64(n), 0) {
+		return []byte("null"), nil
+	}
+	return json.Marshal(float64(n))
+}
+
+// Point is a glucose reading or prediction, in mmol/L.
+type Point struct {
+	Time  time.Time `json:"time"`
+	Value Number    `json:"value"`
+}
+
+// Treatment is a carbohydrate intake in grams, or an insulin dose in units.
+type Treatment struct {
+	Time  time.Time `json:"time"`
+	Value Number    `json:"value"`
+	Label string    `json:"label,omitempty"`
+}
+
+// Thresholds are the glucose thresholds of a chart, in mmol/L.
+type Thresholds struct {
+	Units string `json:"units"`
+	Low   Number `json:"low"`
+	High  Number `json:"high"`
+}
+
+// LiveData is the recent glucose, predictions and treatments.
+type LiveData struct {
+	Thresholds
+	Readings    []Point     `json:"readings"`
+	Predictions []Point     `json:"predictions"`
+	Carbs       []Treatment `json:"carbs"`
+	Insulin     []Treatment `json:"insulin"`
+}
+
+// AGPBin holds the glucose percentiles at a time of day, given in minutes
+// since midnight.
+type AGPBin struct {
+	Minute int    `json:"minute"`
+	P5     Number `json:"p5"`
+	P25    Number `json:"p25"`
+	P50    Number `json:"p50"`
+	P75    Number `json:"p75"`
+	P95    Number `json:"p95"`
+}
+
+// AGPData is the ambulatory glucose profile over the last days, with the
+// consensus range as its thresholds.
+type AGPData struct {
+	Thresholds
+	Days int      `json:"days"`
+	Bins []AGPBin `json:"bins"`
+}
+
+// Metrics are the metrics of a report. Fractions of time are between 0 and
+// 1, and glucose values in mmol/L.
+type Metrics struct {
+	Start        time.Time `json:"start"`
+	End          time.Time `json:"end"`
+	Readings     int       `json:"readings"`
+	Mean         Number    `json:"mean"`
+	StdDev       Number    `json:"stdDev"`
+	CV           Number    `json:"cv"`
+	GMI          Number    `json:"gmi"`
+	InRange      Number    `json:"inRange"`
+	BelowRange   Number    `json:"belowRange"`
+	AboveRange   Number    `json:"aboveRange"`
+	VeryLow      Number    `json:"veryLow"`
+	VeryHigh     Number    `json:"veryHigh"`
+	LBGI         Number    `json:"lbgi"`
+	HBGI         Number    `json:"hbgi"`
+	GRI          Number    `json:"gri"`
+	MAGE         Number    `json:"mage"`
+	LowEpisodes  int       `json:"lowEpisodes"`
+	HighEpisodes int       `json:"highEpisodes"`
+	SensorWear   Number    `json:"sensorWear"`
+	Carbs        int       `json:"carbs"`
+	Bolus        Number    `json:"bolus"`
+	Basal        Number    `json:"basal"`
+}
+
+// MetricsData compares the metrics of a period with those of the period of
+// the same length before it.
+type MetricsData struct {
+	Units    string  `json:"units"`
+	Current  Metrics `json:"current"`
+	Previous Metrics `json:"previous"`
+}
+
+// Page is one page of the entries of a time range, from earliest to latest.
+type Page struct {
+	Total  int         `json:"total"`
+	Offset int         `json:"offset"`
+	Limit  int         `json:"limit"`
+	Items  interface{} `json:"items"`
+}
+
+// Carbohydrate is a logged carbohydrate intake, in grams.
+type Carbohydrate struct {
+	Time    time.Time `json:"time"`
+	Value   int       `json:"value"`
+	Fat     int       `json:"fat,omitempty"`
+	Protein int       `json:"protein,omitempty"`
+	GI      string    `json:"gi,omitempty"`
+	Notes   string    `json:"notes,omitempty"`
+	Tags    []string  `json:"tags,omitempty"`
+}
+
+// InsulinDose is a logged insulin dose, in units. Type is the category of
+// the product, and is set from it when a dose is logged.
+type InsulinDose struct {
+	Time    time.Time `json:"time"`
+	Product string    `json:"product"`
+	Type    string    `json:"type"`
+	Value   float64   `json:"value"`
 }
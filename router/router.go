@@ -1,11 +1,10 @@
 package router
 
 import (
-	"log"
+	"crypto/subtle"
+	"fmt"
 	"net/http"
-	"strconv"
 	"strings"
-	"time"
 
 	"github.com/algao1/ichor/store"
 	"github.com/gin-gonic/gin"
@@ -15,38 +14,51 @@ type Router struct {
 	*gin.Engine
 }
 
-func Create(s *store.Store) *Router {
+// Create sets up the routes of the dashboard, API and upload endpoints.
+// Everything but the dashboard page and its static files requires the
+// bearer token apiKey, which must not be empty.
+func Create(s *store.Store, apiKey string) *Router {
 	r := gin.Default()
 
-	r.POST("/upload/glucose", uploadGlucose(s))
+	r.GET("/", dashboardPage)
+	r.StaticFS("/static", staticFiles())
 
-	return &Router{r}
-}
-
-func uploadGlucose(s *store.Store) func(*gin.Context) {
-	return func(c *gin.Context) {
-		var hd HealthData
+	authed := r.Group("/", requireToken(apiKey))
+	authed.POST("/upload/glucose", uploadGlucose(s))
 
-		if c.Bind(&hd) == nil {
-			// Apple Health weirdness with getting health samples.
-			glucoseValues := strings.Split(hd.Data.Values, "\n")
-			dateStrs := strings.Split(hd.Data.Dates, "\n")
+	dashboard := authed.Group("/dashboard")
+	dashboard.GET("/live", dashboardLive(s))
+	dashboard.GET("/agp", dashboardAGP(s))
+	dashboard.GET("/metrics", dashboardMetrics(s))
 
-			for i := range glucoseValues {
-				date, err := time.Parse("2006-01-02T15:04:05-07:00", dateStrs[i])
-				if err != nil {
-					log.Fatal(err)
-				}
+	v1 := authed.Group("/api/v1")
+	v1.GET("/glucose", apiPoints(s, store.FieldGlucose))
+	v1.GET("/predictions", apiPoints(s, store.FieldGlucosePred))
+	v1.GET("/carbs", apiCarbs(s))
+	v1.POST("/carbs", apiAddCarbs(s))
+	v1.DELETE("/carbs/:time", apiDeleteEntry(s, store.FieldCarbohydrate))
+	v1.GET("/insulin", apiInsulin(s))
+	v1.POST("/insulin", apiAddInsulin(s))
+	v1.DELETE("/insulin/:time", apiDeleteEntry(s, store.FieldInsulin))
+	v1.GET("/metrics", apiMetrics(s))
+	v1.GET("/config", apiConfig(s))
+	v1.PUT("/config", apiUpdateConfig(s))
 
-				val, err := strconv.ParseFloat(glucoseValues[i], 64)
-				if err != nil {
-					log.Fatal(err)
-				}
-
-				s.AddPoint(store.FieldGlucose, date, &store.TimePoint{Time: date, Value: val})
-			}
+	return &Router{r}
+}
 
-			c.JSON(http.StatusOK, gin.H{"status": "ok"})
+// requireToken rejects requests without the Authorization header
+// "Bearer <token>". An empty token rejects every request.
+func requireToken(token string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		const prefix = "Bearer "
+		header := c.GetHeader("Authorization")
+		if token == "" || !strings.HasPrefix(header, prefix) ||
+			subtle.ConstantTimeCompare([]byte(strings.TrimPrefix(header, prefix)), []byte(token)) != 1 {
+			c.Header("WWW-Authenticate", "Bearer")
+			errorResponse(c, http.StatusUnauthorized, fmt.Errorf("missing or invalid API key"))
+			return
 		}
+		c.Next()
 	}
 }
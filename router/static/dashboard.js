@@ -0,0 +1,293 @@
+// Dashboard of the glucose, predictions, treatments and metrics of a user,
+// drawn as SVG from the dashboard JSON endpoints.
+"use strict";
+
+const MGDL_PER_MMOL = 18;
+const WIDTH = 960;
+const HEIGHT = 320;
+const MARGIN = { top: 15, right: 20, bottom: 30, left: 45 };
+
+const params = new URLSearchParams(window.location.search);
+// The API key is given in the fragment, which is never sent to the server.
+const fragment = new URLSearchParams(window.location.hash.slice(1));
+
+function toUnits(v, units) {
+  return units === "mg/dL" ? v * MGDL_PER_MMOL : v;
+}
+
+function formatGlucose(v, units) {
+  if (v === null) {
+    return "-";
+  }
+  return units === "mg/dL" ? toUnits(v, units).toFixed(0) : v.toFixed(1);
+}
+
+async function api(path, extra) {
+  const query = new URLSearchParams(extra);
+  if (params.has("user")) {
+    query.set("user", params.get("user"));
+  }
+
+  const resp = await fetch("dashboard/" + path + "?" + query, {
+    headers: { Authorization: "Bearer " + (fragment.get("token") || "") },
+  });
+  const body = await resp.json();
+  if (!resp.ok) {
+    throw new Error(body.error || resp.statusText);
+  }
+  return body;
+}
+
+function showError(err) {
+  const el = document.getElementById("error");
+  el.textContent = err ? err.message : "";
+  el.hidden = !err;
+}
+
+// svg creates an SVG element with the given attributes, and appends it to
+// parent if one is given.
+function svg(tag, attrs, parent) {
+  const el = document.createElementNS("http://www.w3.org/2000/svg", tag);
+  for (const [k, v] of Object.entries(attrs || {})) {
+    el.setAttribute(k, v);
+  }
+  if (parent) {
+    parent.appendChild(el);
+  }
+  return el;
+}
+
+function scale(domainMin, domainMax, rangeMin, rangeMax) {
+  return (v) => rangeMin + ((v - domainMin) / (domainMax - domainMin || 1)) * (rangeMax - rangeMin);
+}
+
+function path(points) {
+  return points.map(([x, y], i) => (i === 0 ? "M" : "L") + x.toFixed(1) + "," + y.toFixed(1)).join(" ");
+}
+
+// axes draws the y grid and labels, and the x labels at the given ticks.
+function axes(chart, x, y, yMax, units, xTicks) {
+  const step = units === "mg/dL" ? 50 : 2;
+  for (let v = 0; v <= yMax; v += step) {
+    svg("line", { class: "grid", x1: MARGIN.left, x2: WIDTH - MARGIN.right, y1: y(v), y2: y(v) }, chart);
+    svg("text", { x: MARGIN.left - 6, y: y(v) + 4, "text-anchor": "end" }, chart).textContent = v;
+  }
+  for (const [v, label] of xTicks) {
+    svg("text", { x: x(v), y: HEIGHT - 10, "text-anchor": "middle" }, chart).textContent = label;
+  }
+}
+
+function thresholds(chart, y, low, high) {
+  for (const v of [low, high]) {
+    svg("line", { class: "threshold", x1: MARGIN.left, x2: WIDTH - MARGIN.right, y1: y(v), y2: y(v) }, chart);
+  }
+}
+
+function hourLabel(date) {
+  return date.toLocaleTimeString([], { hour: "numeric" });
+}
+
+function drawLive(data) {
+  const chart = document.getElementById("live");
+  chart.replaceChildren();
+
+  const units = data.units;
+  const readings = data.readings.map((p) => [Date.parse(p.time), toUnits(p.value, units)]);
+  const preds = data.predictions.map((p) => [Date.parse(p.time), toUnits(p.value, units)]);
+  const low = toUnits(data.low, units);
+  const high = toUnits(data.high, units);
+
+  const now = Date.now();
+  const xMin = now - 12 * 3600 * 1000;
+  const xMax = preds.length ? preds[preds.length - 1][0] : now;
+  const yMax = Math.max(high, ...readings.map((p) => p[1]), ...preds.map((p) => p[1])) * 1.1;
+
+  const x = scale(xMin, xMax, MARGIN.left, WIDTH - MARGIN.right);
+  const y = scale(0, yMax, HEIGHT - MARGIN.bottom, MARGIN.top);
+
+  const ticks = [];
+  for (let t = Math.ceil(xMin / 3600000) * 3600000; t <= xMax; t += 2 * 3600000) {
+    ticks.push([t, hourLabel(new Date(t))]);
+  }
+  axes(chart, x, y, yMax, units, ticks);
+  thresholds(chart, y, low, high);
+
+  svg("path", { class: "observed", d: path(readings.map(([t, v]) => [x(t), y(v)])) }, chart);
+  if (readings.length && preds.length) {
+    const line = [readings[readings.length - 1], ...preds];
+    svg("path", { class: "predicted", d: path(line.map(([t, v]) => [x(t), y(v)])) }, chart);
+  }
+
+  // Treatments are marked below (carbs) and above (insulin) the readings
+  // closest to them.
+  const glucoseAt = (t) => {
+    let best = readings[0];
+    for (const r of readings) {
+      if (Math.abs(r[0] - t) < Math.abs(best[0] - t)) {
+        best = r;
+      }
+    }
+    return best ? best[1] : low;
+  };
+  for (const carb of data.carbs) {
+    const t = Date.parse(carb.time);
+    const cx = x(t);
+    const cy = y(glucoseAt(t)) + 14;
+    svg("path", { class: "carbs", d: `M${cx},${cy - 6} L${cx - 6},${cy + 5} L${cx + 6},${cy + 5} Z` }, chart);
+    svg("text", { x: cx, y: cy + 18, "text-anchor": "middle" }, chart).textContent = carb.value + "g";
+  }
+  for (const dose of data.insulin) {
+    const t = Date.parse(dose.time);
+    const cx = x(t);
+    const cy = y(glucoseAt(t)) - 14;
+    svg("path", { class: "insulin", d: `M${cx},${cy + 6} L${cx - 6},${cy - 5} L${cx + 6},${cy - 5} Z` }, chart);
+    svg("text", { x: cx, y: cy - 10, "text-anchor": "middle" }, chart).textContent = dose.value + "u";
+  }
+
+  const current = document.getElementById("current");
+  if (data.readings.length) {
+    const last = data.readings[data.readings.length - 1];
+    const age = Math.round((now - Date.parse(last.time)) / 60000);
+    current.textContent = `${formatGlucose(last.value, units)} ${units} (${age} min ago)`;
+  } else {
+    current.textContent = "No recent readings";
+  }
+}
+
+function drawAGP(data) {
+  const chart = document.getElementById("agp");
+  chart.replaceChildren();
+
+  const units = data.units;
+  const bins = data.bins.map((b) => ({
+    minute: b.minute,
+    p5: toUnits(b.p5, units),
+    p25: toUnits(b.p25, units),
+    p50: toUnits(b.p50, units),
+    p75: toUnits(b.p75, units),
+    p95: toUnits(b.p95, units),
+  }));
+  const low = toUnits(data.low, units);
+  const high = toUnits(data.high, units);
+  const yMax = Math.max(high, ...bins.map((b) => b.p95)) * 1.1;
+
+  const x = scale(0, 24 * 60, MARGIN.left, WIDTH - MARGIN.right);
+  const y = scale(0, yMax, HEIGHT - MARGIN.bottom, MARGIN.top);
+
+  const ticks = [];
+  for (let h = 0; h <= 24; h += 3) {
+    ticks.push([h * 60, hourLabel(new Date(2000, 0, 1, h % 24))]);
+  }
+  axes(chart, x, y, yMax, units, ticks);
+
+  const band = (lower, upper) => {
+    const top = bins.map((b) => [x(b.minute), y(b[upper])]);
+    const bottom = bins.map((b) => [x(b.minute), y(b[lower])]).reverse();
+    return path(top.concat(bottom)) + " Z";
+  };
+  if (bins.length > 1) {
+    svg("path", { class: "outer", d: band("p5", "p95") }, chart);
+    svg("path", { class: "inner", d: band("p25", "p75") }, chart);
+    svg("path", { class: "median", d: path(bins.map((b) => [x(b.minute), y(b.p50)])) }, chart);
+  }
+  thresholds(chart, y, low, high);
+}
+
+// tiles are the metrics shown, with how to format them. Changes are shown
+// in the same format, signed.
+const tiles = [
+  { key: "inRange", name: "In Range", format: "percent" },
+  { key: "belowRange", name: "Below Range", format: "percent" },
+  { key: "aboveRange", name: "Above Range", format: "percent" },
+  { key: "veryLow", name: "Very Low", format: "percent" },
+  { key: "mean", name: "Mean", format: "glucose" },
+  { key: "gmi", name: "GMI", format: "gmi" },
+  { key: "cv", name: "CV", format: "percent" },
+  { key: "gri", name: "GRI", format: "number" },
+  { key: "lowEpisodes", name: "Low Episodes", format: "count" },
+  { key: "sensorWear", name: "Sensor Wear", format: "percent" },
+  { key: "carbs", name: "Carbohydrates", format: "grams" },
+  { key: "bolus", name: "Bolus", format: "insulin" },
+];
+
+function formatMetric(v, format, units) {
+  if (v === null || v === undefined) {
+    return "-";
+  }
+  switch (format) {
+    case "percent":
+      return (v * 100).toFixed(1) + "%";
+    case "glucose":
+      return formatGlucose(v, units);
+    case "gmi":
+      return v.toFixed(1) + "%";
+    case "count":
+      return v.toFixed(0);
+    case "grams":
+      return v.toFixed(0) + "g";
+    case "insulin":
+      return v.toFixed(1) + "u";
+    default:
+      return v.toFixed(1);
+  }
+}
+
+function formatChange(v, format, units) {
+  if (v === null || Number.isNaN(v)) {
+    return "";
+  }
+  const s = formatMetric(Math.abs(v), format, units);
+  return (v > 0 ? "+" : v < 0 ? "-" : "±") + s + " vs previous";
+}
+
+function drawTiles(data) {
+  const el = document.getElementById("tiles");
+  el.replaceChildren();
+
+  for (const tile of tiles) {
+    const cur = data.current[tile.key];
+    const prev = data.previous[tile.key];
+    const change = cur === null || prev === null ? null : cur - prev;
+
+    const div = document.createElement("div");
+    div.className = "tile";
+    for (const [cls, text] of [
+      ["name", tile.name],
+      ["value", formatMetric(cur, tile.format, data.units)],
+      ["change", formatChange(change, tile.format, data.units)],
+    ]) {
+      const span = document.createElement("div");
+      span.className = cls;
+      span.textContent = text;
+      div.appendChild(span);
+    }
+    el.appendChild(div);
+  }
+}
+
+async function refreshLive() {
+  try {
+    drawLive(await api("live"));
+    showError(null);
+  } catch (err) {
+    showError(err);
+  }
+}
+
+async function refreshReports() {
+  const period = document.getElementById("period").value;
+  try {
+    drawTiles(await api("metrics", { period }));
+    drawAGP(await api("agp", {}));
+    showError(null);
+  } catch (err) {
+    showError(err);
+  }
+}
+
+document.getElementById("period").addEventListener("change", refreshReports);
+
+refreshLive();
+refreshReports();
+setInterval(refreshLive, 60 * 1000);
+setInterval(refreshReports, 5 * 60 * 1000);
@@ -0,0 +1,227 @@
+package router
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/algao1/ichor/store"
+	"github.com/gin-gonic/gin"
+)
+
+// Plausible glucose readings, in mmol/L. Anything outside is a unit or
+// export mistake rather than a reading.
+const (
+	minUploadGlucose = 1.0
+	maxUploadGlucose = 35.0
+)
+
+// Names of the sample blocks, used in line errors.
+const (
+	sampleGlucose = "glucose"
+	sampleCarbs   = "carbs"
+	sampleInsulin = "insulin"
+)
+
+// sample is a line of a data block that was parsed.
+type sample struct {
+	line  int
+	time  time.Time
+	value float64
+}
+
+// splitLines splits a block of Health samples, which are separated by
+// newlines and may end with an empty line.
+func splitLines(s string) []string {
+	if strings.TrimSpace(s) == "" {
+		return nil
+	}
+	lines := strings.Split(s, "\n")
+	for i := range lines {
+		lines[i] = strings.TrimSpace(lines[i])
+	}
+	return lines
+}
+
+// parseSamples pairs the values and dates of a block line by line,
+// recording an error for every line that cannot be parsed.
+func parseSamples(name string, b DataBlock, res *UploadResult) []sample {
+	values, dates := splitLines(b.Values), splitLines(b.Dates)
+	n := len(values)
+	if len(dates) > n {
+		n = len(dates)
+	}
+
+	samples := make([]sample, 0, n)
+	for i := 0; i < n; i++ {
+		var v, d string
+		if i < len(values) {
+			v = values[i]
+		}
+		if i < len(dates) {
+			d = dates[i]
+		}
+		if v == "" && d == "" {
+			continue
+		}
+
+		lineErr := func(format string, a ...interface{}) {
+			res.Errors = append(res.Errors, LineError{Sample: name, Line: i + 1, Error: fmt.Sprintf(format, a...)})
+		}
+		if v == "" {
+			lineErr("missing value for date %q", d)
+			continue
+		}
+		if d == "" {
+			lineErr("missing date for value %q", v)
+			continue
+		}
+
+		t, err := time.Parse(time.RFC3339, d)
+		if err != nil {
+			lineErr("invalid date %q", d)
+			continue
+		}
+		// Health formats decimals with the locale of the phone.
+		val, err := strconv.ParseFloat(strings.Replace(v, ",", ".", 1), 64)
+		if err != nil || math.IsNaN(val) || math.IsInf(val, 0) {
+			lineErr("invalid value %q", v)
+			continue
+		}
+
+		samples = append(samples, sample{line: i + 1, time: t, value: val})
+	}
+	return samples
+}
+
+// addSample saves pt at t, unless an entry of the field is already stored
+// at the same second. It reports whether pt was saved.
+func addSample(us *store.Store, field string, t time.Time, pt interface{}) (bool, error) {
+	var existing []json.RawMessage
+	if err := us.GetPoints(t, t, field, &existing); err != nil {
+		return false, err
+	}
+	if len(existing) > 0 {
+		return false, nil
+	}
+	return true, us.AddPoint(field, t, pt)
+}
+
+// uploadGlucose saves the glucose readings, carbohydrate intakes and
+// insulin doses exported from Apple Health. Valid samples are saved even if
+// others are not, and samples already stored are skipped, so that a payload
+// can be sent again after fixing it.
+func uploadGlucose(s *store.Store) func(*gin.Context) {
+	return func(c *gin.Context) {
+		var hd HealthData
+		if err := c.ShouldBind(&hd); err != nil {
+			errorResponse(c, http.StatusBadRequest, fmt.Errorf("invalid health data: %w", err))
+			return
+		}
+
+		scale := 1.0
+		switch hd.Data.Units {
+		case "", store.UnitMmol:
+		case store.UnitMgdl:
+			scale = 1 / store.MgdlPerMmol
+		default:
+			errorResponse(c, http.StatusBadRequest, fmt.Errorf("units must be one of %s or %s, got %s",
+				store.UnitMmol, store.UnitMgdl, hd.Data.Units))
+			return
+		}
+
+		us, status, err := userStore(s, c)
+		if err != nil {
+			errorResponse(c, status, err)
+			return
+		}
+
+		var res UploadResult
+		glucose := parseSamples(sampleGlucose, hd.Data, &res)
+		carbs := parseSamples(sampleCarbs, hd.Carbs, &res)
+		insulin := parseSamples(sampleInsulin, hd.Insulin, &res)
+		if len(glucose)+len(carbs)+len(insulin)+len(res.Errors) == 0 {
+			errorResponse(c, http.StatusBadRequest, fmt.Errorf("no samples given"))
+			return
+		}
+
+		// An unknown product only fails the insulin lines, and the other
+		// samples are still saved.
+		var product *store.InsulinProduct
+		var productErr error
+		if len(insulin) > 0 {
+			var products []store.InsulinProduct
+			if err := us.GetObject(store.IndexInsulinProducts, &products); err != nil {
+				errorResponse(c, http.StatusInternalServerError, fmt.Errorf("unable to load insulin products: %w", err))
+				return
+			}
+			product, productErr = store.FindInsulinProduct(products, hd.Insulin.Product)
+		}
+
+		// save records the outcome of saving a sample, and reports whether the
+		// upload can go on.
+		save := func(saved bool, err error) bool {
+			if err != nil {
+				errorResponse(c, http.StatusInternalServerError, fmt.Errorf("unable to save sample: %w", err))
+				return false
+			}
+			if saved {
+				res.Inserted++
+			} else {
+				res.Skipped++
+			}
+			return true
+		}
+
+		for _, smp := range glucose {
+			val := smp.value * scale
+			if val < minUploadGlucose || val > maxUploadGlucose {
+				res.Errors = append(res.Errors, LineError{Sample: sampleGlucose, Line: smp.line,
+					Error: fmt.Sprintf("glucose must be between %g and %g mmol/L, got %.2f", minUploadGlucose, maxUploadGlucose, val)})
+				continue
+			}
+			if !save(addSample(us, store.FieldGlucose, smp.time, &store.TimePoint{Time: smp.time, Value: val, Trend: store.Missing})) {
+				return
+			}
+		}
+
+		for _, smp := range carbs {
+			grams := int(math.Round(smp.value))
+			if grams <= 0 {
+				res.Errors = append(res.Errors, LineError{Sample: sampleCarbs, Line: smp.line,
+					Error: fmt.Sprintf("carbohydrates must be at least a gram, got %g", smp.value)})
+				continue
+			}
+			carb := store.Carbohydrate{Time: smp.time, Value: grams}
+			if !save(addSample(us, store.FieldCarbohydrate, smp.time, carb)) {
+				return
+			}
+		}
+
+		for _, smp := range insulin {
+			if productErr != nil {
+				res.Errors = append(res.Errors, LineError{Sample: sampleInsulin, Line: smp.line, Error: productErr.Error()})
+				continue
+			}
+			if smp.value <= 0 {
+				res.Errors = append(res.Errors, LineError{Sample: sampleInsulin, Line: smp.line,
+					Error: fmt.Sprintf("insulin dose must be positive, got %g", smp.value)})
+				continue
+			}
+			dose := store.Insulin{Time: smp.time, Type: product.Category, Product: product.Name, Value: smp.value}
+			if !save(addSample(us, store.FieldInsulin, smp.time, dose)) {
+				return
+			}
+		}
+
+		status = http.StatusOK
+		if len(res.Errors) > 0 {
+			status = http.StatusBadRequest
+		}
+		c.JSON(status, res)
+	}
+}
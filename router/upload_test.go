@@ -0,0 +1,236 @@
+package router
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/algao1/ichor/store"
+)
+
+var uploadStart = time.Date(2022, 3, 1, 12, 0, 0, 0, time.UTC)
+
+// dates returns n lines of RFC 3339 times, 5 minutes apart.
+func dates(n int) string {
+	lines := make([]string, n)
+	for i := range lines {
+		lines[i] = uploadStart.Add(time.Duration(i) * 5 * time.Minute).Format(time.RFC3339)
+	}
+	return strings.Join(lines, "\n")
+}
+
+func TestUploadGlucose(t *testing.T) {
+	tests := []struct {
+		name         string
+		body         HealthData
+		wantStatus   int
+		wantInserted int
+		wantErrors   []LineError // Only Sample and Line are compared.
+		wantGlucose  []float64
+		wantCarbs    int
+		wantInsulin  int
+	}{
+		{
+			name:         "mmol/L",
+			body:         HealthData{Data: DataBlock{Values: "5.5\n6,5\n", Dates: dates(2) + "\n"}},
+			wantStatus:   http.StatusOK,
+			wantInserted: 2,
+			wantGlucose:  []float64{5.5, 6.5},
+		},
+		{
+			name:         "mg/dL",
+			body:         HealthData{Data: DataBlock{Values: "90\n180", Dates: dates(2), Units: store.UnitMgdl}},
+			wantStatus:   http.StatusOK,
+			wantInserted: 2,
+			wantGlucose:  []float64{5, 10},
+		},
+		{
+			name:         "out of range",
+			body:         HealthData{Data: DataBlock{Values: "0.5\n6\n40", Dates: dates(3)}},
+			wantStatus:   http.StatusBadRequest,
+			wantInserted: 1,
+			wantErrors:   []LineError{{Sample: sampleGlucose, Line: 1}, {Sample: sampleGlucose, Line: 3}},
+			wantGlucose:  []float64{6},
+		},
+		{
+			// 700 mg/dL is 38.9 mmol/L.
+			name:         "out of range in mg/dL",
+			body:         HealthData{Data: DataBlock{Values: "700\n108", Dates: dates(2), Units: store.UnitMgdl}},
+			wantStatus:   http.StatusBadRequest,
+			wantInserted: 1,
+			wantErrors:   []LineError{{Sample: sampleGlucose, Line: 1}},
+			wantGlucose:  []float64{6},
+		},
+		{
+			name:         "malformed lines",
+			body:         HealthData{Data: DataBlock{Values: "5.5\nhigh\n6", Dates: dates(2) + "\nyesterday"}},
+			wantStatus:   http.StatusBadRequest,
+			wantInserted: 1,
+			wantErrors:   []LineError{{Sample: sampleGlucose, Line: 2}, {Sample: sampleGlucose, Line: 3}},
+			wantGlucose:  []float64{5.5},
+		},
+		{
+			name:         "more values than dates",
+			body:         HealthData{Data: DataBlock{Values: "5\n6\n7", Dates: dates(2)}},
+			wantStatus:   http.StatusBadRequest,
+			wantInserted: 2,
+			wantErrors:   []LineError{{Sample: sampleGlucose, Line: 3}},
+			wantGlucose:  []float64{5, 6},
+		},
+		{
+			name:         "more dates than values",
+			body:         HealthData{Data: DataBlock{Values: "5", Dates: dates(3)}},
+			wantStatus:   http.StatusBadRequest,
+			wantInserted: 1,
+			wantErrors:   []LineError{{Sample: sampleGlucose, Line: 2}, {Sample: sampleGlucose, Line: 3}},
+			wantGlucose:  []float64{5},
+		},
+		{
+			name: "carbs and insulin",
+			body: HealthData{
+				Carbs:   DataBlock{Values: "45\n0", Dates: dates(2)},
+				Insulin: DataBlock{Values: "3,5", Dates: dates(1), Product: "lispro"},
+			},
+			wantStatus:   http.StatusBadRequest,
+			wantInserted: 2,
+			wantErrors:   []LineError{{Sample: sampleCarbs, Line: 2}},
+			wantCarbs:    1,
+			wantInsulin:  1,
+		},
+		{
+			name: "first rapid-acting product",
+			body: HealthData{
+				Insulin: DataBlock{Values: "2\n1", Dates: dates(2)},
+			},
+			wantStatus:   http.StatusOK,
+			wantInserted: 2,
+			wantInsulin:  2,
+		},
+		{
+			name: "unknown product",
+			body: HealthData{
+				Data:    DataBlock{Values: "5", Dates: dates(1)},
+				Carbs:   DataBlock{Values: "30", Dates: dates(1)},
+				Insulin: DataBlock{Values: "2\n1", Dates: dates(2), Product: "glargine"},
+			},
+			wantStatus:   http.StatusBadRequest,
+			wantInserted: 2,
+			wantErrors:   []LineError{{Sample: sampleInsulin, Line: 1}, {Sample: sampleInsulin, Line: 2}},
+			wantGlucose:  []float64{5},
+			wantCarbs:    1,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			r, us := newTestRouter(t)
+
+			w := do(r, http.MethodPost, "/upload/glucose", tc.body)
+			if w.Code != tc.wantStatus {
+				t.Errorf("got status %d, want %d: %s", w.Code, tc.wantStatus, w.Body)
+			}
+
+			var res UploadResult
+			if err := json.Unmarshal(w.Body.Bytes(), &res); err != nil {
+				t.Fatal(err)
+			}
+			if res.Inserted != tc.wantInserted || res.Skipped != 0 {
+				t.Errorf("got %d inserted and %d skipped, want %d and 0", res.Inserted, res.Skipped, tc.wantInserted)
+			}
+			if len(res.Errors) != len(tc.wantErrors) {
+				t.Fatalf("got errors %+v, want %d", res.Errors, len(tc.wantErrors))
+			}
+			for i, e := range res.Errors {
+				if e.Sample != tc.wantErrors[i].Sample || e.Line != tc.wantErrors[i].Line || e.Error == "" {
+					t.Errorf("got error %+v, want %s line %d", e, tc.wantErrors[i].Sample, tc.wantErrors[i].Line)
+				}
+			}
+
+			start, end := uploadStart.Add(-time.Hour), uploadStart.Add(time.Hour)
+			var glucose []store.TimePoint
+			if err := us.GetPoints(start, end, store.FieldGlucose, &glucose); err != nil {
+				t.Fatal(err)
+			}
+			if len(glucose) != len(tc.wantGlucose) {
+				t.Fatalf("got %d stored readings, want %d", len(glucose), len(tc.wantGlucose))
+			}
+			for i, pt := range glucose {
+				if !almostEqual(pt.Value, tc.wantGlucose[i]) {
+					t.Errorf("got reading %.2f, want %.2f", pt.Value, tc.wantGlucose[i])
+				}
+			}
+
+			var carbs []store.Carbohydrate
+			if err := us.GetPoints(start, end, store.FieldCarbohydrate, &carbs); err != nil {
+				t.Fatal(err)
+			}
+			var insulin []store.Insulin
+			if err := us.GetPoints(start, end, store.FieldInsulin, &insulin); err != nil {
+				t.Fatal(err)
+			}
+			if len(carbs) != tc.wantCarbs || len(insulin) != tc.wantInsulin {
+				t.Errorf("got %d carbs and %d doses stored, want %d and %d", len(carbs), len(insulin), tc.wantCarbs, tc.wantInsulin)
+			}
+			for _, dose := range insulin {
+				if dose.Product != "lispro" || dose.Type != store.RapidActing {
+					t.Errorf("got dose %+v, want a rapid-acting lispro dose", dose)
+				}
+			}
+		})
+	}
+}
+
+func TestUploadDuplicates(t *testing.T) {
+	r, _ := newTestRouter(t)
+
+	first := HealthData{Data: DataBlock{Values: "5\n6", Dates: dates(2)}}
+	if w := do(r, http.MethodPost, "/upload/glucose", first); w.Code != http.StatusOK {
+		t.Fatalf("got status %d: %s", w.Code, w.Body)
+	}
+
+	// Sending the readings again, with one more, only saves the new one.
+	again := HealthData{Data: DataBlock{Values: "5\n6\n7", Dates: dates(3)}}
+	w := do(r, http.MethodPost, "/upload/glucose", again)
+	if w.Code != http.StatusOK {
+		t.Fatalf("got status %d: %s", w.Code, w.Body)
+	}
+	var res UploadResult
+	if err := json.Unmarshal(w.Body.Bytes(), &res); err != nil {
+		t.Fatal(err)
+	}
+	if res.Inserted != 1 || res.Skipped != 2 {
+		t.Errorf("got %d inserted and %d skipped, want 1 and 2", res.Inserted, res.Skipped)
+	}
+}
+
+func TestUploadBadRequests(t *testing.T) {
+	r, _ := newTestRouter(t)
+
+	tests := []struct {
+		name string
+		body HealthData
+	}{
+		{"no samples", HealthData{}},
+		{"bad units", HealthData{Data: DataBlock{Values: "5", Dates: dates(1), Units: "mg"}}},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			w := do(r, http.MethodPost, "/upload/glucose", tc.body)
+			if w.Code != http.StatusBadRequest {
+				t.Errorf("got status %d, want %d: %s", w.Code, http.StatusBadRequest, w.Body)
+			}
+
+			var body map[string]string
+			if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil || body["error"] == "" {
+				t.Errorf("got body %s, want an error", w.Body)
+			}
+		})
+	}
+}
+
+func almostEqual(a, b float64) bool {
+	return a-b < 1e-9 && b-a < 1e-9
+}
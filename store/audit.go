@@ -0,0 +1,81 @@
+package store
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/boltdb/bolt"
+	"go.uber.org/zap"
+)
+
+// AddAudit records a change in the audit trail. Entries are keyed by their
+// time followed by a sequence number, so that changes made within the same
+// second are all kept.
+func (s *Store) AddAudit(e AuditEntry) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.DB.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(s.bucket(FieldAudit))
+		if b == nil {
+			return fmt.Errorf("unable to find bucket: %s", FieldAudit)
+		}
+
+		seq, err := b.NextSequence()
+		if err != nil {
+			return err
+		}
+
+		encoded, err := json.Marshal(e)
+		if err != nil {
+			return err
+		}
+
+		var key [16]byte
+		copy(key[:8], timeToBytes(e.Time))
+		binary.BigEndian.PutUint64(key[8:], seq)
+
+		s.logger.Debug("added audit entry",
+			zap.String("action", e.Action),
+			zap.String("field", e.Field),
+		)
+
+		return b.Put(key[:], encoded)
+	})
+}
+
+// GetAudits returns the audit entries recorded between start and end, in
+// the order they were made. Unlike GetPoints, it reads every entry of a
+// second rather than only the first.
+func (s *Store) GetAudits(start, end time.Time) ([]AuditEntry, error) {
+	min := timeToBytes(start)
+	max := timeToBytes(end)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var entries []AuditEntry
+	err := s.DB.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket(s.bucket(FieldAudit))
+		if b == nil {
+			return fmt.Errorf("unable to find bucket: %s", FieldAudit)
+		}
+
+		c := b.Cursor()
+		for k, v := c.Seek(min); k != nil && bytes.Compare(k[:8], max) <= 0; k, v = c.Next() {
+			var e AuditEntry
+			if err := json.Unmarshal(v, &e); err != nil {
+				return err
+			}
+			entries = append(entries, e)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
@@ -0,0 +1,42 @@
+package store
+
+import (
+	"fmt"
+	"time"
+)
+
+// Lifetime returns how long a device changed by the given event type, a
+// sensor or site change, lasts under conf.
+func (c Config) Lifetime(eventType string) time.Duration {
+	if eventType == EventSensorChange {
+		return c.SensorLifetime
+	}
+	return c.SiteLifetime
+}
+
+// GetDeviceChanges returns the events of the given type, such as sensor
+// changes, from oldest to latest.
+func (s *Store) GetDeviceChanges(eventType string) ([]Event, error) {
+	var events []Event
+	if err := s.GetPoints(time.Unix(0, 0), time.Now(), FieldEvent, &events); err != nil {
+		return nil, fmt.Errorf("unable to get events: %w", err)
+	}
+
+	changes := make([]Event, 0)
+	for _, e := range events {
+		if e.Type == eventType {
+			changes = append(changes, e)
+		}
+	}
+	return changes, nil
+}
+
+// LastDeviceChange returns the latest event of the given type, or nil if
+// there is none.
+func (s *Store) LastDeviceChange(eventType string) (*Event, error) {
+	changes, err := s.GetDeviceChanges(eventType)
+	if err != nil || len(changes) == 0 {
+		return nil, err
+	}
+	return &changes[len(changes)-1], nil
+}
@@ -0,0 +1,36 @@
+package store
+
+import (
+	"fmt"
+	"time"
+)
+
+// Digest kinds, the keys of the last-sent times under IndexDigests.
+const (
+	DigestDaily  = "daily"
+	DigestWeekly = "weekly"
+)
+
+// ClockFormat is the format of times of day in the config.
+const ClockFormat = "15:04"
+
+// ParseClock returns the time since midnight of a time of day given as
+// HH:MM.
+func ParseClock(v string) (time.Duration, error) {
+	t, err := time.Parse(ClockFormat, v)
+	if err != nil {
+		return 0, fmt.Errorf("time of day must be given as HH:MM, got %q", v)
+	}
+	return time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute, nil
+}
+
+// DigestClock returns the time since midnight at which digests of the given
+// kind are sent.
+func (c Config) DigestClock(kind string) time.Duration {
+	v := c.DailyDigest
+	if kind == DigestWeekly {
+		v = c.WeeklyDigest
+	}
+	d, _ := ParseClock(v)
+	return d
+}
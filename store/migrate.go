@@ -0,0 +1,159 @@
+package store
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// SchemaVersion is the version of the per-user data layout. Stores at an
+// older version are brought up to date by Migrate.
+const SchemaVersion = 4
+
+// legacyInsulinProducts are the products assumed for doses logged before
+// insulin products were introduced, by insulin type.
+var legacyInsulinProducts = map[string]string{
+	RapidActing: "lispro",
+	LongActing:  "degludec",
+}
+
+// Migrate upgrades the user's data to SchemaVersion.
+func (s *Store) Migrate() error {
+	var version int
+	err := s.GetObject(IndexSchemaVersion, &version)
+	if err != nil && !errors.Is(err, ErrNotFound) {
+		return fmt.Errorf("unable to load schema version: %w", err)
+	}
+
+	if version < 1 {
+		if err := s.migrateInsulinProducts(); err != nil {
+			return fmt.Errorf("unable to migrate insulin doses: %w", err)
+		}
+	}
+	if version < 2 {
+		if err := s.migrateConfigProfile(); err != nil {
+			return fmt.Errorf("unable to migrate config to profile: %w", err)
+		}
+	}
+	if version < 3 {
+		if err := s.migrateDigests(); err != nil {
+			return fmt.Errorf("unable to migrate config: %w", err)
+		}
+	}
+	if version < 4 {
+		if err := s.migrateExpiryReminder(); err != nil {
+			return fmt.Errorf("unable to migrate config: %w", err)
+		}
+	}
+
+	if version != SchemaVersion {
+		s.logger.Info("migrated store",
+			zap.String("prefix", s.prefix),
+			zap.Int("from", version),
+			zap.Int("to", SchemaVersion),
+		)
+	}
+
+	return s.AddObject(IndexSchemaVersion, SchemaVersion)
+}
+
+// migrateInsulinProducts sets the product of doses logged with only a type.
+// Their integer units are rewritten as floats.
+func (s *Store) migrateInsulinProducts() error {
+	var insulin []Insulin
+	if err := s.GetPoints(time.Unix(0, 0), time.Now(), FieldInsulin, &insulin); err != nil {
+		return err
+	}
+
+	for _, dose := range insulin {
+		if dose.Product != "" {
+			continue
+		}
+		dose.Product = legacyInsulinProducts[dose.Type]
+		if err := s.AddPoint(FieldInsulin, dose.Time, dose); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// migrateConfigProfile moves the insulin sensitivity, carb ratio and target
+// range kept in the config before profiles existed into the first version of
+// the profile, which must already be set up, and drops them from the config.
+func (s *Store) migrateConfigProfile() error {
+	var old struct {
+		InsulinSensitivity float64
+		CarbRatio          float64
+		TargetLow          float64
+		TargetHigh         float64
+	}
+	err := s.GetObject(IndexConfig, &old)
+	if errors.Is(err, ErrNotFound) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	p, err := s.GetProfile()
+	if err != nil {
+		return err
+	}
+	if old.InsulinSensitivity > 0 {
+		p.Sensitivities = Schedule{{Start: 0, Value: old.InsulinSensitivity}}
+	}
+	if old.CarbRatio > 0 {
+		p.CarbRatios = Schedule{{Start: 0, Value: old.CarbRatio}}
+	}
+	if old.TargetLow > 0 && old.TargetHigh > 0 {
+		p.Targets = TargetSchedule{{Start: 0, Low: old.TargetLow, High: old.TargetHigh}}
+	}
+	if err := p.Validate(); err != nil {
+		return err
+	}
+	// The values were in effect all along, so they replace the first version
+	// rather than saving a new one.
+	if err := s.AddObject(IndexProfile, p); err != nil {
+		return err
+	}
+
+	// Saving the config again leaves out the fields it no longer has.
+	var conf Config
+	if err := s.GetObject(IndexConfig, &conf); err != nil {
+		return err
+	}
+	return s.AddObject(IndexConfig, conf)
+}
+
+// migrateDigests turns on digests for configs saved before they existed,
+// which would otherwise read as turned off.
+func (s *Store) migrateDigests() error {
+	var conf Config
+	err := s.GetObject(IndexConfig, &conf)
+	if errors.Is(err, ErrNotFound) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	conf.Digests = true
+	return s.AddObject(IndexConfig, conf)
+}
+
+// migrateExpiryReminder sets the reminder lead time of configs saved before
+// it existed to the default of 12 hours. A lead time of 0 set afterwards is
+// kept, and sends the reminder at expiry.
+func (s *Store) migrateExpiryReminder() error {
+	var conf Config
+	err := s.GetObject(IndexConfig, &conf)
+	if errors.Is(err, ErrNotFound) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	conf.ExpiryReminder = 12 * time.Hour
+	return s.AddObject(IndexConfig, conf)
+}
@@ -0,0 +1,302 @@
+package store
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/boltdb/bolt"
+	"go.uber.org/zap"
+)
+
+// ScheduleEntry is a value in effect from Start, the time since local
+// midnight, until the start of the next entry.
+type ScheduleEntry struct {
+	Start time.Duration
+	Value float64
+}
+
+// Schedule is a time-of-day schedule, sorted by start.
+type Schedule []ScheduleEntry
+
+// At returns the value in effect at t, in loc.
+func (s Schedule) At(t time.Time, loc *time.Location) float64 {
+	i := scheduleIndex(len(s), func(i int) time.Duration { return s[i].Start }, t, loc)
+	if i < 0 {
+		return 0
+	}
+	return s[i].Value
+}
+
+// With returns the schedule with e added, replacing any entry with the same
+// start.
+func (s Schedule) With(e ScheduleEntry) Schedule {
+	out := make(Schedule, 0, len(s)+1)
+	for _, o := range s {
+		if o.Start != e.Start {
+			out = append(out, o)
+		}
+	}
+	out = append(out, e)
+	sort.Slice(out, func(i, j int) bool { return out[i].Start < out[j].Start })
+	return out
+}
+
+// Without returns the schedule without the entry starting at start.
+func (s Schedule) Without(start time.Duration) Schedule {
+	out := make(Schedule, 0, len(s))
+	for _, o := range s {
+		if o.Start != start {
+			out = append(out, o)
+		}
+	}
+	return out
+}
+
+// TargetEntry is a target range in effect from Start, the time since local
+// midnight, until the start of the next entry.
+type TargetEntry struct {
+	Start time.Duration
+	Low   float64
+	High  float64
+}
+
+// TargetSchedule is a time-of-day schedule of target ranges, sorted by start.
+type TargetSchedule []TargetEntry
+
+// At returns the target range in effect at t, in loc.
+func (s TargetSchedule) At(t time.Time, loc *time.Location) (float64, float64) {
+	i := scheduleIndex(len(s), func(i int) time.Duration { return s[i].Start }, t, loc)
+	if i < 0 {
+		return 0, 0
+	}
+	return s[i].Low, s[i].High
+}
+
+// With returns the schedule with e added, replacing any entry with the same
+// start.
+func (s TargetSchedule) With(e TargetEntry) TargetSchedule {
+	out := make(TargetSchedule, 0, len(s)+1)
+	for _, o := range s {
+		if o.Start != e.Start {
+			out = append(out, o)
+		}
+	}
+	out = append(out, e)
+	sort.Slice(out, func(i, j int) bool { return out[i].Start < out[j].Start })
+	return out
+}
+
+// Without returns the schedule without the entry starting at start.
+func (s TargetSchedule) Without(start time.Duration) TargetSchedule {
+	out := make(TargetSchedule, 0, len(s))
+	for _, o := range s {
+		if o.Start != start {
+			out = append(out, o)
+		}
+	}
+	return out
+}
+
+// scheduleIndex returns the index of the last of n entries starting at or
+// before the time of day of t, or -1 if there are none.
+func scheduleIndex(n int, start func(int) time.Duration, t time.Time, loc *time.Location) int {
+	t = t.In(loc)
+	tod := time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute +
+		time.Duration(t.Second())*time.Second
+
+	found := -1
+	for i := 0; i < n; i++ {
+		if start(i) <= tod {
+			found = i
+		}
+	}
+	return found
+}
+
+// Profile holds the therapy settings that vary with the time of day.
+// Glucose values are in mmol/L. Every change to the profile is saved as a
+// new version, in effect from Since.
+type Profile struct {
+	Version int
+	Since   time.Time
+
+	Basal         Schedule // Units of insulin per hour, empty without a pump.
+	CarbRatios    Schedule // Grams of carbohydrates covered by a unit of insulin.
+	Sensitivities Schedule // Drop in mmol/L per unit of insulin.
+	Targets       TargetSchedule
+}
+
+func (p Profile) Validate() error {
+	if len(p.Basal) > 0 {
+		if err := validateStarts(len(p.Basal), func(i int) time.Duration { return p.Basal[i].Start }); err != nil {
+			return fmt.Errorf("invalid basal schedule: %w", err)
+		}
+	}
+	for _, e := range p.Basal {
+		if e.Value < 0 || e.Value > 10 {
+			return fmt.Errorf("basal rate must be between 0 and 10 units per hour, got %.2f", e.Value)
+		}
+	}
+
+	if err := validateStarts(len(p.CarbRatios), func(i int) time.Duration { return p.CarbRatios[i].Start }); err != nil {
+		return fmt.Errorf("invalid carb ratio schedule: %w", err)
+	}
+	for _, e := range p.CarbRatios {
+		if e.Value < 1 || e.Value > 100 {
+			return fmt.Errorf("carb ratio must be between 1 and 100 grams per unit, got %.2f", e.Value)
+		}
+	}
+
+	if err := validateStarts(len(p.Sensitivities), func(i int) time.Duration { return p.Sensitivities[i].Start }); err != nil {
+		return fmt.Errorf("invalid sensitivity schedule: %w", err)
+	}
+	for _, e := range p.Sensitivities {
+		if e.Value < 0.1 || e.Value > 20 {
+			return fmt.Errorf("insulin sensitivity must be between 0.1 and 20 mmol/L per unit, got %.2f", e.Value)
+		}
+	}
+
+	if err := validateStarts(len(p.Targets), func(i int) time.Duration { return p.Targets[i].Start }); err != nil {
+		return fmt.Errorf("invalid target schedule: %w", err)
+	}
+	for _, e := range p.Targets {
+		if e.Low < 3 || e.High > 15 || e.Low > e.High {
+			return fmt.Errorf("target range must be within 3 and 15 mmol/L, got %.2f - %.2f", e.Low, e.High)
+		}
+	}
+
+	return nil
+}
+
+// validateStarts checks that a schedule of n entries starts at midnight, and
+// that its starts are increasing and within a day.
+func validateStarts(n int, start func(int) time.Duration) error {
+	if n == 0 || start(0) != 0 {
+		return fmt.Errorf("schedule must have an entry starting at 00:00")
+	}
+	for i := 1; i < n; i++ {
+		if start(i) <= start(i-1) {
+			return fmt.Errorf("schedule entries must have increasing start times")
+		}
+	}
+	if start(n-1) >= 24*time.Hour {
+		return fmt.Errorf("schedule entries must start within the day")
+	}
+	return nil
+}
+
+// GetProfile returns the current profile.
+func (s *Store) GetProfile() (*Profile, error) {
+	var p Profile
+	if err := s.GetObject(IndexProfile, &p); err != nil {
+		return nil, fmt.Errorf("unable to load profile: %w", err)
+	}
+	return &p, nil
+}
+
+// GetProfileHistory returns the profiles replaced so far, from oldest to
+// latest.
+func (s *Store) GetProfileHistory() ([]Profile, error) {
+	var history []Profile
+	err := s.GetObject(IndexProfileHistory, &history)
+	if err != nil && !errors.Is(err, ErrNotFound) {
+		return nil, fmt.Errorf("unable to load profile history: %w", err)
+	}
+	return history, nil
+}
+
+// SaveProfile validates p and makes it the current profile, in effect from
+// now as the next version. The previous profile is kept in the history. The
+// history and the current profile are written in one transaction, so that
+// a failure leaves neither changed.
+func (s *Store) SaveProfile(p Profile) (*Profile, error) {
+	if err := p.Validate(); err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	err := s.DB.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(s.bucket(FieldObject))
+		if b == nil {
+			return fmt.Errorf("unable to find bucket: %s", FieldObject)
+		}
+
+		p.Version = 1
+		if found := b.Get([]byte(IndexProfile)); found != nil {
+			var prev Profile
+			if err := json.Unmarshal(found, &prev); err != nil {
+				return fmt.Errorf("unable to unmarshal profile: %w", err)
+			}
+
+			var history []Profile
+			if found := b.Get([]byte(IndexProfileHistory)); found != nil {
+				if err := json.Unmarshal(found, &history); err != nil {
+					return fmt.Errorf("unable to unmarshal profile history: %w", err)
+				}
+			}
+
+			encoded, err := json.Marshal(append(history, prev))
+			if err != nil {
+				return err
+			}
+			if err := b.Put([]byte(IndexProfileHistory), encoded); err != nil {
+				return fmt.Errorf("unable to save profile history: %w", err)
+			}
+			p.Version = prev.Version + 1
+		}
+		p.Since = time.Now()
+
+		encoded, err := json.Marshal(p)
+		if err != nil {
+			return err
+		}
+		if err := b.Put([]byte(IndexProfile), encoded); err != nil {
+			return fmt.Errorf("unable to save profile: %w", err)
+		}
+
+		s.logger.Debug("saved profile",
+			zap.Int("version", p.Version),
+		)
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+// GetProfiles returns every version of the profile, from oldest to latest.
+func (s *Store) GetProfiles() ([]Profile, error) {
+	history, err := s.GetProfileHistory()
+	if err != nil {
+		return nil, err
+	}
+	cur, err := s.GetProfile()
+	if err != nil {
+		return nil, err
+	}
+	return append(history, *cur), nil
+}
+
+// ProfileAt returns the version in profiles, ordered from oldest to latest,
+// that was in effect at t. The oldest version is returned for times before
+// any profile existed.
+func ProfileAt(profiles []Profile, t time.Time) Profile {
+	if len(profiles) == 0 {
+		return Profile{}
+	}
+
+	found := profiles[0]
+	for _, p := range profiles[1:] {
+		if !p.Since.After(t) {
+			found = p
+		}
+	}
+	return found
+}
@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"encoding/binary"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
 	"reflect"
@@ -15,10 +16,16 @@ import (
 	"go.uber.org/zap"
 )
 
+// ErrNotFound is returned when a requested object does not exist.
+var ErrNotFound = errors.New("not found")
+
 type Store struct {
 	DB     *bolt.DB
 	logger *zap.Logger
-	mu     sync.Mutex
+	mu     *sync.Mutex
+
+	// prefix scopes all buckets to a single user, see ForUser.
+	prefix string
 }
 
 func Create(logger *zap.Logger) (*Store, error) {
@@ -29,19 +36,41 @@ func Create(logger *zap.Logger) (*Store, error) {
 
 	logger.Info("created bolt database")
 
-	return &Store{DB: db, logger: logger}, nil
+	return &Store{DB: db, logger: logger, mu: &sync.Mutex{}}, nil
+}
+
+// ForUser returns a view of the store where every bucket is scoped to the
+// given user. The view shares the underlying database and lock.
+func (s *Store) ForUser(uid string) *Store {
+	return &Store{
+		DB:     s.DB,
+		logger: s.logger.With(zap.String("user", uid)),
+		mu:     s.mu,
+		prefix: s.prefix + uid + "/",
+	}
+}
+
+func (s *Store) bucket(field string) []byte {
+	return []byte(s.prefix + field)
 }
 
 // Initialize stands up the necessary buckets for future transactions.
+// The unscoped store only holds the user registry, user scoped stores
+// hold everything else.
 // TODO: This requires an overhaul, manually configuring bucket creation
 //			 is a bit of a hassle.
 func (s *Store) Initialize() error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
+	fields := Fields
+	if s.prefix == "" {
+		fields = GlobalFields
+	}
+
 	return s.DB.Update(func(tx *bolt.Tx) error {
-		for _, field := range Fields {
-			_, err := tx.CreateBucketIfNotExists([]byte(field))
+		for _, field := range fields {
+			_, err := tx.CreateBucketIfNotExists(s.bucket(field))
 			if err != nil {
 				return fmt.Errorf("unable to create bucket: %w", err)
 			}
@@ -100,6 +129,22 @@ func (s *Store) Export(filepath string) error {
 		return err
 	}
 
+	var events []Event
+	if err := s.GetPoints(time.Unix(0, 0), time.Now(), FieldEvent, &events); err != nil {
+		return err
+	}
+	if err := s.exportSingle(filepath, FieldEvent, events); err != nil {
+		return err
+	}
+
+	var episodes []Episode
+	if err := s.GetPoints(time.Unix(0, 0), time.Now(), FieldEpisode, &episodes); err != nil {
+		return err
+	}
+	if err := s.exportSingle(filepath, FieldEpisode, episodes); err != nil {
+		return err
+	}
+
 	s.logger.Info("completed export of database")
 
 	return nil
@@ -112,7 +157,7 @@ func (s *Store) AddPoint(field string, t time.Time, pt interface{}) error {
 	defer s.mu.Unlock()
 
 	return s.DB.Update(func(tx *bolt.Tx) error {
-		b := tx.Bucket([]byte(field))
+		b := tx.Bucket(s.bucket(field))
 		if b == nil {
 			return fmt.Errorf("unable to find bucket: %s", field)
 		}
@@ -137,7 +182,7 @@ func (s *Store) DeletePoint(field string, t time.Time) error {
 	defer s.mu.Unlock()
 
 	return s.DB.Update(func(tx *bolt.Tx) error {
-		b := tx.Bucket([]byte(field))
+		b := tx.Bucket(s.bucket(field))
 		if b == nil {
 			return fmt.Errorf("unable to find bucket: %s", field)
 		}
@@ -158,7 +203,7 @@ func (s *Store) GetPoints(start, end time.Time, field string, ptsPtr interface{}
 	defer s.mu.Unlock()
 
 	err := s.DB.View(func(tx *bolt.Tx) error {
-		b := tx.Bucket([]byte(field))
+		b := tx.Bucket(s.bucket(field))
 		if b == nil {
 			return fmt.Errorf("unable to find bucket: %s", field)
 		}
@@ -213,7 +258,7 @@ func (s *Store) GetLastPoints(field string, last int, ptsPtr interface{}) error
 	defer s.mu.Unlock()
 
 	err := s.DB.View(func(tx *bolt.Tx) error {
-		b := tx.Bucket([]byte(field))
+		b := tx.Bucket(s.bucket(field))
 		if b == nil {
 			return fmt.Errorf("unable to find bucket: %s", field)
 		}
@@ -272,7 +317,7 @@ func (s *Store) AddObject(index string, obj interface{}) error {
 	defer s.mu.Unlock()
 
 	return s.DB.Update(func(tx *bolt.Tx) error {
-		b := tx.Bucket([]byte(FieldObject))
+		b := tx.Bucket(s.bucket(FieldObject))
 		if b == nil {
 			return fmt.Errorf("unable to find bucket: %s", FieldObject)
 		}
@@ -297,14 +342,14 @@ func (s *Store) GetObject(index string, obj interface{}) error {
 
 	var found []byte
 	err := s.DB.View(func(tx *bolt.Tx) error {
-		b := tx.Bucket([]byte(FieldObject))
+		b := tx.Bucket(s.bucket(FieldObject))
 		if b == nil {
 			return fmt.Errorf("unable to find bucket: %s", FieldObject)
 		}
 
 		found = b.Get([]byte(index))
 		if found == nil {
-			return fmt.Errorf("unable to find key: %s", index)
+			return fmt.Errorf("unable to find key %s: %w", index, ErrNotFound)
 		}
 
 		s.logger.Debug("found object",
@@ -1,32 +1,124 @@
 package store
 
-import "time"
+import (
+	"fmt"
+	"strings"
+	"time"
+)
 
 const (
 	FieldGlucose      = "glucose"
 	FieldGlucosePred  = "glucose-pred"
 	FieldCarbohydrate = "carbohydrate"
 	FieldInsulin      = "insulin"
+	FieldEvent        = "event"
+	FieldFingerstick  = "fingerstick"
+	FieldAlert        = "alert"
+	FieldEpisode      = "episode"
+	FieldAudit        = "audit"
 	FieldObject       = "obj"
+	FieldUsers        = "users"
+
+	IndexConfig = "config"
+	// IndexAlertTimeouts holds when each kind of alert may be sent again. It
+	// replaces "timeout-expire", which was only ever read and so is in no
+	// store to clean up.
+	IndexAlertTimeouts   = "alert-timeouts"
+	IndexStatusMessage   = "status-message"
+	IndexMealPresets     = "meal-presets"
+	IndexInsulinProducts = "insulin-products"
+	IndexSchemaVersion   = "schema-version"
+	IndexProfile         = "profile"
+	IndexProfileHistory  = "profile-history"
+	IndexDeviceReminders = "device-reminders"
+	IndexDigests         = "digests"
+	IndexEpisodeScan     = "episode-scan"
+)
+
+// Glucose units. Values are always stored in mmol/L, and only converted
+// for display and input.
+const (
+	UnitMmol = "mmol/L"
+	UnitMgdl = "mg/dL"
+
+	MgdlPerMmol = 18.0
+)
+
+// Audit actions.
+const (
+	AuditEdit   = "edit"
+	AuditDelete = "delete"
+)
+
+// Insulin categories.
+const (
+	RapidActing        = "rapid"
+	UltraRapidActing   = "ultra-rapid"
+	IntermediateActing = "intermediate"
+	LongActing         = "long"
+)
+
+// InsulinCategories lists the valid insulin categories.
+var InsulinCategories = []string{RapidActing, UltraRapidActing, IntermediateActing, LongActing}
+
+// Insulin activity curves, used for insulin on board.
+const (
+	CurveExponential = "exponential"
+	CurveWalsh       = "walsh"
+)
+
+// Carbohydrate absorption models, used for carbs on board.
+const (
+	AbsorptionLinear  = "linear"
+	AbsorptionDynamic = "dynamic"
+)
 
-	IndexConfig        = "config"
-	IndexTimeoutExpire = "timeout-expire"
+// Glycemic index categories of carbohydrate intakes.
+const (
+	GIFast   = "fast"
+	GIMedium = "medium"
+	GISlow   = "slow"
+)
+
+// Event types, for things other than meals and insulin that affect glucose.
+const (
+	EventExercise     = "exercise"
+	EventIllness      = "illness"
+	EventStress       = "stress"
+	EventAlcohol      = "alcohol"
+	EventSiteChange   = "site-change"
+	EventSensorChange = "sensor-change"
 )
 
-// Insulin types.
+// EventTypes lists the valid event types.
+var EventTypes = []string{EventExercise, EventIllness, EventStress, EventAlcohol, EventSiteChange, EventSensorChange}
+
+// Exercise intensities.
 const (
-	RapidActing = "rapid"
-	LongActing  = "long"
+	IntensityLight    = "light"
+	IntensityModerate = "moderate"
+	IntensityVigorous = "vigorous"
 )
 
+// Fields are the buckets kept for every registered user.
 var Fields = []string{
 	FieldGlucose,
 	FieldGlucosePred,
 	FieldCarbohydrate,
 	FieldInsulin,
+	FieldEvent,
+	FieldFingerstick,
+	FieldAlert,
+	FieldEpisode,
+	FieldAudit,
 	FieldObject,
 }
 
+// GlobalFields are the buckets shared by all users.
+var GlobalFields = []string{
+	FieldUsers,
+}
+
 type Trend int
 
 const (
@@ -46,19 +138,296 @@ type TimePoint struct {
 	Trend Trend     `csv:"trend"`
 }
 
+// Carbohydrate is a meal entry. Value is the carbohydrates in grams; the
+// remaining fields are optional.
 type Carbohydrate struct {
-	Time  time.Time `csv:"time"`
-	Value int       `csv:"value"`
+	Time    time.Time `csv:"time"`
+	Value   int       `csv:"value"`
+	Fat     int       `csv:"fat"`
+	Protein int       `csv:"protein"`
+	GI      string    `csv:"gi"`
+	Notes   string    `csv:"notes"`
+	Tags    Tags      `csv:"tags"`
+	Preset  string    `csv:"preset"` // Name of the meal preset logged, if any.
 }
 
+// Tags label a meal. They are exported to CSV as a single column, joined
+// by semicolons.
+type Tags []string
+
+func (t Tags) MarshalCSV() (string, error) {
+	return strings.Join(t, ";"), nil
+}
+
+func (t *Tags) UnmarshalCSV(s string) error {
+	*t = nil
+	if s != "" {
+		*t = strings.Split(s, ";")
+	}
+	return nil
+}
+
+// MealPreset is a saved meal that can be logged with a single command.
+type MealPreset struct {
+	Name    string
+	Carbs   int
+	GI      string
+	Bolus   float64 // Usual units of insulin, or 0 if none.
+	Insulin string  // Insulin product of the bolus, or empty for the first rapid-acting one.
+}
+
+// Insulin is a logged dose. Type is the category of the product.
 type Insulin struct {
-	Time  time.Time `csv:"time"`
-	Type  string    `csv:"type"`
-	Value int       `csv:"value"`
+	Time    time.Time `csv:"time"`
+	Type    string    `csv:"type"`
+	Product string    `csv:"product"`
+	Value   float64   `csv:"value"`
+}
+
+// Event is a logged exercise, illness or other event. Intensity is only set
+// for exercise, and Duration is zero for events without one, such as a site
+// change.
+type Event struct {
+	Time      time.Time     `csv:"time"`
+	Type      string        `csv:"type"`
+	Intensity string        `csv:"intensity"`
+	Duration  time.Duration `csv:"duration"`
+	Notes     string        `csv:"notes"`
+}
+
+func (e Event) Validate() error {
+	valid := false
+	for _, t := range EventTypes {
+		valid = valid || e.Type == t
+	}
+	if !valid {
+		return fmt.Errorf("unknown event type: %s", e.Type)
+	}
+
+	if e.Intensity != "" && e.Type != EventExercise {
+		return fmt.Errorf("only exercise has an intensity")
+	}
+	if e.Intensity != "" && e.Intensity != IntensityLight && e.Intensity != IntensityModerate &&
+		e.Intensity != IntensityVigorous {
+		return fmt.Errorf("intensity must be one of %s, %s or %s, got %s",
+			IntensityLight, IntensityModerate, IntensityVigorous, e.Intensity)
+	}
+	if e.Duration < 0 {
+		return fmt.Errorf("event duration must not be negative, got %s", e.Duration)
+	}
+	return nil
+}
+
+// InsulinProduct is a kind of insulin that doses can be logged for. Peak is
+// zero for peakless insulins.
+type InsulinProduct struct {
+	Name     string
+	Category string
+	Onset    time.Duration
+	Peak     time.Duration
+	Duration time.Duration
+}
+
+func (p InsulinProduct) Validate() error {
+	if p.Name == "" {
+		return fmt.Errorf("insulin product must have a name")
+	}
+
+	valid := false
+	for _, c := range InsulinCategories {
+		valid = valid || p.Category == c
+	}
+	if !valid {
+		return fmt.Errorf("unknown insulin category: %s", p.Category)
+	}
+
+	if p.Onset < 0 || p.Duration <= p.Onset {
+		return fmt.Errorf("insulin duration (%s) must be after its onset (%s)", p.Duration, p.Onset)
+	}
+	if p.Peak != 0 && (p.Peak < p.Onset || p.Peak > p.Duration) {
+		return fmt.Errorf("insulin peak (%s) must be between its onset (%s) and duration (%s)",
+			p.Peak, p.Onset, p.Duration)
+	}
+	return nil
+}
+
+// FindInsulinProduct returns the named product, or the first rapid-acting
+// one if name is empty.
+func FindInsulinProduct(products []InsulinProduct, name string) (*InsulinProduct, error) {
+	for i, p := range products {
+		if name == "" && (p.Category == RapidActing || p.Category == UltraRapidActing) {
+			return &products[i], nil
+		}
+		if name != "" && strings.EqualFold(p.Name, name) {
+			return &products[i], nil
+		}
+	}
+	if name == "" {
+		return nil, fmt.Errorf("no rapid-acting insulin product")
+	}
+	return nil, fmt.Errorf("unknown insulin product: %s", name)
+}
+
+// Follower alert subscriptions.
+const (
+	AlertsAll    = "all"
+	AlertsLows   = "lows"
+	AlertsUrgent = "urgent"
+	AlertsNone   = "none"
+)
+
+// Kinds of alerts.
+const (
+	AlertLow       = "low"
+	AlertUrgentLow = "urgent-low"
+	AlertHigh      = "high"
+)
+
+// SentAlert records an alert sent to a user, raised by the predicted point
+// that crossed a threshold.
+type SentAlert struct {
+	Time      time.Time
+	Kind      string
+	Predicted TimePoint
+}
+
+// Episode is a hypoglycemic episode: at least 15 minutes below Threshold,
+// ending after 15 minutes back above it. Insulin and Carbs are the totals
+// logged in the hours before it started, and Alert is the time of the last
+// low alert sent before it, if any.
+type Episode struct {
+	Start     time.Time `csv:"start"`
+	End       time.Time `csv:"end"`
+	Threshold float64   `csv:"threshold"`
+	Nadir     float64   `csv:"nadir"`
+	NadirTime time.Time `csv:"nadir_time"`
+	Descent   float64   `csv:"descent"` // Rate of fall into the nadir, in mmol/L per minute.
+	Insulin   float64   `csv:"insulin"`
+	Carbs     int       `csv:"carbs"`
+	Alert     time.Time `csv:"alert"`
+}
+
+func (e Episode) Duration() time.Duration {
+	return e.End.Sub(e.Start)
+}
+
+// Alerted reports whether a low alert was sent before the episode.
+func (e Episode) Alerted() bool {
+	return !e.Alert.IsZero()
+}
+
+// AuditEntry records a change made to a logged entry. Before and After hold
+// the entry as it was, and as it became (nil when deleted).
+type AuditEntry struct {
+	Time   time.Time
+	Action string
+	Field  string
+	Before interface{}
+	After  interface{}
+}
+
+// User is a registered patient, identified by their Discord user ID.
+type User struct {
+	ID             string
+	DexcomAccount  string
+	DexcomPassword string
+	Followers      []Follower
+}
+
+// Follower is a Discord user with read access to a patient's data, and
+// a subscription to some of their alerts.
+type Follower struct {
+	ID     string
+	Alerts string
 }
 
 type Config struct {
-	WarningTimeout time.Duration
-	LowThreshold   float64
-	HighThreshold  float64
+	WarningTimeout      time.Duration
+	LowThreshold        float64
+	HighThreshold       float64
+	UrgentLowThreshold  float64
+	Timezone            string
+	Units               string
+	LiveStatus          bool
+	StatusChartInterval time.Duration
+	InsulinCurve        string
+	CarbAbsorption      string
+	SensorLifetime      time.Duration
+	SiteLifetime        time.Duration
+	ExpiryReminder      time.Duration // How long before a sensor or site expires to send a reminder.
+	Digests             bool
+	DailyDigest         string // Local time of the overnight digest, as HH:MM.
+	WeeklyDigest        string // Local time of the weekly digest on Mondays, as HH:MM.
+}
+
+// StatusMessage tracks the live status message kept in a user's private
+// channel, so that it can be edited across restarts.
+type StatusMessage struct {
+	ChannelID uint64
+	MessageID uint64
+	LastChart time.Time
+}
+
+// Location returns the configured timezone, falling back to UTC if it
+// cannot be loaded.
+func (c Config) Location() *time.Location {
+	loc, err := time.LoadLocation(c.Timezone)
+	if err != nil {
+		return time.UTC
+	}
+	return loc
+}
+
+// Validate checks that the configuration values are sensible, thresholds
+// are always given in mmol/L.
+func (c Config) Validate() error {
+	if c.LowThreshold < 2 || c.LowThreshold > 10 {
+		return fmt.Errorf("low threshold must be between 2 and 10 mmol/L, got %.2f", c.LowThreshold)
+	}
+	if c.HighThreshold < 5 || c.HighThreshold > 25 {
+		return fmt.Errorf("high threshold must be between 5 and 25 mmol/L, got %.2f", c.HighThreshold)
+	}
+	if c.UrgentLowThreshold < 2 || c.UrgentLowThreshold > c.LowThreshold {
+		return fmt.Errorf("urgent low threshold must be between 2 mmol/L and the low threshold (%.2f), got %.2f",
+			c.LowThreshold, c.UrgentLowThreshold)
+	}
+	if c.LowThreshold >= c.HighThreshold {
+		return fmt.Errorf("low threshold (%.2f) must be below high threshold (%.2f)",
+			c.LowThreshold, c.HighThreshold)
+	}
+	if c.WarningTimeout < 0 {
+		return fmt.Errorf("warning timeout must not be negative, got %s", c.WarningTimeout)
+	}
+	if c.StatusChartInterval < 5*time.Minute {
+		return fmt.Errorf("status chart interval must be at least 5 minutes, got %s", c.StatusChartInterval)
+	}
+	if _, err := time.LoadLocation(c.Timezone); err != nil {
+		return fmt.Errorf("unknown timezone: %s", c.Timezone)
+	}
+	if c.Units != UnitMmol && c.Units != UnitMgdl {
+		return fmt.Errorf("units must be one of %s or %s, got %s", UnitMmol, UnitMgdl, c.Units)
+	}
+	if c.InsulinCurve != CurveExponential && c.InsulinCurve != CurveWalsh {
+		return fmt.Errorf("insulin curve must be one of %s or %s, got %s", CurveExponential, CurveWalsh, c.InsulinCurve)
+	}
+	if c.CarbAbsorption != AbsorptionLinear && c.CarbAbsorption != AbsorptionDynamic {
+		return fmt.Errorf("carb absorption must be one of %s or %s, got %s",
+			AbsorptionLinear, AbsorptionDynamic, c.CarbAbsorption)
+	}
+	if c.SensorLifetime < 24*time.Hour || c.SensorLifetime > 30*24*time.Hour {
+		return fmt.Errorf("sensor lifetime must be between 1 and 30 days, got %s", c.SensorLifetime)
+	}
+	if c.SiteLifetime < 24*time.Hour || c.SiteLifetime > 7*24*time.Hour {
+		return fmt.Errorf("site lifetime must be between 1 and 7 days, got %s", c.SiteLifetime)
+	}
+	if c.ExpiryReminder < 0 || c.ExpiryReminder > 24*time.Hour {
+		return fmt.Errorf("expiry reminder must be between 0 and 24 hours, got %s", c.ExpiryReminder)
+	}
+	if _, err := ParseClock(c.DailyDigest); err != nil {
+		return fmt.Errorf("invalid daily digest time: %w", err)
+	}
+	if _, err := ParseClock(c.WeeklyDigest); err != nil {
+		return fmt.Errorf("invalid weekly digest time: %w", err)
+	}
+	return nil
 }
@@ -0,0 +1,218 @@
+package store
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/boltdb/bolt"
+	"go.uber.org/zap"
+)
+
+// AddUser registers a user, replacing any previous registration with the
+// same ID.
+func (s *Store) AddUser(u User) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.DB.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(FieldUsers))
+		if b == nil {
+			return fmt.Errorf("unable to find bucket: %s", FieldUsers)
+		}
+
+		encoded, err := json.Marshal(u)
+		if err != nil {
+			return err
+		}
+
+		s.logger.Debug("added user",
+			zap.String("id", u.ID),
+		)
+
+		return b.Put([]byte(u.ID), encoded)
+	})
+}
+
+// GetUser returns the registered user with the given ID, or an error
+// wrapping ErrNotFound if the user is not registered.
+func (s *Store) GetUser(uid string) (*User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var u User
+	err := s.DB.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(FieldUsers))
+		if b == nil {
+			return fmt.Errorf("unable to find bucket: %s", FieldUsers)
+		}
+
+		found := b.Get([]byte(uid))
+		if found == nil {
+			return fmt.Errorf("unable to find user %s: %w", uid, ErrNotFound)
+		}
+
+		return json.Unmarshal(found, &u)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &u, nil
+}
+
+// GetUsers returns all registered users.
+func (s *Store) GetUsers() ([]User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	users := make([]User, 0)
+	err := s.DB.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(FieldUsers))
+		if b == nil {
+			return fmt.Errorf("unable to find bucket: %s", FieldUsers)
+		}
+
+		return b.ForEach(func(k, v []byte) error {
+			var u User
+			if err := json.Unmarshal(v, &u); err != nil {
+				return err
+			}
+			users = append(users, u)
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return users, nil
+}
+
+// updateUser applies fn to the registered user with the given ID, and saves
+// the result within a single transaction.
+func (s *Store) updateUser(uid string, fn func(u *User) error) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.DB.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(FieldUsers))
+		if b == nil {
+			return fmt.Errorf("unable to find bucket: %s", FieldUsers)
+		}
+
+		found := b.Get([]byte(uid))
+		if found == nil {
+			return fmt.Errorf("unable to find user %s: %w", uid, ErrNotFound)
+		}
+
+		var u User
+		if err := json.Unmarshal(found, &u); err != nil {
+			return err
+		}
+
+		if err := fn(&u); err != nil {
+			return err
+		}
+
+		encoded, err := json.Marshal(u)
+		if err != nil {
+			return err
+		}
+
+		return b.Put([]byte(uid), encoded)
+	})
+}
+
+// AddFollower grants a follower read access to the user's data, replacing
+// the alert subscription if they already follow the user.
+func (s *Store) AddFollower(uid string, f Follower) error {
+	return s.updateUser(uid, func(u *User) error {
+		for i := range u.Followers {
+			if u.Followers[i].ID == f.ID {
+				u.Followers[i] = f
+				return nil
+			}
+		}
+		u.Followers = append(u.Followers, f)
+		return nil
+	})
+}
+
+// RemoveFollower revokes a follower's access to the user's data.
+func (s *Store) RemoveFollower(uid, fid string) error {
+	return s.updateUser(uid, func(u *User) error {
+		for i := range u.Followers {
+			if u.Followers[i].ID == fid {
+				u.Followers = append(u.Followers[:i], u.Followers[i+1:]...)
+				return nil
+			}
+		}
+		return fmt.Errorf("unable to find follower %s: %w", fid, ErrNotFound)
+	})
+}
+
+// GetFollowed returns the users that the given follower has access to.
+func (s *Store) GetFollowed(fid string) ([]User, error) {
+	users, err := s.GetUsers()
+	if err != nil {
+		return nil, err
+	}
+
+	followed := make([]User, 0)
+	for _, u := range users {
+		for _, f := range u.Followers {
+			if f.ID == fid {
+				followed = append(followed, u)
+				break
+			}
+		}
+	}
+
+	return followed, nil
+}
+
+// MigrateUnscoped moves data from the unscoped buckets used before multi-user
+// support into the buckets of the given user. Buckets that the user already
+// has data in are left untouched.
+func (s *Store) MigrateUnscoped(uid string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.DB.Update(func(tx *bolt.Tx) error {
+		for _, field := range Fields {
+			src := tx.Bucket([]byte(field))
+			if src == nil {
+				continue
+			}
+
+			dst, err := tx.CreateBucketIfNotExists([]byte(uid + "/" + field))
+			if err != nil {
+				return fmt.Errorf("unable to create bucket: %w", err)
+			}
+			if k, _ := dst.Cursor().First(); k != nil {
+				continue
+			}
+
+			var moved int
+			err = src.ForEach(func(k, v []byte) error {
+				moved++
+				return dst.Put(k, v)
+			})
+			if err != nil {
+				return err
+			}
+
+			if err := tx.DeleteBucket([]byte(field)); err != nil {
+				return err
+			}
+
+			s.logger.Info("migrated unscoped bucket",
+				zap.String("bucket", field),
+				zap.String("user", uid),
+				zap.Int("moved", moved),
+			)
+		}
+
+		return nil
+	})
+}
@@ -2,27 +2,90 @@ package main
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"sort"
 	"time"
 
 	"github.com/algao1/ichor/discord"
 	"github.com/algao1/ichor/glucose/dexcom"
+	"github.com/algao1/ichor/glucose/metrics"
+	"github.com/algao1/ichor/glucose/onboard"
+	"github.com/algao1/ichor/glucose/patterns"
 	"github.com/algao1/ichor/glucose/predictor"
+	"github.com/algao1/ichor/glucose/report"
 	"github.com/algao1/ichor/store"
 	"go.uber.org/zap"
 )
 
 const (
-	DefaultMinutes  = 1440
-	DefaultMaxCount = 288
-	DefaultLookBack = -4 * time.Hour
+	DefaultMinutes      = 1440
+	DefaultMaxCount     = 288
+	DefaultLookBack     = -4 * time.Hour
+	DefaultAlertHorizon = 30 * time.Minute
+
+	// SensorWarmUp and MaxSensorWarmUp bound a gap in readings that is taken
+	// to be the warm-up of a new sensor.
+	SensorWarmUp    = 2 * time.Hour
+	MaxSensorWarmUp = 3 * time.Hour
+
+	// ReminderGrace is how long after a device expired reminders are still
+	// sent, so that old changes don't trigger them.
+	ReminderGrace = 24 * time.Hour
+
+	// EpisodeLookBack is how far back low episodes are detected again after
+	// every upload, so that ongoing episodes are extended.
+	EpisodeLookBack = 24 * time.Hour
+	// EpisodeContext is how long before an episode the insulin and carbs
+	// logged are totalled.
+	EpisodeContext = 4 * time.Hour
+	// AlertLead is how long before an episode a low alert counts as having
+	// warned of it.
+	AlertLead = time.Hour
+	// DescentWindow is how long before the nadir of an episode its rate of
+	// descent is measured from.
+	DescentWindow = 30 * time.Minute
+
+	// DigestNight is the period covered by the overnight digest, up to the
+	// time it is sent.
+	DigestNight = 8 * time.Hour
+	// DigestCatchUp is how far back digests missed while the bot was down
+	// are still sent.
+	DigestCatchUp = 7 * 24 * time.Hour
 )
 
+// alertOrder lists the types of alert from most to least urgent.
+var alertOrder = []discord.AlertType{discord.UrgentLow, discord.Low, discord.High}
+
+// alertKinds are the stored kinds of each type of alert.
+var alertKinds = map[discord.AlertType]string{
+	discord.Low:       store.AlertLow,
+	discord.UrgentLow: store.AlertUrgentLow,
+	discord.High:      store.AlertHigh,
+}
+
+// deviceNames are the devices replaced by each kind of change event.
+var deviceNames = map[string]string{
+	store.EventSensorChange: "sensor",
+	store.EventSiteChange:   "infusion site",
+}
+
 func RunUploader(client *dexcom.Client, s *store.Store, logger *zap.Logger) {
 	ticker := time.NewTicker(1 * time.Minute)
 	defer ticker.Stop()
 
 	for time.Now(); true; <-ticker.C {
+		// The timezone can be changed at any time, so it is read before
+		// every fetch.
+		var conf store.Config
+		if err := s.GetObject(store.IndexConfig, &conf); err != nil {
+			logger.Info("failed to load config",
+				zap.Error(err),
+			)
+			continue
+		}
+		client.SetLocation(conf.Location())
+
 		trs, err := client.GetReadings(DefaultMinutes, DefaultMaxCount)
 		if err != nil {
 			logger.Info("failed to fetch readings",
@@ -44,10 +107,163 @@ func RunUploader(client *dexcom.Client, s *store.Store, logger *zap.Logger) {
 				)
 			}
 		}
+
+		if err := detectSensorChanges(s, trs); err != nil {
+			logger.Info("failed to detect sensor changes",
+				zap.Error(err),
+			)
+		}
+
+		if err := detectEpisodes(s); err != nil {
+			logger.Info("failed to detect low episodes",
+				zap.Error(err),
+			)
+		}
+	}
+}
+
+// detectSensorChanges logs a sensor change at the start of every gap in the
+// readings about as long as a sensor warm-up, unless a change was already
+// logged around it.
+func detectSensorChanges(s *store.Store, trs []*dexcom.TransformedReading) error {
+	times := make([]time.Time, len(trs))
+	for i, tr := range trs {
+		times[i] = tr.Time
+	}
+	sort.Slice(times, func(i, j int) bool { return times[i].Before(times[j]) })
+
+	changes, err := s.GetDeviceChanges(store.EventSensorChange)
+	if err != nil {
+		return err
+	}
+
+	for i := 1; i < len(times); i++ {
+		gap := times[i].Sub(times[i-1])
+		if gap < SensorWarmUp || gap > MaxSensorWarmUp {
+			continue
+		}
+
+		// Changes are often logged a little before the old sensor is
+		// removed, or during the warm-up.
+		logged := false
+		for _, c := range changes {
+			logged = logged || (c.Time.After(times[i-1].Add(-SensorWarmUp)) && !c.Time.After(times[i]))
+		}
+		if logged {
+			continue
+		}
+
+		change := store.Event{
+			Time:  times[i-1],
+			Type:  store.EventSensorChange,
+			Notes: "Detected from a warm-up gap in readings.",
+		}
+		if err := s.AddPoint(store.FieldEvent, change.Time, change); err != nil {
+			return fmt.Errorf("unable to save sensor change: %w", err)
+		}
+		changes = append(changes, change)
+	}
+
+	return nil
+}
+
+// detectEpisodes stores the low episodes in the readings since the last
+// scan, and since the start of the history on the first scan. Episodes are
+// keyed by their start, so that detecting one again updates it.
+func detectEpisodes(s *store.Store) error {
+	var conf store.Config
+	if err := s.GetObject(store.IndexConfig, &conf); err != nil {
+		return fmt.Errorf("unable to load config: %w", err)
+	}
+
+	var scanned time.Time
+	err := s.GetObject(store.IndexEpisodeScan, &scanned)
+	if err != nil && !errors.Is(err, store.ErrNotFound) {
+		return fmt.Errorf("unable to load last scan: %w", err)
+	}
+
+	now := time.Now()
+	start := time.Unix(0, 0)
+	if !scanned.IsZero() {
+		start = scanned.Add(-EpisodeLookBack)
+	}
+
+	var pts []store.TimePoint
+	if err := s.GetPoints(start, now, store.FieldGlucose, &pts); err != nil {
+		return fmt.Errorf("unable to get points: %w", err)
+	}
+
+	for _, e := range metrics.LowEpisodes(pts, conf.LowThreshold) {
+		// An episode at the start of the readings may have started before
+		// them, and was stored by an earlier scan.
+		if e.Start.Equal(pts[0].Time) && !scanned.IsZero() {
+			continue
+		}
+
+		ep, err := describeEpisode(s, pts, e, conf.LowThreshold)
+		if err != nil {
+			return err
+		}
+		if err := s.AddPoint(store.FieldEpisode, ep.Start, ep); err != nil {
+			return fmt.Errorf("unable to save episode: %w", err)
+		}
+	}
+
+	return s.AddObject(store.IndexEpisodeScan, now)
+}
+
+// describeEpisode adds the nadir, rate of descent, treatments and alerts to
+// a low episode found in pts.
+func describeEpisode(s *store.Store, pts []store.TimePoint, e metrics.Episode, threshold float64) (store.Episode, error) {
+	ep := store.Episode{
+		Start:     e.Start,
+		End:       e.End,
+		Threshold: threshold,
+		Nadir:     e.Extreme,
+		NadirTime: e.Start,
+	}
+
+	for _, pt := range pts {
+		if !pt.Time.Before(e.Start) && !pt.Time.After(e.End) && pt.Value == e.Extreme {
+			ep.NadirTime = pt.Time
+			break
+		}
+	}
+	if v, ok := metrics.At(pts, ep.NadirTime.Add(-DescentWindow)); ok {
+		ep.Descent = (v - ep.Nadir) / DescentWindow.Minutes()
+	}
+
+	var insulin []store.Insulin
+	if err := s.GetPoints(e.Start.Add(-EpisodeContext), e.Start, store.FieldInsulin, &insulin); err != nil {
+		return ep, fmt.Errorf("unable to get insulin doses: %w", err)
+	}
+	for _, dose := range insulin {
+		ep.Insulin += dose.Value
+	}
+
+	var carbs []store.Carbohydrate
+	if err := s.GetPoints(e.Start.Add(-EpisodeContext), e.Start, store.FieldCarbohydrate, &carbs); err != nil {
+		return ep, fmt.Errorf("unable to get carbohydrates: %w", err)
+	}
+	for _, carb := range carbs {
+		ep.Carbs += carb.Value
+	}
+
+	var alerts []store.SentAlert
+	if err := s.GetPoints(e.Start.Add(-AlertLead), e.Start, store.FieldAlert, &alerts); err != nil {
+		return ep, fmt.Errorf("unable to get alerts: %w", err)
+	}
+	// Alerts are ordered oldest first, so the last low one is kept.
+	for _, alert := range alerts {
+		if alert.Kind == store.AlertLow || alert.Kind == store.AlertUrgentLow {
+			ep.Alert = alert.Time
+		}
 	}
+
+	return ep, nil
 }
 
-func RunPredictor(client *predictor.Client, s *store.Store, logger *zap.Logger, alertCh chan<- discord.Alert) {
+func RunPredictor(client *predictor.Client, s *store.Store, uid string, logger *zap.Logger, alertCh chan<- discord.Alert) {
 	ticker := time.NewTicker(1 * time.Minute)
 	defer ticker.Stop()
 
@@ -59,9 +275,6 @@ func RunPredictor(client *predictor.Client, s *store.Store, logger *zap.Logger,
 			panic(fmt.Errorf("failed to load config: %w", err))
 		}
 
-		var expire time.Time
-		s.GetObject(store.IndexTimeoutExpire, &expire)
-
 		var pastPoints []store.TimePoint
 		err = s.GetLastPoints(store.FieldGlucose, 4*12, &pastPoints)
 		if err != nil {
@@ -89,7 +302,26 @@ func RunPredictor(client *predictor.Client, s *store.Store, logger *zap.Logger,
 			continue
 		}
 
-		fpts, err := client.Predict(context.Background(), pastPoints, pastInsulin, pastCarbs)
+		var pastEvents []store.Event
+		err = s.GetPoints(time.Now().Add(DefaultLookBack), time.Now(), store.FieldEvent, &pastEvents)
+		if err != nil {
+			logger.Info("failed to get past events",
+				zap.Error(err),
+			)
+			continue
+		}
+
+		var ob *onboard.Calculator
+		if len(pastPoints) > 0 {
+			ob, err = onboard.New(s, pastPoints[0].Time, time.Now())
+			if err != nil {
+				logger.Info("failed to load insulin and carbs on board",
+					zap.Error(err),
+				)
+			}
+		}
+
+		fpts, err := client.Predict(context.Background(), pastPoints, pastInsulin, pastCarbs, pastEvents, ob)
 		if err != nil {
 			logger.Info("failed to make a prediction",
 				zap.Error(err),
@@ -105,16 +337,271 @@ func RunPredictor(client *predictor.Client, s *store.Store, logger *zap.Logger,
 			})
 		}
 
-		if expire.After(time.Now()) {
+		// Time until which each kind of alert is held back, after one was sent.
+		timeouts := make(map[string]time.Time)
+		err = s.GetObject(store.IndexAlertTimeouts, &timeouts)
+		if err != nil && !errors.Is(err, store.ErrNotFound) {
+			logger.Info("failed to load alert timeouts",
+				zap.Error(err),
+			)
+			continue
+		}
+
+		// Alert on the most urgent threshold crossed by a prediction within
+		// the horizon. A less urgent alert is never sent in place of one held
+		// back.
+		now := time.Now()
+		for _, t := range alertOrder {
+			fpt, ok := firstCrossing(fpts, now.Add(DefaultAlertHorizon), t, &conf)
+			if !ok {
+				continue
+			}
+			kind := alertKinds[t]
+			if heldBack(kind, timeouts, now) {
+				break
+			}
+
+			alert := discord.Alert{UserID: uid, Type: t, Point: fpt}
+			if ob != nil {
+				alert.IOB = ob.IOB(now)
+				alert.COB = ob.COB(now)
+			}
+			alertCh <- alert
+
+			timeouts[kind] = now.Add(conf.WarningTimeout)
+			if err := s.AddObject(store.IndexAlertTimeouts, timeouts); err != nil {
+				logger.Info("failed to save alert timeouts",
+					zap.Error(err),
+				)
+			}
+
+			sent := store.SentAlert{Time: now, Kind: kind, Predicted: fpt}
+			if err := s.AddPoint(store.FieldAlert, sent.Time, sent); err != nil {
+				logger.Info("failed to save alert",
+					zap.Error(err),
+				)
+			}
+			break
+		}
+	}
+}
+
+// firstCrossing returns the first prediction before horizon that crosses
+// the threshold of alerts of type t.
+func firstCrossing(fpts []store.TimePoint, horizon time.Time, t discord.AlertType, conf *store.Config) (store.TimePoint, bool) {
+	for _, fpt := range fpts {
+		if fpt.Time.After(horizon) {
+			break
+		}
+		switch {
+		case t == discord.UrgentLow && fpt.Value <= conf.UrgentLowThreshold,
+			t == discord.Low && fpt.Value <= conf.LowThreshold,
+			t == discord.High && fpt.Value >= conf.HighThreshold:
+			return fpt, true
+		}
+	}
+	return store.TimePoint{}, false
+}
+
+// heldBack reports whether an alert of the given kind is within the timeout
+// of one sent before. Low alerts are also held back by an urgent low alert,
+// but urgent low alerts only ever by another urgent low alert.
+func heldBack(kind string, timeouts map[string]time.Time, now time.Time) bool {
+	if timeouts[kind].After(now) {
+		return true
+	}
+	return kind == store.AlertLow && timeouts[store.AlertUrgentLow].After(now)
+}
+
+// RunReminders messages the user ahead of their sensor or infusion site
+// expiring, once per change.
+func RunReminders(s *store.Store, uid string, logger *zap.Logger, reminderCh chan<- discord.Reminder) {
+	ticker := time.NewTicker(5 * time.Minute)
+	defer ticker.Stop()
+
+	for ; true; <-ticker.C {
+		var conf store.Config
+		if err := s.GetObject(store.IndexConfig, &conf); err != nil {
+			logger.Info("failed to load config",
+				zap.Error(err),
+			)
+			continue
+		}
+
+		// Time of the last change reminded about, by event type.
+		reminded := make(map[string]time.Time)
+		err := s.GetObject(store.IndexDeviceReminders, &reminded)
+		if err != nil && !errors.Is(err, store.ErrNotFound) {
+			logger.Info("failed to load device reminders",
+				zap.Error(err),
+			)
+			continue
+		}
+
+		for _, eventType := range []string{store.EventSensorChange, store.EventSiteChange} {
+			last, err := s.LastDeviceChange(eventType)
+			if err != nil {
+				logger.Info("failed to get last device change",
+					zap.String("type", eventType),
+					zap.Error(err),
+				)
+				continue
+			}
+			if last == nil || reminded[eventType].Equal(last.Time) {
+				continue
+			}
+
+			now := time.Now()
+			expiry := last.Time.Add(conf.Lifetime(eventType))
+			if now.Before(expiry.Add(-conf.ExpiryReminder)) || now.After(expiry.Add(ReminderGrace)) {
+				continue
+			}
+
+			msg := fmt.Sprintf("⏰ your %s expires %s", deviceNames[eventType],
+				expiry.In(conf.Location()).Format(discord.TimeFormat))
+			if now.After(expiry) {
+				msg = fmt.Sprintf("⏰ your %s expired %s", deviceNames[eventType],
+					expiry.In(conf.Location()).Format(discord.TimeFormat))
+			}
+			reminderCh <- discord.Reminder{UserID: uid, Message: msg + "\nLog the new one with /devices change."}
+
+			reminded[eventType] = last.Time
+			if err := s.AddObject(store.IndexDeviceReminders, reminded); err != nil {
+				logger.Info("failed to save device reminders",
+					zap.Error(err),
+				)
+			}
+		}
+	}
+}
+
+// digestTitles are the titles of each kind of digest.
+var digestTitles = map[string]string{
+	store.DigestDaily:  "Overnight Digest",
+	store.DigestWeekly: "Weekly Digest",
+}
+
+// digestTimes returns the times digests of the given kind were due after
+// since, up to now. Weekly digests are due on Mondays.
+func digestTimes(conf *store.Config, kind string, since, now time.Time) []time.Time {
+	loc := conf.Location()
+	clock := conf.DigestClock(kind)
+
+	var times []time.Time
+	since = since.In(loc)
+	day := time.Date(since.Year(), since.Month(), since.Day(), 0, 0, 0, 0, loc)
+	for ; !day.After(now); day = day.AddDate(0, 0, 1) {
+		if kind == store.DigestWeekly && day.Weekday() != time.Monday {
+			continue
+		}
+		// Added to the wall clock, so that digests keep their time across DST.
+		t := time.Date(day.Year(), day.Month(), day.Day(), 0, int(clock.Minutes()), 0, 0, loc)
+		if t.After(since) && !t.After(now) {
+			times = append(times, t)
+		}
+	}
+	return times
+}
+
+// digestPeriod returns the period covered by a digest due at t: the night
+// before it, or the week from Monday to Sunday before it.
+func digestPeriod(kind string, t time.Time, loc *time.Location) (time.Time, time.Time) {
+	if kind == store.DigestWeekly {
+		t = t.In(loc)
+		monday := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, loc)
+		return monday.AddDate(0, 0, -7), monday
+	}
+	return t.Add(-DigestNight), t
+}
+
+// RunDigests sends the overnight and weekly digests at the times set in the
+// config. The time of the last digest of each kind is kept in the store, so
+// that restarts neither repeat nor skip digests.
+func RunDigests(s *store.Store, uid string, logger *zap.Logger, digestCh chan<- discord.Digest) {
+	ticker := time.NewTicker(5 * time.Minute)
+	defer ticker.Stop()
+
+	for ; true; <-ticker.C {
+		var conf store.Config
+		if err := s.GetObject(store.IndexConfig, &conf); err != nil {
+			logger.Info("failed to load config",
+				zap.Error(err),
+			)
+			continue
+		}
+
+		// Time the last digest was due, by kind.
+		sent := make(map[string]time.Time)
+		err := s.GetObject(store.IndexDigests, &sent)
+		if err != nil && !errors.Is(err, store.ErrNotFound) {
+			logger.Info("failed to load digests",
+				zap.Error(err),
+			)
 			continue
 		}
 
-		// if ftp.Value <= conf.LowThreshold {
-		// 	alertCh <- discord.Low
-		// 	s.AddObject(store.IndexTimeoutExpire, time.Now().Add(conf.WarningTimeout))
-		// } else if ftp.Value >= conf.HighThreshold {
-		// 	alertCh <- discord.High
-		// 	s.AddObject(store.IndexTimeoutExpire, time.Now().Add(conf.WarningTimeout))
-		// }
+		now := time.Now()
+		for _, kind := range []string{store.DigestDaily, store.DigestWeekly} {
+			last, ok := sent[kind]
+			// Start from now the first time, and while digests are turned
+			// off, so that turning them on does not send old digests.
+			if !ok || !conf.Digests {
+				sent[kind] = now
+				continue
+			}
+			if now.Sub(last) > DigestCatchUp {
+				last = now.Add(-DigestCatchUp)
+			}
+
+			for _, t := range digestTimes(&conf, kind, last, now) {
+				if err := sendDigest(s, uid, kind, t, &conf, digestCh); err != nil {
+					logger.Info("failed to send digest",
+						zap.String("kind", kind),
+						zap.Time("due", t),
+						zap.Error(err),
+					)
+					break
+				}
+				sent[kind] = t
+			}
+		}
+
+		if err := s.AddObject(store.IndexDigests, sent); err != nil {
+			logger.Info("failed to save digests",
+				zap.Error(err),
+			)
+		}
+	}
+}
+
+// sendDigest sends the digest of the given kind due at t. Periods without
+// readings are passed over. Weekly digests also report the patterns found
+// over the last days.
+func sendDigest(s *store.Store, uid, kind string, t time.Time, conf *store.Config,
+	digestCh chan<- discord.Digest) error {
+	start, end := digestPeriod(kind, t, conf.Location())
+
+	cur, err := report.Build(s, start, end)
+	if err != nil {
+		return err
+	}
+	if cur.Readings == 0 {
+		return nil
+	}
+	prev, err := cur.Previous(s)
+	if err != nil {
+		return err
+	}
+
+	digest := discord.Digest{UserID: uid, Title: digestTitles[kind], Report: cur, Previous: prev}
+	if kind == store.DigestWeekly {
+		findings, err := patterns.Detect(s, end.AddDate(0, 0, -patterns.DefaultDays), end)
+		if err != nil {
+			return err
+		}
+		digest.Findings = findings
 	}
+
+	digestCh <- digest
+	return nil
 }
@@ -0,0 +1,209 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/algao1/ichor/store"
+	"go.uber.org/zap"
+)
+
+// registration describes a user to register on startup, either from the
+// command line flags or from the users file.
+type registration struct {
+	ID             string `json:"discord_id"`
+	DexcomAccount  string `json:"dexcom_account"`
+	DexcomPassword string `json:"dexcom_password"`
+	Timezone       string `json:"timezone"`
+
+	// legacy is set for the user given by flags, who inherits any data
+	// stored before multi-user support.
+	legacy bool
+}
+
+// registrations collects the users given by flags and the users file.
+func registrations() ([]registration, error) {
+	regs := make([]registration, 0)
+
+	if uid != "" {
+		regs = append(regs, registration{
+			ID:             uid,
+			DexcomAccount:  dexAccount,
+			DexcomPassword: dexPassword,
+			Timezone:       timezone,
+			legacy:         true,
+		})
+	}
+
+	if usersFile == "" {
+		return regs, nil
+	}
+
+	b, err := os.ReadFile(usersFile)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read users file: %w", err)
+	}
+
+	var fileRegs []registration
+	if err := json.Unmarshal(b, &fileRegs); err != nil {
+		return nil, fmt.Errorf("unable to parse users file: %w", err)
+	}
+
+	for _, reg := range fileRegs {
+		if reg.ID == "" {
+			return nil, fmt.Errorf("users file contains a user without a discord_id")
+		}
+	}
+
+	return append(regs, fileRegs...), nil
+}
+
+// register adds the user to the registry, and sets up their buckets and
+// configuration.
+func register(s *store.Store, reg registration, logger *zap.Logger) error {
+	u := store.User{
+		ID:             reg.ID,
+		DexcomAccount:  reg.DexcomAccount,
+		DexcomPassword: reg.DexcomPassword,
+	}
+
+	// Keep the followers granted through Discord.
+	prev, err := s.GetUser(reg.ID)
+	if err == nil {
+		u.Followers = prev.Followers
+	} else if !errors.Is(err, store.ErrNotFound) {
+		return err
+	}
+
+	if err := s.AddUser(u); err != nil {
+		return err
+	}
+
+	if reg.legacy {
+		if err := s.MigrateUnscoped(reg.ID); err != nil {
+			return fmt.Errorf("unable to migrate unscoped data: %w", err)
+		}
+	}
+
+	us := s.ForUser(reg.ID)
+	if err := us.Initialize(); err != nil {
+		return err
+	}
+
+	// Migrate before the config is saved again below, which drops the
+	// fields that older versions kept in it.
+	if err := setupProfile(us); err != nil {
+		return err
+	}
+	if err := us.Migrate(); err != nil {
+		return fmt.Errorf("unable to migrate user data: %w", err)
+	}
+
+	conf, err := setupConfig(us, reg.Timezone)
+	if err != nil {
+		return err
+	}
+
+	if err := setupInsulinProducts(us); err != nil {
+		return err
+	}
+
+	logger.Info("registered user",
+		zap.String("user", reg.ID),
+		zap.Any("store config", conf),
+	)
+
+	return nil
+}
+
+// setupConfig writes the default configuration only if none exists, so that
+// changes made through /settings persist across restarts. A non-empty
+// timezone overrides the stored setting.
+func setupConfig(s *store.Store, timezone string) (*store.Config, error) {
+	var conf store.Config
+	err := s.GetObject(store.IndexConfig, &conf)
+	if errors.Is(err, store.ErrNotFound) {
+		conf = defaultConfig
+	} else if err != nil {
+		return nil, fmt.Errorf("unable to load config: %w", err)
+	}
+
+	// Configs saved by older versions are missing some fields.
+	if conf.Timezone == "" {
+		conf.Timezone = defaultConfig.Timezone
+	}
+	if conf.Units == "" {
+		conf.Units = defaultConfig.Units
+	}
+	if conf.UrgentLowThreshold == 0 {
+		conf.UrgentLowThreshold = defaultConfig.UrgentLowThreshold
+	}
+	if conf.StatusChartInterval == 0 {
+		conf.StatusChartInterval = defaultConfig.StatusChartInterval
+	}
+	if conf.InsulinCurve == "" {
+		conf.InsulinCurve = defaultConfig.InsulinCurve
+	}
+	if conf.CarbAbsorption == "" {
+		conf.CarbAbsorption = defaultConfig.CarbAbsorption
+	}
+	if conf.SensorLifetime == 0 {
+		conf.SensorLifetime = defaultConfig.SensorLifetime
+	}
+	if conf.SiteLifetime == 0 {
+		conf.SiteLifetime = defaultConfig.SiteLifetime
+	}
+	if conf.DailyDigest == "" {
+		conf.DailyDigest = defaultConfig.DailyDigest
+	}
+	if conf.WeeklyDigest == "" {
+		conf.WeeklyDigest = defaultConfig.WeeklyDigest
+	}
+
+	if timezone != "" {
+		conf.Timezone = timezone
+	}
+
+	if err := conf.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid config: %w", err)
+	}
+
+	if err := s.AddObject(store.IndexConfig, conf); err != nil {
+		return nil, fmt.Errorf("unable to save config: %w", err)
+	}
+
+	return &conf, nil
+}
+
+// setupInsulinProducts writes the default insulin products if the user has
+// none yet.
+func setupInsulinProducts(s *store.Store) error {
+	var products []store.InsulinProduct
+	err := s.GetObject(store.IndexInsulinProducts, &products)
+	if err == nil {
+		return nil
+	} else if !errors.Is(err, store.ErrNotFound) {
+		return fmt.Errorf("unable to load insulin products: %w", err)
+	}
+
+	if err := s.AddObject(store.IndexInsulinProducts, defaultInsulinProducts); err != nil {
+		return fmt.Errorf("unable to save insulin products: %w", err)
+	}
+	return nil
+}
+
+// setupProfile saves the default therapy profile as the first version if
+// the user has none yet.
+func setupProfile(s *store.Store) error {
+	_, err := s.GetProfile()
+	if err == nil {
+		return nil
+	} else if !errors.Is(err, store.ErrNotFound) {
+		return err
+	}
+
+	_, err = s.SaveProfile(defaultProfile)
+	return err
+}